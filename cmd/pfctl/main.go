@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/anthurium-ai/personal-finance/internal/app"
+	"github.com/anthurium-ai/personal-finance/internal/attachment"
+	"github.com/anthurium-ai/personal-finance/internal/backup"
+	"github.com/anthurium-ai/personal-finance/internal/classify"
+	"github.com/anthurium-ai/personal-finance/internal/db"
+	"github.com/anthurium-ai/personal-finance/internal/merchant"
+)
+
+const usage = `usage: pfctl <command> [flags]
+
+commands:
+  apply-rules           run merchant overrides and rules over existing transactions
+  normalise-merchants   re-derive merchant names from raw bank names and aliases
+  train                 rebuild the local classifier from confirmed categories
+  eval                  score each classification source against confirmed categories
+  backup                write the database and attachments to a .tar.gz
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var err error
+	switch os.Args[1] {
+	case "apply-rules":
+		err = runApplyRules(ctx, os.Args[2:])
+	case "normalise-merchants":
+		err = runNormaliseMerchants(ctx, os.Args[2:])
+	case "train":
+		err = runTrain(ctx, os.Args[2:])
+	case "eval":
+		err = runEval(ctx, os.Args[2:])
+	case "backup":
+		err = runBackup(ctx, os.Args[2:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func openDB(ctx context.Context, path string) (*sql.DB, error) {
+	d, err := db.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Migrate(ctx, d); err != nil {
+		d.Close()
+		return nil, err
+	}
+	return d, nil
+}
+
+func runApplyRules(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("apply-rules", flag.ExitOnError)
+	dbPath := fs.String("db", app.DefaultDBPath(), "sqlite db path")
+	from := fs.String("from", "", "first txn date (YYYY-MM-DD)")
+	to := fs.String("to", "", "last txn date (YYYY-MM-DD)")
+	all := fs.Bool("all", false, "also re-evaluate rows previously set by overrides/rules")
+	dryRun := fs.Bool("dry-run", false, "report the diff without writing")
+	_ = fs.Parse(args)
+
+	d, err := openDB(ctx, *dbPath)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	res, err := classify.ApplyRules(ctx, d, classify.ApplyOptions{
+		From:              *from,
+		To:                *to,
+		UncategorisedOnly: !*all,
+		DryRun:            *dryRun,
+	})
+	if err != nil {
+		return err
+	}
+	for _, ch := range res.Changes {
+		action := "apply"
+		if ch.Review {
+			action = "review"
+		}
+		fmt.Printf("%d\t%s\t%s\t%q -> %q\t%s\t%s: %s\n", ch.TxID, ch.Date, ch.Merchant, ch.Old, ch.New, action, ch.Source, ch.Reason)
+	}
+	fmt.Fprintf(os.Stderr, "scanned=%d changed=%d queued=%d unchanged=%d no-match=%d manual-skipped=%d dry-run=%v\n",
+		res.Scanned, res.Changed, res.Queued, res.Unchanged, res.NoMatch, res.SkippedManual, *dryRun)
+	return nil
+}
+
+func runNormaliseMerchants(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("normalise-merchants", flag.ExitOnError)
+	dbPath := fs.String("db", app.DefaultDBPath(), "sqlite db path")
+	dryRun := fs.Bool("dry-run", false, "report renames without writing")
+	_ = fs.Parse(args)
+
+	d, err := openDB(ctx, *dbPath)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	res, err := merchant.Renormalise(ctx, d, *dryRun)
+	if err != nil {
+		return err
+	}
+	for _, rn := range res.Renames {
+		fmt.Printf("%d\t%q -> %q\n", rn.Count, rn.Old, rn.New)
+	}
+	fmt.Fprintf(os.Stderr, "scanned=%d changed=%d manual-skipped=%d overrides-moved=%d dry-run=%v\n",
+		res.Scanned, res.Changed, res.SkippedManual, res.OverridesMoved, *dryRun)
+	return nil
+}
+
+func runTrain(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("train", flag.ExitOnError)
+	dbPath := fs.String("db", app.DefaultDBPath(), "sqlite db path")
+	_ = fs.Parse(args)
+
+	d, err := openDB(ctx, *dbPath)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	n, err := classify.Retrain(ctx, d)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "trained on %d confirmed transactions\n", n)
+	return nil
+}
+
+func runEval(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("eval", flag.ExitOnError)
+	dbPath := fs.String("db", app.DefaultDBPath(), "sqlite db path")
+	split := fs.String("split", "kfold", "holdout: kfold|time")
+	folds := fs.Int("k", 5, "folds for kfold")
+	testFrac := fs.Float64("test-frac", 0.2, "newest fraction held out for time split")
+	seed := fs.Int64("seed", 1, "kfold shuffle seed")
+	llm := fs.Bool("llm", false, "also score the LLM (slow)")
+	llmLimit := fs.Int("llm-limit", 50, "max transactions sent to the LLM (0 = all)")
+	htmlPath := fs.String("html", "", "also write an HTML report to this file")
+	var llmCfg classify.LLMConfig
+	llmCfg.RegisterFlags(fs)
+	_ = fs.Parse(args)
+
+	if *split != "kfold" && *split != "time" {
+		return fmt.Errorf("unknown split %q (want kfold|time)", *split)
+	}
+
+	d, err := openDB(ctx, *dbPath)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	opts := classify.EvalOptions{Split: *split, Folds: *folds, TestFrac: *testFrac, Seed: *seed, LLMLimit: *llmLimit}
+	if *llm {
+		p, err := classify.NewLLMProvider(llmCfg)
+		if err != nil {
+			return err
+		}
+		opts.LLM = classify.WithAudit(p, d)
+	}
+	rep, err := classify.Evaluate(ctx, d, opts)
+	if err != nil {
+		return err
+	}
+	if err := rep.WriteText(os.Stdout); err != nil {
+		return err
+	}
+	if *htmlPath != "" {
+		f, err := os.Create(*htmlPath)
+		if err != nil {
+			return err
+		}
+		if err := rep.WriteHTML(f); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	}
+	return nil
+}
+
+func runBackup(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	dbPath := fs.String("db", app.DefaultDBPath(), "sqlite db path")
+	attachDir := fs.String("attachments", "", "attachment store directory (default: attachments next to the db)")
+	out := fs.String("out", "", "archive to write (default: pf-backup-<time>.tar.gz)")
+	_ = fs.Parse(args)
+
+	if *attachDir == "" {
+		*attachDir = attachment.DefaultDir(*dbPath)
+	}
+	if *out == "" {
+		*out = "pf-backup-" + time.Now().Format("20060102-150405") + ".tar.gz"
+	}
+
+	d, err := openDB(ctx, *dbPath)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	if err := backup.Write(ctx, d, *attachDir, f); err != nil {
+		f.Close()
+		os.Remove(*out)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "wrote %s\n", *out)
+	return nil
+}
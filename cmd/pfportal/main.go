@@ -9,6 +9,8 @@ import (
 	"syscall"
 
 	"github.com/anthurium-ai/personal-finance/internal/app"
+	"github.com/anthurium-ai/personal-finance/internal/attachment"
+	"github.com/anthurium-ai/personal-finance/internal/classify"
 	"github.com/anthurium-ai/personal-finance/internal/db"
 	"github.com/anthurium-ai/personal-finance/internal/web"
 )
@@ -16,6 +18,9 @@ import (
 func main() {
 	addr := flag.String("addr", ":8787", "listen address")
 	dbPath := flag.String("db", app.DefaultDBPath(), "sqlite db path")
+	attachDir := flag.String("attachments", "", "attachment store directory (default: attachments next to the db)")
+	var llmCfg classify.LLMConfig
+	llmCfg.RegisterFlags(flag.CommandLine)
 	flag.Parse()
 
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
@@ -38,8 +43,12 @@ func main() {
 		os.Exit(1)
 	}
 
+	if *attachDir == "" {
+		*attachDir = attachment.DefaultDir(*dbPath)
+	}
+
 	fmt.Fprintf(os.Stderr, "pfportal listening on %s\n", *addr)
-	if err := app.Run(ctx, d, tmpl, app.Config{Addr: *addr}); err != nil {
+	if err := app.Run(ctx, d, tmpl, app.Config{Addr: *addr, LLM: llmCfg, AttachmentsDir: *attachDir}); err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
@@ -0,0 +1,385 @@
+// Package anomaly flags unusual spending for the alerts inbox.
+package anomaly
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/anthurium-ai/personal-finance/internal/db"
+)
+
+// Kinds stored in anomalies.kind.
+const (
+	KindLargeAmount   = "large_amount"
+	KindNewMerchant   = "new_merchant"
+	KindForeign       = "foreign"
+	KindDuplicate     = "duplicate"
+	KindCategorySpike = "category_spike"
+)
+
+var Kinds = []string{KindLargeAmount, KindNewMerchant, KindForeign, KindDuplicate, KindCategorySpike}
+
+// Statuses stored in anomalies.status.
+const (
+	StatusOpen      = "open"
+	StatusDismissed = "dismissed"
+)
+
+type Options struct {
+	Now   time.Time // category spikes are checked up to this month
+	Since time.Time // only transactions on or after this date are checked
+
+	// large_amount: at least LargeFactor times the merchant's median spend,
+	// over MinHistory earlier charges, and at least LargeMinCents
+	LargeFactor   float64
+	MinHistory    int
+	LargeMinCents int64
+
+	NewMerchantCents int64 // new_merchant: first charge at least this much
+
+	// category_spike: a month's spend at least SpikeFactor times the mean of
+	// the SpikeBaseline months before it, and SpikeMinCents over it
+	SpikeFactor   float64
+	SpikeBaseline int
+	SpikeMinCents int64
+}
+
+// DefaultOptions checks the last 60 days.
+func DefaultOptions(now time.Time) Options {
+	return Options{
+		Now:              now,
+		Since:            now.AddDate(0, 0, -60),
+		LargeFactor:      3,
+		MinHistory:       5,
+		LargeMinCents:    5000,
+		NewMerchantCents: 20000,
+		SpikeFactor:      1.5,
+		SpikeBaseline:    3,
+		SpikeMinCents:    10000,
+	}
+}
+
+// Anomaly is one finding. Key identifies it across scans, so a dismissed
+// anomaly is never raised again.
+type Anomaly struct {
+	ID          int64
+	CreatedAt   string
+	Kind        string
+	Key         string
+	TxID        int64 // 0 for category spikes
+	Category    string
+	Month       string // YYYY-MM, category spikes only
+	AmountCents int64
+	Title       string
+	Detail      string
+	Status      string
+}
+
+type spend struct {
+	id          int64
+	date        string
+	amountCents int64 // positive
+	merchant    string
+	details     string
+	txnType     string
+	account     string
+	category    string
+}
+
+// Scan runs every detector and stores new anomalies. It returns how many
+// were new.
+func Scan(ctx context.Context, d *sql.DB, opts Options) (int, error) {
+	rows, err := d.QueryContext(ctx, `
+		SELECT id, txn_date, -amount_cents, COALESCE(NULLIF(merchant_norm,''), merchant_raw, ''),
+		       COALESCE(details,''), COALESCE(txn_type,''), COALESCE(account,''),
+		       `+db.CategorySQL("")+`
+		FROM transactions WHERE amount_cents < 0
+		ORDER BY txn_date, id`)
+	if err != nil {
+		return 0, err
+	}
+	var all []spend
+	for rows.Next() {
+		var s spend
+		if err := rows.Scan(&s.id, &s.date, &s.amountCents, &s.merchant, &s.details, &s.txnType, &s.account, &s.category); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		all = append(all, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	since := opts.Since.Format("2006-01-02")
+	var found []Anomaly
+	found = append(found, largeAndNew(all, since, opts)...)
+	found = append(found, foreign(all, since)...)
+	found = append(found, duplicates(all, since)...)
+	found = append(found, categorySpikes(all, opts)...)
+
+	tx, err := d.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	n := 0
+	for _, a := range found {
+		res, err := tx.ExecContext(ctx, `INSERT INTO anomalies (kind, key, tx_id, category, month, amount_cents, title, detail)
+			VALUES (?,?,?,?,?,?,?,?) ON CONFLICT(key) DO NOTHING`,
+			a.Kind, a.Key, nullID(a.TxID), a.Category, a.Month, a.AmountCents, a.Title, a.Detail)
+		if err != nil {
+			return 0, err
+		}
+		k, _ := res.RowsAffected()
+		n += int(k)
+	}
+	return n, tx.Commit()
+}
+
+// largeAndNew flags charges far above the merchant's usual, and first
+// charges at a merchant above a threshold.
+func largeAndNew(all []spend, since string, opts Options) []Anomaly {
+	var out []Anomaly
+	history := map[string][]int64{}
+	for _, s := range all {
+		h := history[s.merchant]
+		if s.date >= since && s.merchant != "" {
+			switch {
+			case len(h) == 0 && s.amountCents >= opts.NewMerchantCents:
+				out = append(out, Anomaly{
+					Kind: KindNewMerchant, Key: fmt.Sprintf("%s:%d", KindNewMerchant, s.id), TxID: s.id,
+					Category: s.category, AmountCents: s.amountCents,
+					Title:  fmt.Sprintf("First charge from %s", s.merchant),
+					Detail: fmt.Sprintf("%s on %s, never seen before", money(s.amountCents), s.date),
+				})
+			case len(h) >= opts.MinHistory && s.amountCents >= opts.LargeMinCents:
+				med := median(h)
+				if float64(s.amountCents) >= opts.LargeFactor*float64(med) {
+					out = append(out, Anomaly{
+						Kind: KindLargeAmount, Key: fmt.Sprintf("%s:%d", KindLargeAmount, s.id), TxID: s.id,
+						Category: s.category, AmountCents: s.amountCents,
+						Title:  fmt.Sprintf("Unusually large charge from %s", s.merchant),
+						Detail: fmt.Sprintf("%s on %s; usually %s over %d charges", money(s.amountCents), s.date, money(med), len(h)),
+					})
+				}
+			}
+		}
+		history[s.merchant] = append(h, s.amountCents)
+	}
+	return out
+}
+
+var (
+	// a non-Australian three-letter country code ending the details, e.g.
+	// "SPOTIFY STOCKHOLM SWE". Two-letter codes are ordinary words too
+	// ("DRIVE IN", "PICK UP CA"), as are CAN and ARE, so they aren't counted.
+	foreignCountryRe = regexp.MustCompile(`\s(USA|GBR|NZL|SGP|HKG|IRL|NLD|JPN|FRA|DEU|LUX|SWE|CHE|IND|CHN|IDN|THA|MYS|PHL|VNM|ITA|ESP)$`)
+	// a foreign currency amount in the details, e.g. "USD 12.00"
+	foreignCurrencyRe = regexp.MustCompile(`\b(USD|EUR|GBP|NZD|SGD|HKD|JPY|CAD)\s*\d`)
+	// foreign-fee wording. Merchants use these words too ("FOREIGN EXCHANGE
+	// CAFE", "INTERNATIONAL ROSES"), so it only counts in the transaction
+	// type or in details that name a fee.
+	foreignWordsRe = regexp.MustCompile(`\b(FOREIGN|OVERSEAS|INTERNATIONAL|INTL|O/S)\b`)
+	feeRe          = regexp.MustCompile(`\bFEES?\b`)
+)
+
+func isForeign(s spend) bool {
+	text := strings.ToUpper(strings.TrimSpace(s.details))
+	switch {
+	case foreignCountryRe.MatchString(text), foreignCurrencyRe.MatchString(text):
+		return true
+	case foreignWordsRe.MatchString(strings.ToUpper(s.txnType)):
+		return true
+	}
+	return feeRe.MatchString(text) && foreignWordsRe.MatchString(text)
+}
+
+func foreign(all []spend, since string) []Anomaly {
+	var out []Anomaly
+	for _, s := range all {
+		if s.date < since || !isForeign(s) {
+			continue
+		}
+		out = append(out, Anomaly{
+			Kind: KindForeign, Key: fmt.Sprintf("%s:%d", KindForeign, s.id), TxID: s.id,
+			Category: s.category, AmountCents: s.amountCents,
+			Title:  fmt.Sprintf("Foreign transaction at %s", s.merchant),
+			Detail: fmt.Sprintf("%s on %s: %s", money(s.amountCents), s.date, s.details),
+		})
+	}
+	return out
+}
+
+// duplicates flags the same amount charged twice by a merchant on one card
+// on the same day. Bank exports carry dates only, so same-day is as close as
+// "within minutes" gets.
+func duplicates(all []spend, since string) []Anomaly {
+	type key struct {
+		date, merchant, account string
+		amount                  int64
+	}
+	first := map[key]spend{}
+	var out []Anomaly
+	for _, s := range all {
+		if s.date < since || s.merchant == "" {
+			continue
+		}
+		k := key{s.date, s.merchant, s.account, s.amountCents}
+		prev, ok := first[k]
+		if !ok {
+			first[k] = s
+			continue
+		}
+		out = append(out, Anomaly{
+			Kind: KindDuplicate, Key: fmt.Sprintf("%s:%d", KindDuplicate, s.id), TxID: s.id,
+			Category: s.category, AmountCents: s.amountCents,
+			Title:  fmt.Sprintf("Possible duplicate charge from %s", s.merchant),
+			Detail: fmt.Sprintf("%s twice on %s (transactions %d and %d)", money(s.amountCents), s.date, prev.id, s.id),
+		})
+	}
+	return out
+}
+
+// categorySpikes compares each category's spend in every month from Since
+// to Now (the current one so far) with the mean of the months before it.
+func categorySpikes(all []spend, opts Options) []Anomaly {
+	byMonth := map[string]map[string]int64{} // category -> month -> spend
+	earliest := ""
+	for _, s := range all {
+		if len(s.date) < 7 {
+			continue
+		}
+		if earliest == "" || s.date[:7] < earliest {
+			earliest = s.date[:7]
+		}
+		m := byMonth[s.category]
+		if m == nil {
+			m = map[string]int64{}
+			byMonth[s.category] = m
+		}
+		m[s.date[:7]] += s.amountCents
+	}
+
+	start := time.Date(opts.Since.Year(), opts.Since.Month(), 1, 0, 0, 0, 0, time.UTC)
+	var months []time.Time
+	for t := start; !t.After(opts.Now); t = t.AddDate(0, 1, 0) {
+		months = append(months, t)
+	}
+
+	cats := make([]string, 0, len(byMonth))
+	for c := range byMonth {
+		cats = append(cats, c)
+	}
+	sort.Strings(cats)
+
+	var out []Anomaly
+	for _, cat := range cats {
+		m := byMonth[cat]
+		for _, month := range months {
+			// a baseline reaching back before the data starts would read as a spike
+			if month.AddDate(0, -opts.SpikeBaseline, 0).Format("2006-01") < earliest {
+				continue
+			}
+			var base int64
+			for i := 1; i <= opts.SpikeBaseline; i++ {
+				base += m[month.AddDate(0, -i, 0).Format("2006-01")]
+			}
+			if base == 0 {
+				continue // nothing to compare with
+			}
+			mean := base / int64(opts.SpikeBaseline)
+			label := month.Format("2006-01")
+			cur := m[label]
+			if float64(cur) < opts.SpikeFactor*float64(mean) || cur-mean < opts.SpikeMinCents {
+				continue
+			}
+			out = append(out, Anomaly{
+				Kind: KindCategorySpike, Key: fmt.Sprintf("%s:%s:%s", KindCategorySpike, cat, label),
+				Category: cat, Month: label, AmountCents: cur,
+				Title:  fmt.Sprintf("%s spending up in %s", cat, label),
+				Detail: fmt.Sprintf("%s against a %d-month average of %s", money(cur), opts.SpikeBaseline, money(mean)),
+			})
+		}
+	}
+	return out
+}
+
+// List returns anomalies with status (all when empty), newest first.
+func List(ctx context.Context, q db.Querier, status, kind string, limit int) ([]Anomaly, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT id, created_at, kind, key, COALESCE(tx_id,0), COALESCE(category,''), COALESCE(month,''),
+		       COALESCE(amount_cents,0), title, COALESCE(detail,''), status
+		FROM anomalies
+		WHERE (? = '' OR status = ?) AND (? = '' OR kind = ?)
+		ORDER BY id DESC LIMIT ?`, status, status, kind, kind, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Anomaly
+	for rows.Next() {
+		var a Anomaly
+		if err := rows.Scan(&a.ID, &a.CreatedAt, &a.Kind, &a.Key, &a.TxID, &a.Category, &a.Month,
+			&a.AmountCents, &a.Title, &a.Detail, &a.Status); err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+// SetStatus marks anomalies open or dismissed.
+func SetStatus(ctx context.Context, q db.Querier, status string, ids ...int64) error {
+	for _, id := range ids {
+		if _, err := q.ExecContext(ctx, `UPDATE anomalies SET status=?, decided_at=strftime('%Y-%m-%dT%H:%M:%fZ','now') WHERE id=?`, status, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// OpenByKind counts open anomalies per kind, with every kind present.
+func OpenByKind(ctx context.Context, q db.Querier) (map[string]int, error) {
+	out := map[string]int{}
+	for _, k := range Kinds {
+		out[k] = 0
+	}
+	rows, err := q.QueryContext(ctx, `SELECT kind, COUNT(*) FROM anomalies WHERE status='open' GROUP BY kind`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var k string
+		var n int
+		if err := rows.Scan(&k, &n); err != nil {
+			return nil, err
+		}
+		out[k] = n
+	}
+	return out, rows.Err()
+}
+
+func median(xs []int64) int64 {
+	s := append([]int64(nil), xs...)
+	sort.Slice(s, func(i, j int) bool { return s[i] < s[j] })
+	return s[len(s)/2]
+}
+
+func money(cents int64) string {
+	return fmt.Sprintf("$%d.%02d", cents/100, cents%100)
+}
+
+func nullID(id int64) any {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
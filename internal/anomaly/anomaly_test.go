@@ -0,0 +1,172 @@
+package anomaly
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/anthurium-ai/personal-finance/internal/db"
+)
+
+func TestForeign(t *testing.T) {
+	cases := []struct {
+		details, txnType string
+		want             bool
+	}{
+		{details: "SPOTIFY STOCKHOLM SWE", want: true},
+		{details: "AMAZON MKTPLACE USD 12.00", want: true},
+		{details: "NETFLIX.COM USD12.99", want: true},
+		{details: "INTERNATIONAL TRANSACTION FEE", want: true},
+		{details: "OVERSEAS TXN FEE", want: true},
+		{details: "O/S FEES", want: true},
+		{details: "COLES 0421", txnType: "FOREIGN CURRENCY PURCHASE", want: true},
+		{details: "KMART", txnType: "INTL PURCHASE", want: true},
+
+		// merchants that use the same words
+		{details: "INTERNATIONAL ROSES SYDNEY"},
+		{details: "FOREIGN EXCHANGE CAFE NEWTOWN"},
+		{details: "OVERSEASIAN GROCER"},
+		{details: "INTERNATIONALE BAKERY FEES"},
+		{details: "WOOLWORTHS DRIVE IN"},
+		{details: "PICK UP CA"},
+		{details: "BUSD1 TOKENS"},
+		{details: "COLES SYDNEY AUS"},
+	}
+	for _, c := range cases {
+		s := spend{id: 1, date: "2025-03-01", amountCents: 1000, merchant: "X", details: c.details, txnType: c.txnType}
+		got := len(foreign([]spend{s}, "2025-01-01")) == 1
+		if got != c.want {
+			t.Errorf("foreign(%q, type %q) = %v, want %v", c.details, c.txnType, got, c.want)
+		}
+	}
+}
+
+func TestLargeAndNew(t *testing.T) {
+	opts := DefaultOptions(time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC))
+	var all []spend
+	add := func(date, merchant string, cents int64) {
+		all = append(all, spend{id: int64(len(all) + 1), date: date, merchant: merchant, amountCents: cents})
+	}
+	// five usual charges, then one three times the median
+	for _, d := range []string{"2024-10-01", "2024-11-01", "2024-12-01", "2025-01-01", "2025-02-01"} {
+		add(d, "AGL", 10000)
+	}
+	add("2025-03-01", "AGL", 30000)      // 6: large
+	add("2025-03-02", "AGL", 29000)      // under 3x
+	add("2025-03-03", "JB HI FI", 25000) // 8: new merchant
+	add("2025-03-04", "CAFE", 1500)      // new but small
+	add("2024-12-15", "BUNNINGS", 50000) // new but before Since
+
+	got := largeAndNew(all, opts.Since.Format("2006-01-02"), opts)
+	want := []struct {
+		kind string
+		tx   int64
+	}{{KindLargeAmount, 6}, {KindNewMerchant, 8}}
+	if len(got) != len(want) {
+		t.Fatalf("got %d anomalies, want %d: %+v", len(got), len(want), got)
+	}
+	for i, w := range want {
+		if got[i].Kind != w.kind || got[i].TxID != w.tx {
+			t.Errorf("anomaly %d = %s on %d, want %s on %d", i, got[i].Kind, got[i].TxID, w.kind, w.tx)
+		}
+	}
+}
+
+func TestDuplicates(t *testing.T) {
+	all := []spend{
+		{id: 1, date: "2025-03-01", merchant: "UBER", account: "card", amountCents: 2300},
+		{id: 2, date: "2025-03-01", merchant: "UBER", account: "card", amountCents: 2300},
+		{id: 3, date: "2025-03-01", merchant: "UBER", account: "other", amountCents: 2300},
+		{id: 4, date: "2025-03-02", merchant: "UBER", account: "card", amountCents: 2300},
+		{id: 5, date: "2025-03-01", merchant: "UBER", account: "card", amountCents: 2400},
+	}
+	got := duplicates(all, "2025-01-01")
+	if len(got) != 1 || got[0].TxID != 2 {
+		t.Errorf("duplicates = %+v, want transaction 2 only", got)
+	}
+}
+
+func TestCategorySpikes(t *testing.T) {
+	var all []spend
+	add := func(date, cat string, cents int64) {
+		all = append(all, spend{id: int64(len(all) + 1), date: date, category: cat, amountCents: cents})
+	}
+	for _, m := range []string{"2024-11", "2024-12", "2025-01"} {
+		add(m+"-10", "Dining", 20000)
+		add(m+"-10", "Groceries", 60000)
+	}
+	add("2025-02-10", "Dining", 40000)    // 2x the mean and $200 over: spike
+	add("2025-02-10", "Groceries", 65000) // within 1.5x
+	add("2025-03-10", "Dining", 90000)    // after Now: not checked yet
+
+	opts := DefaultOptions(time.Date(2025, 2, 20, 0, 0, 0, 0, time.UTC))
+	got := categorySpikes(all, opts)
+	if len(got) != 1 || got[0].Category != "Dining" || got[0].Month != "2025-02" || got[0].AmountCents != 40000 {
+		t.Errorf("categorySpikes = %+v, want Dining in 2025-02", got)
+	}
+
+	// a month whose baseline reaches back before the data is skipped
+	opts = DefaultOptions(time.Date(2025, 1, 20, 0, 0, 0, 0, time.UTC))
+	opts.Since = time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+	if got := categorySpikes(all, opts); len(got) != 0 {
+		t.Errorf("categorySpikes before a full baseline = %+v, want none", got)
+	}
+}
+
+func TestScan(t *testing.T) {
+	ctx := context.Background()
+	d, err := db.Open(filepath.Join(t.TempDir(), "pf.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if err := db.Migrate(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range []struct {
+		date, merchant, details, cat string
+		cents                        int64
+	}{
+		{"2025-03-01", "SPOTIFY", "SPOTIFY STOCKHOLM SWE", "", -1199},
+		{"2025-03-02", "UBER", "UBER TRIP", "Transport", -2300},
+		{"2025-03-02", "UBER", "UBER TRIP", "Transport", -2300},
+		{"2025-03-03", "EMPLOYER", "SALARY USD 100", "Income", 500000}, // credits aren't checked
+	} {
+		if _, err := d.Exec(`INSERT INTO transactions (txn_date, amount_cents, merchant_norm, details, category_norm, row_hash)
+			VALUES (?,?,?,?,?,hex(randomblob(8)))`, r.date, r.cents, r.merchant, r.details, r.cat); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	opts := DefaultOptions(time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC))
+	n, err := Scan(ctx, d, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("first scan found %d, want 2", n)
+	}
+	open, err := List(ctx, d, StatusOpen, KindForeign, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(open) != 1 || open[0].Category != "Uncategorised" {
+		t.Fatalf("foreign anomalies = %+v, want one Uncategorised", open)
+	}
+
+	// a dismissed anomaly is not raised again
+	if err := SetStatus(ctx, d, StatusDismissed, open[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := Scan(ctx, d, opts); err != nil || n != 0 {
+		t.Errorf("second scan = %d, %v, want 0", n, err)
+	}
+	counts, err := OpenByKind(ctx, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts[KindForeign] != 0 || counts[KindDuplicate] != 1 || len(counts) != len(Kinds) {
+		t.Errorf("OpenByKind = %v, want one duplicate and every kind present", counts)
+	}
+}
@@ -0,0 +1,78 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anthurium-ai/personal-finance/internal/anomaly"
+)
+
+func (a *App) handleAlerts(w http.ResponseWriter, r *http.Request) {
+	a.renderAlerts(w, r, "")
+}
+
+func (a *App) renderAlerts(w http.ResponseWriter, r *http.Request, msg string) {
+	status := r.FormValue("status")
+	if status == "" {
+		status = anomaly.StatusOpen
+	}
+	if status == "all" {
+		status = ""
+	}
+	kind := strings.TrimSpace(r.FormValue("kind"))
+	list, err := anomaly.List(r.Context(), a.DB, status, kind, 500)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	type row struct {
+		anomaly.Anomaly
+		Amount string
+	}
+	rows := make([]row, 0, len(list))
+	for _, an := range list {
+		rows = append(rows, row{Anomaly: an, Amount: fmtMoney(an.AmountCents)})
+	}
+	if status == "" {
+		status = "all"
+	}
+	a.Tmpl.Render(w, "alerts", map[string]any{
+		"Rows":    rows,
+		"Status":  status,
+		"Kind":    kind,
+		"Kinds":   anomaly.Kinds,
+		"Message": msg,
+	})
+}
+
+func (a *App) handleScanAlerts(w http.ResponseWriter, r *http.Request) {
+	n, err := anomaly.Scan(r.Context(), a.DB, anomaly.DefaultOptions(time.Now()))
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	a.renderAlerts(w, r, fmt.Sprintf("%d new alerts", n))
+}
+
+// handleSetAlertStatus dismisses (or reopens) the selected alerts.
+func (a *App) handleSetAlertStatus(w http.ResponseWriter, r *http.Request) {
+	_ = r.ParseForm()
+	status := anomaly.StatusDismissed
+	if r.FormValue("action") == "reopen" {
+		status = anomaly.StatusOpen
+	}
+	var ids []int64
+	for _, s := range r.Form["id"] {
+		if id, err := strconv.ParseInt(s, 10, 64); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	if err := anomaly.SetStatus(r.Context(), a.DB, status, ids...); err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	a.renderAlerts(w, r, fmt.Sprintf("%d alerts %s", len(ids), status))
+}
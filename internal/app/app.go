@@ -5,13 +5,23 @@ import (
 	"database/sql"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
+	"net/url"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/anthurium-ai/personal-finance/internal/anomaly"
+	"github.com/anthurium-ai/personal-finance/internal/attachment"
+	"github.com/anthurium-ai/personal-finance/internal/bills"
+	"github.com/anthurium-ai/personal-finance/internal/classify"
 	"github.com/anthurium-ai/personal-finance/internal/importer"
+	"github.com/anthurium-ai/personal-finance/internal/jobs"
 	"github.com/anthurium-ai/personal-finance/internal/metrics"
+	"github.com/anthurium-ai/personal-finance/internal/search"
+	"github.com/anthurium-ai/personal-finance/internal/tax"
 	"github.com/anthurium-ai/personal-finance/internal/web"
 	"github.com/go-chi/chi/v5"
 	"github.com/prometheus/client_golang/prometheus"
@@ -22,10 +32,29 @@ type App struct {
 	DB   *sql.DB
 	Tmpl *web.Templates
 	Met  *metrics.Collector
+	LLM  classify.LLMProvider // nil when disabled
+	// Files holds transaction attachments
+	Files attachment.Store
+
+	// ctx outlives requests; background jobs run under it
+	ctx context.Context
+}
+
+// txFilter is the /transactions query string.
+type txFilter struct {
+	Q         string // search box; see package search
+	Source    string // category_source
+	SetSince  string // YYYY-MM-DD, category_set_at lower bound
+	Confirmed string // yes|no|"" (any)
+	Receipt   string // has|missing|"" (any); missing is tax-relevant rows only
 }
 
+var categorySources = []string{classify.SourceBank, classify.SourceOverride, classify.SourceRule, classify.SourceModel, classify.SourceLLM, classify.SourceManual}
+
 type Config struct {
-	Addr string
+	Addr           string
+	LLM            classify.LLMConfig
+	AttachmentsDir string
 }
 
 func (a *App) Router() http.Handler {
@@ -40,6 +69,92 @@ func (a *App) Router() http.Handler {
 	r.Get("/tx/{id}", a.handleEditTx)
 	r.Post("/tx/{id}", a.handleSaveTx)
 	r.Post("/tx/{id}/suggest", a.handleSuggestTx)
+	r.Post("/tx/{id}/tax", a.handleSaveTxTax)
+	r.Post("/tx/{id}/attachments", a.handleAddAttachment)
+	r.Get("/attachments/{id}", a.handleAttachment)
+	r.Post("/attachments/{id}/delete", a.handleDeleteAttachment)
+
+	r.Get("/classify", a.handleApplyRulesForm)
+	r.Post("/classify/apply", a.handleApplyRules)
+	r.Post("/classify/retrain", a.handleRetrainModel)
+	r.Post("/classify/batch-llm", a.handleStartBatchLLM)
+	r.Post("/classify/policy", a.handleSavePolicy)
+	r.Get("/classify/history", a.handleHistory)
+	r.Post("/classify/history/{id}/revert", a.handleRevert)
+
+	r.Get("/review", a.handleReview)
+	r.Post("/review", a.handleDecideReview)
+	r.Post("/review/queue", a.handleQueueSuggestions)
+	r.Post("/review/rules/{id}/disable", a.handleDisableRule)
+
+	r.Get("/jobs", a.handleJobs)
+
+	r.Get("/subscriptions", a.handleSubscriptions)
+
+	r.Get("/budgets", a.handleBudgets)
+	r.Post("/budgets", a.handleSetBudget)
+	r.Post("/budgets/{id}/delete", a.handleDeleteBudget)
+
+	r.Get("/envelopes", a.handleEnvelopes)
+	r.Post("/envelopes/assign", a.handleAssignEnvelopes)
+	r.Post("/envelopes/move", a.handleMoveMoney)
+
+	r.Get("/goals", a.handleGoals)
+	r.Post("/goals", a.handleAddGoal)
+	r.Post("/goals/{id}/delete", a.handleDeleteGoal)
+
+	r.Get("/bills", a.handleBills)
+	r.Post("/bills", a.handleAddBill)
+	r.Post("/bills/match", a.handleMatchBills)
+	r.Post("/bills/{id}/delete", a.handleDeleteBill)
+	r.Post("/bills/{id}/paid", a.handleMarkBillPaid)
+	r.Post("/bills/{id}/unmatch", a.handleUnmatchBill)
+
+	r.Get("/loans", a.handleLoans)
+	r.Post("/loans", a.handleAddLoan)
+	r.Get("/loans/{id}", a.handleLoan)
+	r.Post("/loans/{id}/delete", a.handleDeleteLoan)
+	r.Post("/loans/{id}/rates", a.handleSetLoanRate)
+	r.Post("/loans/{id}/rates/delete", a.handleDeleteLoanRate)
+
+	r.Get("/debts", a.handleDebts)
+	r.Post("/debts", a.handleAddDebt)
+	r.Post("/debts/plan", a.handleSaveDebtPlan)
+	r.Post("/debts/{id}", a.handleUpdateDebt)
+	r.Post("/debts/{id}/delete", a.handleDeleteDebt)
+
+	r.Get("/tax", a.handleTax)
+	r.Get("/tax/print", a.handleTaxPrint)
+	r.Get("/tax/export.csv", a.handleTaxCSV)
+	r.Post("/tax/map", a.handleMapTaxCategory)
+
+	r.Get("/networth", a.handleNetWorth)
+	r.Post("/networth/items", a.handleAddNetWorthItem)
+	r.Post("/networth/items/{id}/delete", a.handleDeleteNetWorthItem)
+	r.Post("/networth/valuations", a.handleAddValuation)
+	r.Post("/networth/valuations/delete", a.handleDeleteValuation)
+
+	r.Get("/forecast", a.handleForecast)
+	r.Post("/forecast/accounts", a.handleSetAccountBalance)
+	r.Post("/forecast/accounts/delete", a.handleDeleteAccountBalance)
+
+	r.Get("/alerts", a.handleAlerts)
+	r.Post("/alerts", a.handleSetAlertStatus)
+	r.Post("/alerts/scan", a.handleScanAlerts)
+
+	r.Get("/merchants", a.handleMerchants)
+	r.Get("/merchants/{name}", a.handleMerchant)
+	r.Post("/merchants/{name}/rename", a.handleRenameMerchant)
+
+	r.Get("/aliases", a.handleAliases)
+	r.Post("/aliases", a.handleAddAlias)
+	r.Post("/aliases/{id}/delete", a.handleDeleteAlias)
+	r.Post("/aliases/renormalise", a.handleRenormalise)
+
+	r.Get("/llm", a.handleLLM)
+	r.Post("/llm/cache/clear", a.handleClearLLMCache)
+	r.Post("/llm/redaction", a.handleAddRedactionTerm)
+	r.Post("/llm/redaction/{id}/delete", a.handleDeleteRedactionTerm)
 
 	// metrics (refresh on scrape)
 	r.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
@@ -63,68 +178,167 @@ func (a *App) handleUpload(w http.ResponseWriter, r *http.Request) {
 
 	// Limit size (25MB).
 	limited := io.LimitReader(f, 25*1024*1024)
-	id, total, inserted, skipped, err := importer.ImportCCCSV(a.DB, limited, hdr.Filename)
+	res, err := importer.ImportCCCSV(r.Context(), a.DB, limited, hdr.Filename)
 	if err != nil {
 		a.Tmpl.Render(w, "upload", map[string]any{"Message": "import failed: " + err.Error()})
 		return
 	}
-	msg := fmt.Sprintf("import #%d: rows=%d inserted=%d skipped=%d", id, total, inserted, skipped)
+	msg := fmt.Sprintf("import #%d: rows=%d inserted=%d skipped=%d classified=%d (override=%d rule=%d) queued-for-review=%d",
+		res.ImportID, res.Total, res.Inserted, res.Skipped, res.Classified, res.ByOverride, res.ByRule, res.Queued)
+	if n, err := bills.Match(r.Context(), a.DB, time.Now()); err != nil {
+		msg += "; bill matching failed: " + err.Error()
+	} else if n > 0 {
+		msg += fmt.Sprintf("; %d bills paid", n)
+	}
+	if n, err := anomaly.Scan(r.Context(), a.DB, anomaly.DefaultOptions(time.Now())); err != nil {
+		msg += "; alert scan failed: " + err.Error()
+	} else if n > 0 {
+		msg += fmt.Sprintf("; %d new alerts", n)
+	}
 	a.Tmpl.Render(w, "upload", map[string]any{"Message": msg})
 }
 
 func (a *App) handleTransactions(w http.ResponseWriter, r *http.Request) {
-	// KISS for now: just show latest 50.
-	rows, err := a.DB.Query(`SELECT id, txn_date, amount_cents, category_norm, merchant_norm, details FROM transactions ORDER BY txn_date DESC, id DESC LIMIT 200`)
+	qs := r.URL.Query()
+	f := txFilter{
+		Q:         strings.TrimSpace(qs.Get("q")),
+		Source:    qs.Get("source"),
+		SetSince:  qs.Get("set_since"),
+		Confirmed: qs.Get("confirmed"),
+		Receipt:   qs.Get("receipt"),
+	}
+	var msg string
+	sq, err := search.Parse(f.Q)
+	if err != nil {
+		msg, sq = err.Error(), &search.Query{}
+	}
+	from, args := sq.From()
+	where := append([]string{"1=1"}, sq.Where...)
+	args = append(args, sq.Args...)
+	if f.Source != "" {
+		where = append(where, "COALESCE(t.category_source,'bank') = ?")
+		args = append(args, f.Source)
+	}
+	if f.SetSince != "" {
+		where = append(where, "t.category_set_at >= ?")
+		args = append(args, f.SetSince)
+	}
+	switch f.Confirmed {
+	case "yes":
+		where = append(where, "t.category_confirmed = 1")
+	case "no":
+		where = append(where, "t.category_confirmed = 0")
+	}
+	switch f.Receipt {
+	case "has":
+		where = append(where, "EXISTS (SELECT 1 FROM attachments a WHERE a.tx_id = t.id)")
+	case "missing":
+		where = append(where, tax.RelevantSQL, "NOT EXISTS (SELECT 1 FROM attachments a WHERE a.tx_id = t.id)")
+	}
+	cond := strings.Join(where, " AND ")
+
+	// totals over every match; the table shows the latest 200
+	var count int
+	var in, out int64
+	if err := a.DB.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(CASE WHEN t.amount_cents > 0 THEN t.amount_cents ELSE 0 END),0),
+		       COALESCE(SUM(CASE WHEN t.amount_cents < 0 THEN -t.amount_cents ELSE 0 END),0)
+		FROM `+from+` WHERE `+cond, args...).Scan(&count, &in, &out); err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+
+	rows, err := a.DB.Query(`
+		SELECT t.id, t.txn_date, t.amount_cents, `+sq.Highlight("category_norm")+`, COALESCE(t.merchant_norm,''), `+sq.Highlight("merchant_norm")+`,
+		       `+sq.Highlight("details")+`, COALESCE(t.category_source,'bank'), t.category_confidence, t.category_confirmed,
+		       (SELECT COUNT(*) FROM attachments a WHERE a.tx_id = t.id)
+		FROM `+from+`
+		WHERE `+cond+`
+		ORDER BY t.txn_date DESC, t.id DESC LIMIT 200`, args...)
 	if err != nil {
 		http.Error(w, err.Error(), 500)
 		return
 	}
 	defer rows.Close()
 	type row struct {
-		ID       int64
-		Date     string
-		Amount   string
-		Cat      string
-		Merchant string
-		Details  string
+		ID          int64
+		Date        string
+		Amount      string
+		Cat         string // with search hits marked, as are MerchantHit and Details
+		Merchant    string
+		MerchantHit string
+		Details     string
+		Source      string
+		Confidence  string
+		Confirmed   bool
+		Files       int
 	}
-	var out []row
+	var list []row
 	for rows.Next() {
 		var id, amount int64
-		var date, cat, merchant, details string
-		_ = rows.Scan(&id, &date, &amount, &cat, &merchant, &details)
-		out = append(out, row{ID: id, Date: date, Amount: fmtMoney(amount), Cat: cat, Merchant: merchant, Details: details})
+		var date, cat, merchant, merchantHit, details, source string
+		var conf sql.NullFloat64
+		var confirmed bool
+		var files int
+		_ = rows.Scan(&id, &date, &amount, &cat, &merchant, &merchantHit, &details, &source, &conf, &confirmed, &files)
+		rw := row{ID: id, Date: date, Amount: fmtMoney(amount), Cat: cat, Merchant: merchant, MerchantHit: merchantHit, Details: details,
+			Source: source, Confirmed: confirmed, Files: files}
+		if conf.Valid {
+			rw.Confidence = fmtConfidence(conf.Float64)
+		}
+		list = append(list, rw)
 	}
 
-	a.Tmpl.Render(w, "transactions", map[string]any{"Rows": out})
+	a.Tmpl.Render(w, "transactions", map[string]any{"Rows": list, "Filter": f, "Sources": categorySources, "Message": msg,
+		"Count": count, "In": fmtMoney(in), "Out": fmtMoney(out), "Net": fmtMoney(in - out)})
 }
 
 func (a *App) handleImports(w http.ResponseWriter, r *http.Request) {
-	rows, err := a.DB.Query(`SELECT id, created_at, file_name, rows_total, rows_inserted, rows_skipped FROM imports ORDER BY id DESC LIMIT 50`)
+	rows, err := a.DB.Query(`SELECT id, created_at, file_name, rows_total, rows_inserted, rows_skipped, rows_classified, rows_by_override, rows_by_rule, rows_queued FROM imports ORDER BY id DESC LIMIT 50`)
 	if err != nil {
 		http.Error(w, err.Error(), 500)
 		return
 	}
 	defer rows.Close()
 	type row struct {
-		ID       int64
-		Created  string
-		File     string
-		Total    int
-		Inserted int
-		Skipped  int
+		ID         int64
+		Created    string
+		File       string
+		Total      int
+		Inserted   int
+		Skipped    int
+		Classified int
+		ByOverride int
+		ByRule     int
+		Queued     int
 	}
 	var out []row
 	for rows.Next() {
 		var id int64
 		var created, file string
-		var total, ins, sk int
-		_ = rows.Scan(&id, &created, &file, &total, &ins, &sk)
-		out = append(out, row{ID: id, Created: created, File: file, Total: total, Inserted: ins, Skipped: sk})
+		var total, ins, sk, cl, byOv, byRule, queued int
+		_ = rows.Scan(&id, &created, &file, &total, &ins, &sk, &cl, &byOv, &byRule, &queued)
+		out = append(out, row{ID: id, Created: created, File: file, Total: total, Inserted: ins, Skipped: sk, Classified: cl, ByOverride: byOv, ByRule: byRule, Queued: queued})
 	}
 	a.Tmpl.Render(w, "imports", map[string]any{"Rows": out})
 }
 
+// seeOther ends a POST that worked by sending the browser back to page,
+// with q and msg in its query, so reloading the result doesn't post again.
+// The page shows msg as its message.
+func seeOther(w http.ResponseWriter, r *http.Request, page string, q url.Values, msg string) {
+	if q == nil {
+		q = url.Values{}
+	}
+	if msg != "" {
+		q.Set("msg", msg)
+	}
+	if len(q) > 0 {
+		page += "?" + q.Encode()
+	}
+	http.Redirect(w, r, page, http.StatusSeeOther)
+}
+
 func fmtMoney(cents int64) string {
 	sign := ""
 	if cents < 0 {
@@ -136,6 +350,48 @@ func fmtMoney(cents int64) string {
 	return sign + "$" + strconv.FormatInt(d, 10) + "." + fmt2(c)
 }
 
+// parseMoney reads a dollar amount such as "1,250.5", "$80" or "-12.30" into
+// cents. Only a single leading minus is allowed, and at most two decimals.
+func parseMoney(s string) (int64, error) {
+	in := s
+	s = strings.NewReplacer("$", "", ",", "", " ", "").Replace(s)
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" && frac == "" || len(frac) > 2 || !digits(whole) || !digits(frac) {
+		return 0, fmt.Errorf("not an amount: %q", in)
+	}
+	if whole == "" {
+		whole = "0"
+	}
+	d, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || d > (math.MaxInt64-99)/100 {
+		return 0, fmt.Errorf("not an amount: %q", in)
+	}
+	var c int64
+	if frac != "" {
+		c, _ = strconv.ParseInt(frac, 10, 64)
+		if len(frac) == 1 {
+			c *= 10
+		}
+	}
+	cents := d*100 + c
+	if neg {
+		cents = -cents
+	}
+	return cents, nil
+}
+
+// digits reports whether s is only ASCII digits; "" counts.
+func digits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
 func fmt2(v int64) string {
 	if v < 10 {
 		return "0" + strconv.FormatInt(v, 10)
@@ -146,7 +402,14 @@ func fmt2(v int64) string {
 func Run(ctx context.Context, db *sql.DB, tmpl *web.Templates, cfg Config) error {
 	met := metrics.New(db)
 	met.Register(prometheus.DefaultRegisterer)
-	a := &App{DB: db, Tmpl: tmpl, Met: met}
+	llm, err := classify.NewLLMProvider(cfg.LLM)
+	if err != nil {
+		return err
+	}
+	if err := jobs.Abandon(ctx, db); err != nil {
+		return err
+	}
+	a := &App{DB: db, Tmpl: tmpl, Met: met, LLM: classify.WithAudit(llm, db), Files: attachment.Store{Dir: cfg.AttachmentsDir}, ctx: ctx}
 	srv := &http.Server{Addr: cfg.Addr, Handler: a.Router()}
 
 	go func() {
@@ -0,0 +1,44 @@
+package app
+
+import "testing"
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+		ok   bool
+	}{
+		{"80", 8000, true},
+		{"$80", 8000, true},
+		{"1,250.5", 125050, true},
+		{"1 250.05", 125005, true},
+		{"-12.30", -1230, true},
+		{"-$12", -1200, true},
+		{".5", 50, true},
+		{"-.05", -5, true},
+		{"7.", 700, true},
+		{"0", 0, true},
+
+		{"", 0, false},
+		{"-", 0, false},
+		{".", 0, false},
+		{"abc", 0, false},
+		{"1.234", 0, false},
+		{"--5", 0, false},
+		{"+5", 0, false},
+		{"5-", 0, false},
+		{"1.+5", 0, false},
+		{"1.-5", 0, false},
+		{"-.-5", 0, false},
+		{"1e3", 0, false},
+		{"1.2.3", 0, false},
+		{"0x10", 0, false},
+		{"99999999999999999999", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := parseMoney(tt.in)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("parseMoney(%q) = %d, %v; want %d, ok %v", tt.in, got, err, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package app
+
+import (
+	"database/sql"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/anthurium-ai/personal-finance/internal/attachment"
+	"github.com/go-chi/chi/v5"
+)
+
+type attachmentView struct {
+	ID       int64
+	Filename string
+	Size     string
+	Image    bool
+	Added    string
+}
+
+// attachmentViews lists a transaction's attachments for a page.
+func (a *App) attachmentViews(r *http.Request, txID int64) ([]attachmentView, error) {
+	atts, err := attachment.List(r.Context(), a.DB, txID)
+	if err != nil {
+		return nil, err
+	}
+	var out []attachmentView
+	for i := range atts {
+		at := &atts[i]
+		out = append(out, attachmentView{ID: at.ID, Filename: at.Filename, Size: fmtSize(at.Size), Image: at.Image(), Added: at.CreatedAt[:min(10, len(at.CreatedAt))]})
+	}
+	return out, nil
+}
+
+func (a *App) handleAddAttachment(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	var exists bool
+	if err := a.DB.QueryRow(`SELECT EXISTS(SELECT 1 FROM transactions WHERE id=?)`, id).Scan(&exists); err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	if !exists {
+		http.Error(w, "transaction not found", 404)
+		return
+	}
+
+	// room for the multipart envelope around the largest file
+	r.Body = http.MaxBytesReader(w, r.Body, attachment.MaxSize+1<<20)
+	f, hdr, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "missing file (up to "+fmtSize(attachment.MaxSize)+")", 400)
+		return
+	}
+	defer f.Close()
+	if _, err := attachment.Add(r.Context(), a.DB, a.Files, id, hdr.Filename, f); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	http.Redirect(w, r, "/tx/"+strconv.FormatInt(id, 10), http.StatusSeeOther)
+}
+
+// handleAttachment serves a file for viewing in the browser.
+func (a *App) handleAttachment(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	at, err := attachment.Get(r.Context(), a.DB, id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "attachment not found", 404)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	f, err := a.Files.Open(at.SHA256)
+	if err != nil {
+		http.Error(w, "attachment file missing: "+err.Error(), 404)
+		return
+	}
+	defer f.Close()
+	w.Header().Set("Content-Type", at.ContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": at.Filename}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	created, _ := time.Parse(time.RFC3339, at.CreatedAt)
+	http.ServeContent(w, r, at.Filename, created, f)
+}
+
+func (a *App) handleDeleteAttachment(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	at, err := attachment.Get(r.Context(), a.DB, id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "attachment not found", 404)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	if err := attachment.Delete(r.Context(), a.DB, a.Files, id); err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	http.Redirect(w, r, "/tx/"+strconv.FormatInt(at.TxID, 10), http.StatusSeeOther)
+}
+
+// fmtSize is a file size for people: "820 KB", "3.4 MB".
+func fmtSize(n int64) string {
+	switch {
+	case n < 1<<10:
+		return fmt.Sprintf("%d B", n)
+	case n < 1<<20:
+		return fmt.Sprintf("%d KB", (n+1<<9)>>10)
+	}
+	return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+}
@@ -0,0 +1,224 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anthurium-ai/personal-finance/internal/bills"
+	"github.com/anthurium-ai/personal-finance/internal/classify"
+	"github.com/anthurium-ai/personal-finance/internal/forecast"
+	"github.com/go-chi/chi/v5"
+)
+
+type billOccurrence struct {
+	BillID int64
+	Name   string
+	Due    string
+	Amount string
+	Status string
+	TxID   int64
+	PaidOn string
+}
+
+func newBillOccurrence(o bills.Occurrence) billOccurrence {
+	bo := billOccurrence{BillID: o.Bill.ID, Name: o.Bill.Name, Due: o.Due.Format("2006-01-02"), Amount: fmtMoney(o.Bill.AmountCents), Status: o.Status, TxID: o.TxID}
+	if o.Status == bills.StatusPaid {
+		bo.PaidOn = o.PaidOn.Format("2006-01-02")
+		if o.TxID != 0 {
+			bo.Amount = fmtMoney(o.PaidCents)
+		}
+	}
+	return bo
+}
+
+func (a *App) handleBills(w http.ResponseWriter, r *http.Request) {
+	a.renderBills(w, r, r.URL.Query().Get("msg"))
+}
+
+// renderBills shows overdue and upcoming bills and a calendar for ?month=
+// (default this month), weeks starting Monday.
+func (a *App) renderBills(w http.ResponseWriter, r *http.Request, msg string) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	month := strings.TrimSpace(r.FormValue("month"))
+	first, err := time.Parse("2006-01", month)
+	if err != nil {
+		first = time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.UTC)
+		month = first.Format("2006-01")
+	}
+
+	// the grid runs Monday to Sunday around the month
+	gridFrom := first.AddDate(0, 0, -((int(first.Weekday()) + 6) % 7))
+	last := first.AddDate(0, 1, -1)
+	gridTo := last.AddDate(0, 0, 7-(int(last.Weekday())+6)%7)
+
+	occ, err := bills.Calendar(r.Context(), a.DB, gridFrom, gridTo, today)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	byDay := map[string][]billOccurrence{}
+	for _, o := range occ {
+		bo := newBillOccurrence(o)
+		byDay[bo.Due] = append(byDay[bo.Due], bo)
+	}
+	type cell struct {
+		Day     int
+		InMonth bool
+		Today   bool
+		Items   []billOccurrence
+	}
+	var weeks [][]cell
+	for d := gridFrom; d.Before(gridTo); d = d.AddDate(0, 0, 7) {
+		var week []cell
+		for i := 0; i < 7; i++ {
+			day := d.AddDate(0, 0, i)
+			week = append(week, cell{Day: day.Day(), InMonth: day.Month() == first.Month(), Today: day.Equal(today), Items: byDay[day.Format("2006-01-02")]})
+		}
+		weeks = append(weeks, week)
+	}
+
+	// overdue from the last year, and what's due in the next 30 days
+	recent, err := bills.Calendar(r.Context(), a.DB, today.AddDate(-1, 0, 0), today.AddDate(0, 0, 31), today)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	var overdue, upcoming []billOccurrence
+	for _, o := range recent {
+		switch o.Status {
+		case bills.StatusOverdue:
+			overdue = append(overdue, newBillOccurrence(o))
+		case bills.StatusDue, bills.StatusUpcoming:
+			upcoming = append(upcoming, newBillOccurrence(o))
+		}
+	}
+
+	list, err := bills.List(r.Context(), a.DB)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	type billRow struct {
+		bills.Bill
+		Amount string
+		Every  string
+		Until  string
+	}
+	var rows []billRow
+	for _, b := range list {
+		br := billRow{Bill: b, Amount: fmtMoney(b.AmountCents), Every: b.Describe()}
+		if !b.End.IsZero() {
+			br.Until = b.End.Format("2006-01-02")
+		}
+		rows = append(rows, br)
+	}
+	accounts, _ := forecast.KnownAccounts(r.Context(), a.DB)
+	cats, _ := classify.KnownCategories(r.Context(), a.DB)
+	a.Tmpl.Render(w, "bills", map[string]any{
+		"Month":      month,
+		"MonthName":  first.Format("January 2006"),
+		"Prev":       first.AddDate(0, -1, 0).Format("2006-01"),
+		"Next":       first.AddDate(0, 1, 0).Format("2006-01"),
+		"Weeks":      weeks,
+		"Overdue":    overdue,
+		"Upcoming":   upcoming,
+		"Bills":      rows,
+		"Rules":      bills.Rules,
+		"Accounts":   accounts,
+		"Categories": cats,
+		"Today":      today.Format("2006-01-02"),
+		"Message":    msg,
+	})
+}
+
+func (a *App) handleAddBill(w http.ResponseWriter, r *http.Request) {
+	b := bills.Bill{
+		Name:      r.FormValue("name"),
+		Account:   r.FormValue("account"),
+		Category:  strings.TrimSpace(r.FormValue("category")),
+		MatchText: r.FormValue("match_text"),
+		Rule:      r.FormValue("rule"),
+	}
+	var err error
+	if b.AmountCents, err = parseMoney(r.FormValue("amount")); err != nil {
+		a.renderBills(w, r, "amount: "+err.Error())
+		return
+	}
+	// amounts are entered positive; a bill is money going out
+	if b.AmountCents > 0 && r.FormValue("direction") != "income" {
+		b.AmountCents = -b.AmountCents
+	}
+	b.Interval, _ = strconv.Atoi(r.FormValue("interval"))
+	b.Day, _ = strconv.Atoi(r.FormValue("day"))
+	b.ToleranceDays, _ = strconv.Atoi(r.FormValue("tolerance_days"))
+	b.TolerancePct, _ = strconv.Atoi(r.FormValue("tolerance_pct"))
+	if b.Start, err = time.Parse("2006-01-02", r.FormValue("start")); err != nil {
+		a.renderBills(w, r, "start date must be YYYY-MM-DD")
+		return
+	}
+	if s := strings.TrimSpace(r.FormValue("end")); s != "" {
+		if b.End, err = time.Parse("2006-01-02", s); err != nil {
+			a.renderBills(w, r, "end date must be YYYY-MM-DD")
+			return
+		}
+	}
+	if _, err := bills.Create(r.Context(), a.DB, b); err != nil {
+		a.renderBills(w, r, err.Error())
+		return
+	}
+	msg := fmt.Sprintf("bill %q added", strings.TrimSpace(b.Name))
+	if n, err := bills.Match(r.Context(), a.DB, time.Now()); err == nil && n > 0 {
+		msg += fmt.Sprintf("; %d already paid", n)
+	}
+	seeOther(w, r, "/bills", monthQuery(r), msg)
+}
+
+func (a *App) handleMatchBills(w http.ResponseWriter, r *http.Request) {
+	n, err := bills.Match(r.Context(), a.DB, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	seeOther(w, r, "/bills", monthQuery(r), fmt.Sprintf("%d bills matched to transactions", n))
+}
+
+func (a *App) handleDeleteBill(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err := bills.Delete(r.Context(), a.DB, id); err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	seeOther(w, r, "/bills", monthQuery(r), "bill deleted")
+}
+
+func (a *App) handleMarkBillPaid(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	due, err := time.Parse("2006-01-02", r.FormValue("due"))
+	if err != nil {
+		http.Error(w, "bad due date", 400)
+		return
+	}
+	if err := bills.MarkPaid(r.Context(), a.DB, id, due); err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	seeOther(w, r, "/bills", monthQuery(r), "marked paid")
+}
+
+func (a *App) handleUnmatchBill(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	due, err := time.Parse("2006-01-02", r.FormValue("due"))
+	if err != nil {
+		http.Error(w, "bad due date", 400)
+		return
+	}
+	if err := bills.Unmatch(r.Context(), a.DB, id, due); err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	seeOther(w, r, "/bills", monthQuery(r), "payment removed")
+}
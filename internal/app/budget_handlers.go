@@ -0,0 +1,146 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anthurium-ai/personal-finance/internal/budget"
+	"github.com/anthurium-ai/personal-finance/internal/classify"
+	"github.com/go-chi/chi/v5"
+)
+
+func (a *App) handleBudgets(w http.ResponseWriter, r *http.Request) {
+	a.renderBudgets(w, r, r.URL.Query().Get("msg"))
+}
+
+// renderBudgets shows budget vs actual for ?month= (default this month). For
+// the current month spend runs to today and pace is shown; other months are
+// shown as at their last day.
+func (a *App) renderBudgets(w http.ResponseWriter, r *http.Request, msg string) {
+	now := time.Now()
+	thisMonth := now.Format("2006-01")
+	month := r.URL.Query().Get("month")
+	m, err := time.Parse("2006-01", month)
+	if err != nil {
+		month = thisMonth
+		m, _ = time.Parse("2006-01", month)
+	}
+	asOf := now
+	current := month == thisMonth
+	if !current {
+		asOf = m.AddDate(0, 1, -1)
+	}
+
+	status, err := budget.Track(r.Context(), a.DB, asOf)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	type row struct {
+		Category  string
+		Period    string
+		From, To  string
+		Budget    string
+		Spent     string
+		Remaining string
+		Used      string
+		Expected  string
+		Projected string
+		Over      bool
+		OverPace  bool
+	}
+	var rows []row
+	over, ahead := 0, 0
+	for _, s := range status {
+		rw := row{
+			Category:  s.Category,
+			Period:    s.Period,
+			From:      s.From.Format("2006-01-02"),
+			To:        s.To.AddDate(0, 0, -1).Format("2006-01-02"),
+			Budget:    fmtMoney(s.AmountCents),
+			Spent:     fmtMoney(s.SpentCents),
+			Remaining: fmtMoney(s.RemainingCents),
+			Used:      fmt.Sprintf("%.0f%%", 100*s.UsedRatio()),
+			Expected:  fmtMoney(s.ExpectedCents),
+			Projected: fmtMoney(s.ProjectedCents()),
+			Over:      s.Over(),
+			OverPace:  current && !s.Over() && s.OverPace(),
+		}
+		if rw.Over {
+			over++
+		}
+		if rw.OverPace {
+			ahead++
+		}
+		rows = append(rows, rw)
+	}
+
+	all, err := budget.List(r.Context(), a.DB)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	type saved struct {
+		budget.Budget
+		Amount string
+	}
+	var list []saved
+	for _, b := range all {
+		list = append(list, saved{Budget: b, Amount: fmtMoney(b.AmountCents)})
+	}
+	cats, _ := classify.KnownCategories(r.Context(), a.DB)
+	a.Tmpl.Render(w, "budgets", map[string]any{
+		"Rows":       rows,
+		"Month":      month,
+		"Prev":       m.AddDate(0, -1, 0).Format("2006-01"),
+		"Next":       m.AddDate(0, 1, 0).Format("2006-01"),
+		"Current":    current,
+		"ThisMonth":  thisMonth,
+		"Over":       over,
+		"Ahead":      ahead,
+		"Budgets":    list,
+		"Periods":    budget.Periods,
+		"Categories": cats,
+		"Message":    msg,
+	})
+}
+
+func (a *App) handleSetBudget(w http.ResponseWriter, r *http.Request) {
+	amount, err := parseMoney(r.FormValue("amount"))
+	if err != nil {
+		a.renderBudgets(w, r, err.Error())
+		return
+	}
+	b := budget.Budget{
+		Category:    r.FormValue("category"),
+		Period:      r.FormValue("period"),
+		AmountCents: amount,
+		StartMonth:  strings.TrimSpace(r.FormValue("start_month")),
+	}
+	if err := budget.Set(r.Context(), a.DB, b); err != nil {
+		a.renderBudgets(w, r, err.Error())
+		return
+	}
+	seeOther(w, r, "/budgets", monthQuery(r), fmt.Sprintf("%s: %s %s from %s", b.Category, fmtMoney(b.AmountCents), b.Period, b.StartMonth))
+}
+
+func (a *App) handleDeleteBudget(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err := budget.Delete(r.Context(), a.DB, id); err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	seeOther(w, r, "/budgets", monthQuery(r), "budget deleted")
+}
+
+// monthQuery keeps the ?month= a page was posted from.
+func monthQuery(r *http.Request) url.Values {
+	if m := r.URL.Query().Get("month"); m != "" {
+		return url.Values{"month": {m}}
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/anthurium-ai/personal-finance/internal/classify"
+	"github.com/anthurium-ai/personal-finance/internal/jobs"
+	"github.com/go-chi/chi/v5"
+)
+
+func (a *App) handleApplyRulesForm(w http.ResponseWriter, r *http.Request) {
+	a.renderApplyRules(w, r, map[string]any{"Opts": classify.ApplyOptions{UncategorisedOnly: true, DryRun: true}})
+}
+
+// renderApplyRules adds the local model's status to the page data.
+func (a *App) renderApplyRules(w http.ResponseWriter, r *http.Request, data map[string]any) {
+	if m, err := classify.LoadModel(r.Context(), a.DB); err == nil {
+		data["ModelExamples"] = m.Examples()
+	}
+	var pending int
+	_ = a.DB.QueryRowContext(r.Context(), `SELECT COUNT(*) FROM classification_suggestions WHERE status='pending'`).Scan(&pending)
+	data["Pending"] = pending
+	data["LLMEnabled"] = a.LLM != nil
+	if p, err := classify.LoadPolicy(r.Context(), a.DB); err == nil {
+		data["Policy"] = p.List()
+	}
+	a.Tmpl.Render(w, "apply_rules", data)
+}
+
+func (a *App) handleRetrainModel(w http.ResponseWriter, r *http.Request) {
+	n, err := classify.Retrain(r.Context(), a.DB)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	a.renderApplyRules(w, r, map[string]any{
+		"Opts":    classify.ApplyOptions{UncategorisedOnly: true, DryRun: true},
+		"Message": fmt.Sprintf("local model retrained on %d confirmed transactions", n),
+	})
+}
+
+func (a *App) handleApplyRules(w http.ResponseWriter, r *http.Request) {
+	_ = r.ParseForm()
+	opts := classify.ApplyOptions{
+		From:              strings.TrimSpace(r.FormValue("from")),
+		To:                strings.TrimSpace(r.FormValue("to")),
+		UncategorisedOnly: r.FormValue("uncategorised") != "",
+		DryRun:            r.FormValue("dry_run") != "",
+	}
+	res, err := classify.ApplyRules(r.Context(), a.DB, opts)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	verb := "changed"
+	if opts.DryRun {
+		verb = "would change"
+	}
+	msg := fmt.Sprintf("scanned=%d %s=%d queued-for-review=%d unchanged=%d no-match=%d manual-skipped=%d",
+		res.Scanned, verb, res.Changed, res.Queued, res.Unchanged, res.NoMatch, res.SkippedManual)
+	a.renderApplyRules(w, r, map[string]any{"Opts": opts, "Result": res, "Message": msg})
+}
+
+func (a *App) handleStartBatchLLM(w http.ResponseWriter, r *http.Request) {
+	_ = r.ParseForm()
+	opts := classify.BatchOptions{BatchSize: 20, Examples: 15}
+	if n, err := strconv.Atoi(r.FormValue("limit")); err == nil {
+		opts.Limit = n
+	}
+	_, err := jobs.Start(a.ctx, a.DB, classify.JobBatchLLM, func(ctx context.Context, jobID int64, progress jobs.Progress) (string, error) {
+		res, err := classify.BatchLLM(ctx, a.DB, a.LLM, jobID, opts, progress)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("merchants=%d cached=%d applied=%d suggestions=%d failed=%d", res.Merchants, res.Cached, res.Applied, res.Suggestions, res.Failed), nil
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	http.Redirect(w, r, "/jobs", http.StatusSeeOther)
+}
+
+func (a *App) handleJobs(w http.ResponseWriter, r *http.Request) {
+	list, err := jobs.Recent(r.Context(), a.DB, 50)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	running := false
+	for _, j := range list {
+		running = running || j.Status == jobs.StatusRunning
+	}
+	a.Tmpl.Render(w, "jobs", map[string]any{"Jobs": list, "Running": running})
+}
+
+// handleSavePolicy stores every source's threshold from the policy form
+// (auto_<source>, min_<source>).
+func (a *App) handleSavePolicy(w http.ResponseWriter, r *http.Request) {
+	_ = r.ParseForm()
+	tx, err := a.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	defer tx.Rollback()
+	for _, src := range classify.PolicySources {
+		t := classify.Threshold{Source: src, Auto: r.FormValue("auto_"+src) != ""}
+		if t.MinConfidence, err = strconv.ParseFloat(strings.TrimSpace(r.FormValue("min_"+src)), 64); err != nil {
+			http.Error(w, src+": invalid confidence", http.StatusBadRequest)
+			return
+		}
+		if err := classify.SavePolicy(r.Context(), tx, t); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	a.renderApplyRules(w, r, map[string]any{
+		"Opts":    classify.ApplyOptions{UncategorisedOnly: true, DryRun: true},
+		"Message": "auto-apply policy saved",
+	})
+}
+
+func (a *App) handleHistory(w http.ResponseWriter, r *http.Request) {
+	a.renderHistory(w, r, "")
+}
+
+func (a *App) renderHistory(w http.ResponseWriter, r *http.Request, msg string) {
+	source := strings.TrimSpace(r.FormValue("source"))
+	list, err := classify.History(r.Context(), a.DB, source, 500)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	type row struct {
+		classify.AutoApplied
+		Confidence string
+	}
+	rows := make([]row, 0, len(list))
+	for _, h := range list {
+		rows = append(rows, row{AutoApplied: h, Confidence: fmtConfidence(h.Confidence)})
+	}
+	a.Tmpl.Render(w, "history", map[string]any{
+		"Rows":    rows,
+		"Source":  source,
+		"Sources": classify.PolicySources,
+		"Message": msg,
+	})
+}
+
+func (a *App) handleRevert(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	err := classify.Revert(r.Context(), a.DB, id)
+	if errors.Is(err, classify.ErrChangedSince) || errors.Is(err, classify.ErrNoDecision) {
+		a.renderHistory(w, r, fmt.Sprintf("#%d not reverted: %v", id, err))
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	a.renderHistory(w, r, fmt.Sprintf("#%d reverted", id))
+}
@@ -0,0 +1,265 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anthurium-ai/personal-finance/internal/debt"
+	"github.com/anthurium-ai/personal-finance/internal/forecast"
+	"github.com/go-chi/chi/v5"
+)
+
+func (a *App) handleDebts(w http.ResponseWriter, r *http.Request) {
+	a.renderDebts(w, r, r.URL.Query().Get("msg"))
+}
+
+// renderDebts shows the debts, how each strategy would pay them off from the
+// plan's budget (or ?budget= to try another), the chosen plan month by month,
+// and the payments made so far against it.
+func (a *App) renderDebts(w http.ResponseWriter, r *http.Request, msg string) {
+	ctx := r.Context()
+	debts, err := debt.List(ctx, a.DB)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	plan, err := debt.LoadPlan(ctx, a.DB)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	now := time.Now()
+	thisMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	p := debt.Plan{Strategy: debt.StrategyAvalanche, Start: thisMonth}
+	if plan != nil {
+		p = *plan
+	}
+	if s := strings.TrimSpace(r.URL.Query().Get("budget")); s != "" {
+		if p.BudgetCents, err = parseMoney(s); err != nil {
+			msg = "budget: " + err.Error()
+		}
+		p.Start = thisMonth
+	}
+	if s := r.URL.Query().Get("strategy"); s != "" {
+		p.Strategy = s
+	}
+	preview := plan == nil || *plan != p
+
+	type debtRow struct {
+		debt.Debt
+		Balance string
+		AsOf    string
+		Minimum string
+		Rate    string
+		PaidOff string
+	}
+	var rows []debtRow
+	var owed, minimums int64
+	for _, d := range debts {
+		rows = append(rows, debtRow{Debt: d, Balance: fmtMoney(d.BalanceCents), AsOf: d.AsOf.Format("2006-01-02"), Minimum: fmtMoney(d.MinimumCents), Rate: fmtRate(d.RatePct)})
+		owed += d.BalanceCents
+		minimums += d.MinimumCents
+	}
+
+	type comparison struct {
+		Strategy string
+		Chosen   bool
+		DebtFree string
+		Months   int
+		Interest string
+		Saved    string // against the worst strategy
+	}
+	type month struct {
+		Month string
+		Cells []string
+		Total string
+	}
+	type tracked struct {
+		Month   string
+		Cells   []string
+		Paid    string
+		Planned string
+		Behind  bool
+	}
+	var comparisons []comparison
+	var order []string
+	var schedule []month
+	var tracking []tracked
+	if len(debts) > 0 && p.BudgetCents > 0 {
+		var worst int64
+		var all []*debt.Schedule
+		for _, st := range debt.Strategies {
+			s, err := debt.Simulate(debts, p.BudgetCents, st, p.Start)
+			if err != nil {
+				msg = fmt.Sprintf("%s: minimums come to %s", err.Error(), fmtMoney(minimums))
+				all = nil
+				break
+			}
+			worst = max(worst, s.InterestCents)
+			all = append(all, s)
+		}
+		for _, s := range all {
+			c := comparison{Strategy: s.Strategy, Chosen: s.Strategy == p.Strategy, DebtFree: "never", Months: len(s.Months), Interest: fmtMoney(s.InterestCents)}
+			if !s.DebtFree.IsZero() {
+				c.DebtFree = s.DebtFree.Format("January 2006")
+			}
+			if saved := worst - s.InterestCents; saved > 0 {
+				c.Saved = fmtMoney(saved)
+			}
+			comparisons = append(comparisons, c)
+			if !c.Chosen {
+				continue
+			}
+
+			for i, d := range s.Debts {
+				order = append(order, d.Name)
+				for j := range rows {
+					if rows[j].ID == d.ID && !s.PaidOff[i].IsZero() {
+						rows[j].PaidOff = s.PaidOff[i].Format("Jan 2006")
+					}
+				}
+			}
+			for _, m := range s.Months {
+				mo := month{Month: m.Month.Format("Jan 2006")}
+				var total int64
+				for _, l := range m.Lines {
+					cell := ""
+					if l.PaymentCents > 0 {
+						cell = fmt.Sprintf("%s → %s", fmtMoney(l.PaymentCents), fmtMoney(l.BalanceCents))
+					}
+					mo.Cells = append(mo.Cells, cell)
+					total += l.PaymentCents
+				}
+				mo.Total = fmtMoney(total)
+				schedule = append(schedule, mo)
+			}
+
+			if plan != nil && !preview {
+				paid, err := debt.Payments(ctx, a.DB, s.Debts, p.Start, thisMonth.AddDate(0, 1, 0))
+				if err != nil {
+					http.Error(w, err.Error(), 500)
+					return
+				}
+				for i, m := range s.Months {
+					if m.Month.After(thisMonth) {
+						break
+					}
+					key := m.Month.Format("2006-01")
+					t := tracked{Month: m.Month.Format("Jan 2006")}
+					var actual, planned int64
+					for j, d := range s.Debts {
+						t.Cells = append(t.Cells, fmt.Sprintf("%s of %s", fmtMoney(paid[d.ID][key]), fmtMoney(s.Months[i].Lines[j].PaymentCents)))
+						actual += paid[d.ID][key]
+						planned += s.Months[i].Lines[j].PaymentCents
+					}
+					t.Paid, t.Planned = fmtMoney(actual), fmtMoney(planned)
+					// the month in progress isn't behind yet
+					t.Behind = actual < planned && m.Month.Before(thisMonth)
+					tracking = append(tracking, t)
+				}
+			}
+		}
+	}
+
+	accounts, _ := forecast.KnownAccounts(ctx, a.DB)
+	budget := ""
+	if p.BudgetCents > 0 {
+		budget = fmt.Sprintf("%d.%02d", p.BudgetCents/100, p.BudgetCents%100)
+	}
+	a.Tmpl.Render(w, "debts", map[string]any{
+		"Debts":       rows,
+		"Owed":        fmtMoney(owed),
+		"Minimums":    fmtMoney(minimums),
+		"Budget":      budget,
+		"Strategy":    p.Strategy,
+		"Strategies":  debt.Strategies,
+		"Saved":       plan != nil,
+		"Preview":     preview,
+		"Start":       p.Start.Format("January 2006"),
+		"Comparisons": comparisons,
+		"Order":       order,
+		"Schedule":    schedule,
+		"Tracking":    tracking,
+		"Accounts":    accounts,
+		"Today":       now.Format("2006-01-02"),
+		"Message":     msg,
+	})
+}
+
+func (a *App) handleAddDebt(w http.ResponseWriter, r *http.Request) {
+	d := debt.Debt{Name: r.FormValue("name"), Account: r.FormValue("account"), MatchText: r.FormValue("match_text")}
+	var err error
+	if d.BalanceCents, err = parseMoney(r.FormValue("balance")); err != nil {
+		a.renderDebts(w, r, "balance: "+err.Error())
+		return
+	}
+	if s := strings.TrimSpace(r.FormValue("minimum")); s != "" {
+		if d.MinimumCents, err = parseMoney(s); err != nil {
+			a.renderDebts(w, r, "minimum: "+err.Error())
+			return
+		}
+	}
+	if d.RatePct, err = parseRate(r.FormValue("rate")); err != nil {
+		a.renderDebts(w, r, "rate: "+err.Error())
+		return
+	}
+	if d.AsOf, err = time.Parse("2006-01-02", r.FormValue("as_of")); err != nil {
+		a.renderDebts(w, r, "balance date must be YYYY-MM-DD")
+		return
+	}
+	d.Priority, _ = strconv.Atoi(r.FormValue("priority"))
+	if _, err := debt.Create(r.Context(), a.DB, d); err != nil {
+		a.renderDebts(w, r, err.Error())
+		return
+	}
+	seeOther(w, r, "/debts", nil, fmt.Sprintf("debt %q added", strings.TrimSpace(d.Name)))
+}
+
+func (a *App) handleUpdateDebt(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	balance, err := parseMoney(r.FormValue("balance"))
+	if err != nil {
+		a.renderDebts(w, r, "balance: "+err.Error())
+		return
+	}
+	asOf, err := time.Parse("2006-01-02", r.FormValue("as_of"))
+	if err != nil {
+		a.renderDebts(w, r, "balance date must be YYYY-MM-DD")
+		return
+	}
+	priority, _ := strconv.Atoi(r.FormValue("priority"))
+	if err := debt.Update(r.Context(), a.DB, id, balance, asOf, priority); err != nil {
+		a.renderDebts(w, r, err.Error())
+		return
+	}
+	seeOther(w, r, "/debts", nil, "debt updated")
+}
+
+func (a *App) handleDeleteDebt(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err := debt.Delete(r.Context(), a.DB, id); err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	seeOther(w, r, "/debts", nil, "debt deleted")
+}
+
+// handleSaveDebtPlan saves the budget and strategy, following the plan from
+// this month.
+func (a *App) handleSaveDebtPlan(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	p := debt.Plan{Strategy: r.FormValue("strategy"), Start: time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)}
+	var err error
+	if p.BudgetCents, err = parseMoney(r.FormValue("budget")); err != nil {
+		a.renderDebts(w, r, "budget: "+err.Error())
+		return
+	}
+	if err := debt.SavePlan(r.Context(), a.DB, p); err != nil {
+		a.renderDebts(w, r, err.Error())
+		return
+	}
+	seeOther(w, r, "/debts", nil, fmt.Sprintf("following the %s plan at %s a month", p.Strategy, fmtMoney(p.BudgetCents)))
+}
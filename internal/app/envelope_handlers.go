@@ -0,0 +1,136 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/anthurium-ai/personal-finance/internal/classify"
+	"github.com/anthurium-ai/personal-finance/internal/envelope"
+)
+
+func (a *App) handleEnvelopes(w http.ResponseWriter, r *http.Request) {
+	a.renderEnvelopes(w, r, r.URL.Query().Get("msg"))
+}
+
+func (a *App) renderEnvelopes(w http.ResponseWriter, r *http.Request, msg string) {
+	thisMonth := time.Now().Format("2006-01")
+	month := strings.TrimSpace(r.FormValue("month"))
+	m, err := time.Parse("2006-01", month)
+	if err != nil {
+		month = thisMonth
+		m, _ = time.Parse("2006-01", month)
+	}
+	l, err := envelope.Month(r.Context(), a.DB, month)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	entries, err := envelope.Entries(r.Context(), a.DB, month)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+
+	type row struct {
+		Category  string
+		Carried   string
+		Assigned  string
+		Activity  string
+		Available string
+		Overspent bool
+	}
+	var rows []row
+	var overspent []string
+	for _, e := range l.Envelopes {
+		rows = append(rows, row{
+			Category:  e.Category,
+			Carried:   fmtMoney(e.CarriedCents),
+			Assigned:  fmt.Sprintf("%.2f", float64(e.AssignedCents)/100),
+			Activity:  fmtMoney(e.ActivityCents),
+			Available: fmtMoney(e.AvailableCents),
+			Overspent: e.Overspent(),
+		})
+		if e.Overspent() {
+			overspent = append(overspent, e.Category)
+		}
+	}
+	type entry struct {
+		envelope.Entry
+		Amount string
+	}
+	var log []entry
+	for _, e := range entries {
+		log = append(log, entry{Entry: e, Amount: fmtMoney(e.AmountCents)})
+	}
+	cats, _ := classify.KnownCategories(r.Context(), a.DB)
+	a.Tmpl.Render(w, "envelopes", map[string]any{
+		"Ledger":    l,
+		"Rows":      rows,
+		"Overspent": overspent,
+		"Ready":     fmtMoney(l.ReadyToAssignCents),
+		"Negative":  l.ReadyToAssignCents < 0,
+		"Inflow":    fmtMoney(l.InflowCents),
+		"Uncovered": fmtMoney(l.UncoveredCents),
+		"Assigned":  fmtMoney(l.AssignedCents),
+		"Entries":   log,
+		"Month":     month,
+		"Prev":      m.AddDate(0, -1, 0).Format("2006-01"),
+		"Next":      m.AddDate(0, 1, 0).Format("2006-01"),
+		"ThisMonth": thisMonth,
+
+		"Categories": cats,
+		"Message":    msg,
+	})
+}
+
+// handleAssignEnvelopes saves the assigned column: parallel cat/assigned
+// fields, one pair per envelope, plus an optional new envelope.
+func (a *App) handleAssignEnvelopes(w http.ResponseWriter, r *http.Request) {
+	_ = r.ParseForm()
+	month := r.FormValue("month")
+	cats, amounts := r.Form["cat"], r.Form["assigned"]
+	changed := 0
+	for i := 0; i < len(cats) && i < len(amounts); i++ {
+		if strings.TrimSpace(cats[i]) == "" || strings.TrimSpace(amounts[i]) == "" {
+			continue
+		}
+		cents, err := parseMoney(amounts[i])
+		if err != nil {
+			a.renderEnvelopes(w, r, cats[i]+": "+err.Error())
+			return
+		}
+		delta, err := envelope.Assign(r.Context(), a.DB, month, cats[i], cents)
+		if err != nil {
+			a.renderEnvelopes(w, r, err.Error())
+			return
+		}
+		if delta != 0 {
+			changed++
+		}
+	}
+	seeOther(w, r, "/envelopes", url.Values{"month": {month}}, fmt.Sprintf("%d envelopes updated", changed))
+}
+
+func (a *App) handleMoveMoney(w http.ResponseWriter, r *http.Request) {
+	cents, err := parseMoney(r.FormValue("amount"))
+	if err != nil {
+		a.renderEnvelopes(w, r, err.Error())
+		return
+	}
+	month, from, to := r.FormValue("month"), r.FormValue("from"), r.FormValue("to")
+	if err := envelope.Move(r.Context(), a.DB, month, from, to, cents, strings.TrimSpace(r.FormValue("note"))); err != nil {
+		a.renderEnvelopes(w, r, err.Error())
+		return
+	}
+	seeOther(w, r, "/envelopes", url.Values{"month": {month}}, fmt.Sprintf("moved %s from %s to %s", fmtMoney(cents), envelopeName(from), envelopeName(to)))
+}
+
+func envelopeName(cat string) string {
+	if cat == envelope.ReadyToAssign {
+		return "Ready to assign"
+	}
+	return cat
+}
@@ -0,0 +1,175 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anthurium-ai/personal-finance/internal/forecast"
+)
+
+// chartColours cycle across accounts in the forecast chart.
+var chartColours = []string{"#0b63ce", "#d97706", "#059669", "#7c3aed", "#dc2626", "#0891b2"}
+
+func (a *App) handleForecast(w http.ResponseWriter, r *http.Request) {
+	a.renderForecast(w, r, r.URL.Query().Get("msg"))
+}
+
+func (a *App) renderForecast(w http.ResponseWriter, r *http.Request, msg string) {
+	days, _ := strconv.Atoi(r.FormValue("days"))
+	if days < 7 || days > 366 {
+		days = 90
+	}
+	f, err := forecast.Build(r.Context(), a.DB, time.Now(), days)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+
+	type account struct {
+		Account   string
+		AsOf      string
+		Today     string
+		Floor     string
+		Lowest    string
+		LowestOn  string
+		Below     bool
+		Colour    string
+		FloorLine string // svg points
+		Line      string
+	}
+	// chart scale: every balance and floor fits between lo and hi
+	const width, height = 960.0, 240.0
+	lo, hi := int64(0), int64(0)
+	for i, acct := range f.Accounts {
+		lo, hi = min(lo, acct.FloorCents, acct.BalanceCents), max(hi, acct.FloorCents, acct.BalanceCents)
+		for _, d := range f.Days {
+			lo, hi = min(lo, d.Balances[i]), max(hi, d.Balances[i])
+		}
+	}
+	if hi == lo {
+		hi = lo + 100
+	}
+	y := func(c int64) float64 { return height - float64(c-lo)/float64(hi-lo)*height }
+	x := func(i int) float64 { return float64(i) / float64(max(len(f.Days)-1, 1)) * width }
+
+	below := map[string]bool{}
+	for _, wn := range f.Warnings {
+		below[wn.Account] = true
+	}
+	var accounts []account
+	for i, acct := range f.Accounts {
+		low, at := f.Lowest(i)
+		ac := account{
+			Account:  acct.Account,
+			AsOf:     acct.AsOf.Format("2006-01-02"),
+			Today:    fmtMoney(acct.BalanceCents),
+			Floor:    fmtMoney(acct.FloorCents),
+			Lowest:   fmtMoney(low),
+			LowestOn: at.Format("2006-01-02"),
+			Below:    below[acct.Account],
+			Colour:   chartColours[i%len(chartColours)],
+		}
+		var pts []string
+		for j, d := range f.Days {
+			pts = append(pts, fmt.Sprintf("%.1f,%.1f", x(j), y(d.Balances[i])))
+		}
+		ac.Line = strings.Join(pts, " ")
+		ac.FloorLine = fmt.Sprintf("0,%.1f %.1f,%.1f", y(acct.FloorCents), width, y(acct.FloorCents))
+		accounts = append(accounts, ac)
+	}
+
+	type cell struct {
+		Balance string
+		Below   bool
+	}
+	type day struct {
+		Date     string
+		Weekday  string
+		Items    []string
+		Balances []cell
+	}
+	var table []day
+	for _, d := range f.Days {
+		dd := day{Date: d.Date.Format("2006-01-02"), Weekday: d.Date.Format("Mon")}
+		// budgets are spread daily; one line for all of them keeps the table readable
+		var budgeted int64
+		for _, e := range d.Events {
+			if e.Source == forecast.SourceBudget {
+				budgeted += e.AmountCents
+				continue
+			}
+			dd.Items = append(dd.Items, fmt.Sprintf("%s %s", e.Label, fmtMoney(e.AmountCents)))
+		}
+		if budgeted != 0 {
+			dd.Items = append(dd.Items, "budgets "+fmtMoney(budgeted))
+		}
+		for i, b := range d.Balances {
+			dd.Balances = append(dd.Balances, cell{Balance: fmtMoney(b), Below: b < f.Accounts[i].FloorCents})
+		}
+		table = append(table, dd)
+	}
+
+	type warning struct {
+		Account, Date, Balance, Floor string
+	}
+	var warnings []warning
+	for _, wn := range f.Warnings {
+		warnings = append(warnings, warning{wn.Account, wn.Date.Format("2006-01-02"), fmtMoney(wn.BalanceCents), fmtMoney(wn.FloorCents)})
+	}
+
+	known, err := forecast.KnownAccounts(r.Context(), a.DB)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	a.Tmpl.Render(w, "forecast", map[string]any{
+		"Days":     days,
+		"Accounts": accounts,
+		"Table":    table,
+		"Warnings": warnings,
+		"Unplaced": len(f.Unplaced),
+		"Known":    known,
+		"Width":    width,
+		"Height":   height,
+		"ZeroY":    fmt.Sprintf("%.1f", y(0)),
+		"High":     fmtMoney(hi),
+		"Low":      fmtMoney(lo),
+		"Today":    time.Now().Format("2006-01-02"),
+		"Message":  msg,
+	})
+}
+
+func (a *App) handleSetAccountBalance(w http.ResponseWriter, r *http.Request) {
+	acct := forecast.Account{Account: r.FormValue("account")}
+	var err error
+	if acct.BalanceCents, err = parseMoney(r.FormValue("balance")); err != nil {
+		a.renderForecast(w, r, "balance: "+err.Error())
+		return
+	}
+	if s := strings.TrimSpace(r.FormValue("floor")); s != "" {
+		if acct.FloorCents, err = parseMoney(s); err != nil {
+			a.renderForecast(w, r, "floor: "+err.Error())
+			return
+		}
+	}
+	if acct.AsOf, err = time.Parse("2006-01-02", r.FormValue("as_of")); err != nil {
+		a.renderForecast(w, r, "balance date must be YYYY-MM-DD")
+		return
+	}
+	if err := forecast.SetAccount(r.Context(), a.DB, acct); err != nil {
+		a.renderForecast(w, r, err.Error())
+		return
+	}
+	seeOther(w, r, "/forecast", nil, fmt.Sprintf("%s: %s on %s", strings.TrimSpace(acct.Account), fmtMoney(acct.BalanceCents), acct.AsOf.Format("2006-01-02")))
+}
+
+func (a *App) handleDeleteAccountBalance(w http.ResponseWriter, r *http.Request) {
+	if err := forecast.DeleteAccount(r.Context(), a.DB, r.FormValue("account")); err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	seeOther(w, r, "/forecast", nil, "balance removed")
+}
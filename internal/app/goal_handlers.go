@@ -0,0 +1,130 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anthurium-ai/personal-finance/internal/goal"
+	"github.com/go-chi/chi/v5"
+)
+
+func (a *App) handleGoals(w http.ResponseWriter, r *http.Request) {
+	a.renderGoals(w, r, r.URL.Query().Get("msg"))
+}
+
+func (a *App) renderGoals(w http.ResponseWriter, r *http.Request, msg string) {
+	progress, err := goal.Track(r.Context(), a.DB, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	type row struct {
+		ID            int64
+		Name          string
+		Link          string
+		Target        string
+		TargetDate    string
+		Saved         string
+		Remaining     string
+		Percent       string
+		Bar           int // 0-100, for the progress bar
+		Contributions int
+		Required      string
+		Pace          string
+		Projected     string
+		Done          bool
+		OnTrack       bool
+	}
+	var rows []row
+	for _, p := range progress {
+		rw := row{
+			ID:            p.ID,
+			Name:          p.Name,
+			Link:          "account " + p.Account,
+			Target:        fmtMoney(p.TargetCents),
+			TargetDate:    p.TargetDate.Format("2006-01-02"),
+			Saved:         fmtMoney(p.SavedCents),
+			Remaining:     fmtMoney(p.RemainingCents),
+			Percent:       fmt.Sprintf("%.0f%%", 100*p.Ratio()),
+			Bar:           min(max(int(100*p.Ratio()), 0), 100),
+			Contributions: p.Contributions,
+			Required:      fmtMoney(p.RequiredMonthlyCents),
+			Pace:          fmtMoney(p.PaceMonthlyCents),
+			Done:          p.Done(),
+			OnTrack:       p.OnTrack(),
+		}
+		if p.Tag != "" {
+			rw.Link = "#" + p.Tag
+		}
+		if !p.Projected.IsZero() {
+			rw.Projected = p.Projected.Format("2006-01-02")
+		}
+		rows = append(rows, rw)
+	}
+
+	var accounts []string
+	arows, err := a.DB.QueryContext(r.Context(), `SELECT DISTINCT account FROM transactions WHERE COALESCE(account,'') != '' ORDER BY account`)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	for arows.Next() {
+		var acct string
+		_ = arows.Scan(&acct)
+		accounts = append(accounts, acct)
+	}
+	arows.Close()
+
+	a.Tmpl.Render(w, "goals", map[string]any{
+		"Rows":     rows,
+		"Accounts": accounts,
+		"Today":    time.Now().Format("2006-01-02"),
+		"Message":  msg,
+	})
+}
+
+func (a *App) handleAddGoal(w http.ResponseWriter, r *http.Request) {
+	g := goal.Goal{
+		Name:    r.FormValue("name"),
+		Account: r.FormValue("account"),
+		Tag:     r.FormValue("tag"),
+	}
+	var err error
+	if g.TargetCents, err = parseMoney(r.FormValue("target")); err != nil {
+		a.renderGoals(w, r, "target: "+err.Error())
+		return
+	}
+	if s := strings.TrimSpace(r.FormValue("opening")); s != "" {
+		if g.OpeningCents, err = parseMoney(s); err != nil {
+			a.renderGoals(w, r, "already saved: "+err.Error())
+			return
+		}
+	}
+	if g.TargetDate, err = time.Parse("2006-01-02", r.FormValue("target_date")); err != nil {
+		a.renderGoals(w, r, "target date must be YYYY-MM-DD")
+		return
+	}
+	if s := strings.TrimSpace(r.FormValue("start_date")); s != "" {
+		if g.StartDate, err = time.Parse("2006-01-02", s); err != nil {
+			a.renderGoals(w, r, "start date must be YYYY-MM-DD")
+			return
+		}
+	}
+	if _, err := goal.Create(r.Context(), a.DB, g); err != nil {
+		a.renderGoals(w, r, err.Error())
+		return
+	}
+	seeOther(w, r, "/goals", nil, fmt.Sprintf("goal %q added", strings.TrimSpace(g.Name)))
+}
+
+func (a *App) handleDeleteGoal(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err := goal.Delete(r.Context(), a.DB, id); err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	seeOther(w, r, "/goals", nil, "goal deleted")
+}
@@ -0,0 +1,105 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/anthurium-ai/personal-finance/internal/classify"
+	"github.com/go-chi/chi/v5"
+)
+
+func (a *App) handleLLM(w http.ResponseWriter, r *http.Request) {
+	a.renderLLM(w, r, "")
+}
+
+// renderLLM shows the provider, the suggestion cache, redaction terms and
+// the audit log of what was sent.
+func (a *App) renderLLM(w http.ResponseWriter, r *http.Request, msg string) {
+	ctx := r.Context()
+	provider := "disabled"
+	if a.LLM != nil {
+		provider = a.LLM.Name()
+	}
+	var cached int
+	_ = a.DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM llm_cache WHERE prompt_version=?`, classify.PromptVersion).Scan(&cached)
+
+	rows, err := a.DB.QueryContext(ctx, `SELECT id, term FROM redaction_terms ORDER BY term`)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	defer rows.Close()
+	type term struct {
+		ID   int64
+		Term string
+	}
+	var terms []term
+	for rows.Next() {
+		var t term
+		_ = rows.Scan(&t.ID, &t.Term)
+		terms = append(terms, t)
+	}
+
+	audit, err := classify.RecentAudit(ctx, a.DB, 20)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+
+	// preview the redaction on a sample so the effect of new terms is visible
+	sample := strings.TrimSpace(r.FormValue("sample"))
+	var redacted string
+	if sample != "" {
+		red, err := classify.LoadRedactor(ctx, a.DB)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		redacted = red.Redact(sample)
+	}
+
+	a.Tmpl.Render(w, "llm", map[string]any{
+		"Provider":      provider,
+		"PromptVersion": classify.PromptVersion,
+		"Cached":        cached,
+		"Terms":         terms,
+		"Audit":         audit,
+		"Sample":        sample,
+		"Redacted":      redacted,
+		"Message":       msg,
+	})
+}
+
+func (a *App) handleClearLLMCache(w http.ResponseWriter, r *http.Request) {
+	n, err := classify.ClearLLMCache(r.Context(), a.DB)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	a.renderLLM(w, r, fmt.Sprintf("cleared %d cached suggestions", n))
+}
+
+func (a *App) handleAddRedactionTerm(w http.ResponseWriter, r *http.Request) {
+	_ = r.ParseForm()
+	t := strings.TrimSpace(r.FormValue("term"))
+	if t == "" {
+		a.renderLLM(w, r, "term is required")
+		return
+	}
+	if _, err := a.DB.ExecContext(r.Context(), `INSERT INTO redaction_terms (term) VALUES (?) ON CONFLICT(term) DO NOTHING`, t); err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	http.Redirect(w, r, "/llm", http.StatusSeeOther)
+}
+
+func (a *App) handleDeleteRedactionTerm(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if _, err := a.DB.ExecContext(r.Context(), `DELETE FROM redaction_terms WHERE id=?`, id); err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	http.Redirect(w, r, "/llm", http.StatusSeeOther)
+}
@@ -0,0 +1,289 @@
+package app
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anthurium-ai/personal-finance/internal/forecast"
+	"github.com/anthurium-ai/personal-finance/internal/loan"
+	"github.com/go-chi/chi/v5"
+)
+
+func fmtRate(pct float64) string { return strconv.FormatFloat(pct, 'f', -1, 64) + "%" }
+
+func parseRate(s string) (float64, error) {
+	pct, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(s), "%"), 64)
+	if err != nil {
+		return 0, fmt.Errorf("not a rate: %q", s)
+	}
+	return pct, nil
+}
+
+// fmtPayoff is when a projection pays the loan off.
+func fmtPayoff(p loan.Projection) string {
+	if !p.PaidOff {
+		return "never"
+	}
+	return p.Payoff.Format("2006-01-02")
+}
+
+func (a *App) handleLoans(w http.ResponseWriter, r *http.Request) {
+	a.renderLoans(w, r, r.URL.Query().Get("msg"))
+}
+
+func (a *App) renderLoans(w http.ResponseWriter, r *http.Request, msg string) {
+	ctx := r.Context()
+	loans, err := loan.List(ctx, a.DB)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	type row struct {
+		ID         int64
+		Name       string
+		Frequency  string
+		Rate       string
+		Balance    string
+		Estimated  bool
+		Repayment  string
+		Payoff     string
+		Interest   string
+		Saved      string
+		Repayments int
+	}
+	var rows []row
+	for _, l := range loans {
+		s, err := loan.Track(ctx, a.DB, l, time.Now(), 0)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		rw := row{
+			ID:         l.ID,
+			Name:       l.Name,
+			Frequency:  l.Frequency,
+			Rate:       fmtRate(s.RatePct),
+			Balance:    fmtMoney(s.BalanceCents),
+			Estimated:  s.Estimated,
+			Repayment:  fmtMoney(s.RepaymentCents),
+			Payoff:     fmtPayoff(s.Current),
+			Interest:   fmtMoney(s.Current.InterestCents),
+			Repayments: len(s.Repayments),
+		}
+		if saved := s.SavedCents(); saved > 0 {
+			rw.Saved = fmtMoney(saved)
+		}
+		rows = append(rows, rw)
+	}
+	accounts, _ := forecast.KnownAccounts(ctx, a.DB)
+	a.Tmpl.Render(w, "loans", map[string]any{
+		"Loans":       rows,
+		"Frequencies": loan.Frequencies,
+		"Accounts":    accounts,
+		"Today":       time.Now().Format("2006-01-02"),
+		"Message":     msg,
+	})
+}
+
+func (a *App) handleAddLoan(w http.ResponseWriter, r *http.Request) {
+	l := loan.Loan{
+		Name:          r.FormValue("name"),
+		Frequency:     r.FormValue("frequency"),
+		MatchText:     r.FormValue("match_text"),
+		Account:       r.FormValue("account"),
+		OffsetAccount: r.FormValue("offset_account"),
+	}
+	var err error
+	if l.PrincipalCents, err = parseMoney(r.FormValue("principal")); err != nil {
+		a.renderLoans(w, r, "principal: "+err.Error())
+		return
+	}
+	if s := strings.TrimSpace(r.FormValue("repayment")); s != "" {
+		if l.RepaymentCents, err = parseMoney(s); err != nil {
+			a.renderLoans(w, r, "repayment: "+err.Error())
+			return
+		}
+	}
+	rate, err := parseRate(r.FormValue("rate"))
+	if err != nil {
+		a.renderLoans(w, r, "rate: "+err.Error())
+		return
+	}
+	years, _ := strconv.Atoi(r.FormValue("years"))
+	months, _ := strconv.Atoi(r.FormValue("months"))
+	l.TermMonths = years*12 + months
+	if l.Start, err = time.Parse("2006-01-02", r.FormValue("start")); err != nil {
+		a.renderLoans(w, r, "start date must be YYYY-MM-DD")
+		return
+	}
+	if _, err := loan.Create(r.Context(), a.DB, l, rate); err != nil {
+		a.renderLoans(w, r, err.Error())
+		return
+	}
+	seeOther(w, r, "/loans", nil, fmt.Sprintf("loan %q added", strings.TrimSpace(l.Name)))
+}
+
+func (a *App) handleDeleteLoan(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err := loan.Delete(r.Context(), a.DB, id); err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	seeOther(w, r, "/loans", nil, "loan deleted")
+}
+
+func (a *App) handleLoan(w http.ResponseWriter, r *http.Request) {
+	a.renderLoan(w, r, r.URL.Query().Get("msg"))
+}
+
+// renderLoan shows a loan's repayments so far and its schedule from here,
+// with a what-if for ?extra= on top of each repayment.
+func (a *App) renderLoan(w http.ResponseWriter, r *http.Request, msg string) {
+	ctx := r.Context()
+	id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	l, err := loan.Get(ctx, a.DB, id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "loan not found", 404)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	var extra int64
+	if s := strings.TrimSpace(r.FormValue("extra")); s != "" {
+		if extra, err = parseMoney(s); err != nil || extra < 0 {
+			msg, extra = "extra must be an amount", 0
+		}
+	}
+	s, err := loan.Track(ctx, a.DB, l, time.Now(), extra)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+
+	type rate struct {
+		From string
+		Rate string
+	}
+	var rates []rate
+	for _, rt := range s.Rates {
+		rates = append(rates, rate{rt.From.Format("2006-01-02"), fmtRate(rt.Percent)})
+	}
+	type repayment struct {
+		TxID      int64
+		Date      string
+		Amount    string
+		Offset    string
+		Interest  string
+		Principal string
+		Balance   string
+	}
+	var repayments []repayment
+	for _, sp := range s.Repayments {
+		repayments = append(repayments, repayment{sp.TxID, sp.Date.Format("2006-01-02"), fmtMoney(sp.AmountCents), fmtMoney(sp.OffsetCents),
+			fmtMoney(sp.InterestCents), fmtMoney(sp.PrincipalCents), fmtMoney(sp.BalanceCents)})
+	}
+	type period struct {
+		N         int
+		Date      string
+		Rate      string
+		Repayment string
+		Interest  string
+		Principal string
+		Balance   string
+	}
+	sched := s.Current
+	if extra > 0 {
+		sched = s.WhatIf
+	}
+	var schedule []period
+	for _, p := range sched.Periods {
+		schedule = append(schedule, period{p.N, p.Date.Format("2006-01-02"), fmtRate(p.RatePct), fmtMoney(p.RepaymentCents),
+			fmtMoney(p.InterestCents), fmtMoney(p.PrincipalCents), fmtMoney(p.BalanceCents)})
+	}
+	type projection struct {
+		Name     string
+		Payoff   string
+		Interest string
+		Total    string
+	}
+	projections := []projection{
+		{"Minimum repayments, no offset", fmtPayoff(s.Minimum), fmtMoney(s.Minimum.InterestCents), fmtMoney(s.Minimum.TotalCents)},
+		{"As repaid, with today's offset", fmtPayoff(s.Current), fmtMoney(s.Current.InterestCents), fmtMoney(s.Current.TotalCents)},
+	}
+	if extra > 0 {
+		projections = append(projections, projection{"With " + fmtMoney(extra) + " extra each repayment", fmtPayoff(s.WhatIf), fmtMoney(s.WhatIf.InterestCents), fmtMoney(s.WhatIf.TotalCents)})
+	}
+
+	a.Tmpl.Render(w, "loan", map[string]any{
+		"Loan":          l,
+		"Start":         l.Start.Format("2006-01-02"),
+		"Principal":     fmtMoney(l.PrincipalCents),
+		"Repayment":     fmtMoney(s.RepaymentCents),
+		"Rate":          fmtRate(s.RatePct),
+		"Rates":         rates,
+		"Balance":       fmtMoney(s.BalanceCents),
+		"AsOf":          s.AsOf.Format("2006-01-02"),
+		"Estimated":     s.Estimated,
+		"InterestPaid":  fmtMoney(s.InterestPaidCents),
+		"PrincipalPaid": fmtMoney(s.PrincipalPaidCents),
+		"Offset":        fmtMoney(s.OffsetCents),
+		"Minimum":       fmtMoney(s.MinimumCents),
+		"Projections":   projections,
+		"Saved":         fmtMoney(s.SavedCents()),
+		"Extra":         r.FormValue("extra"),
+		"WhatIfSaved":   fmtMoney(s.WhatIfSavedCents()),
+		"ShowWhatIf":    extra > 0,
+		"Repayments":    repayments,
+		"Schedule":      schedule,
+		"Today":         time.Now().Format("2006-01-02"),
+		"Message":       msg,
+	})
+}
+
+func (a *App) handleSetLoanRate(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	pct, err := parseRate(r.FormValue("rate"))
+	if err != nil {
+		a.renderLoan(w, r, err.Error())
+		return
+	}
+	from, err := time.Parse("2006-01-02", r.FormValue("from"))
+	if err != nil {
+		a.renderLoan(w, r, "rate date must be YYYY-MM-DD")
+		return
+	}
+	if err := loan.SetRate(r.Context(), a.DB, id, from, pct); err != nil {
+		a.renderLoan(w, r, err.Error())
+		return
+	}
+	seeOther(w, r, "/loans/"+strconv.FormatInt(id, 10), nil, fmt.Sprintf("%s from %s", fmtRate(pct), from.Format("2006-01-02")))
+}
+
+func (a *App) handleDeleteLoanRate(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	from, err := time.Parse("2006-01-02", r.FormValue("from"))
+	if err != nil {
+		http.Error(w, "bad date", 400)
+		return
+	}
+	rates, err := loan.Rates(r.Context(), a.DB, id)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	if len(rates) == 1 {
+		a.renderLoan(w, r, "a loan needs at least one rate")
+		return
+	}
+	if err := loan.DeleteRate(r.Context(), a.DB, id, from); err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	seeOther(w, r, "/loans/"+strconv.FormatInt(id, 10), nil, "rate removed")
+}
@@ -0,0 +1,236 @@
+package app
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/anthurium-ai/personal-finance/internal/merchant"
+	"github.com/go-chi/chi/v5"
+)
+
+func (a *App) handleAliases(w http.ResponseWriter, r *http.Request) {
+	a.renderAliases(w, r, "", nil)
+}
+
+func (a *App) renderAliases(w http.ResponseWriter, r *http.Request, msg string, res *merchant.RenormaliseResult) {
+	rows, err := a.DB.QueryContext(r.Context(), `SELECT id, alias, merchant_norm, exact FROM merchant_aliases ORDER BY merchant_norm, alias`)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	defer rows.Close()
+	type row struct {
+		ID       int64
+		Alias    string
+		Merchant string
+		Exact    bool
+	}
+	var out []row
+	for rows.Next() {
+		var rw row
+		_ = rows.Scan(&rw.ID, &rw.Alias, &rw.Merchant, &rw.Exact)
+		out = append(out, rw)
+	}
+	a.Tmpl.Render(w, "aliases", map[string]any{"Rows": out, "Message": msg, "Result": res})
+}
+
+func (a *App) handleAddAlias(w http.ResponseWriter, r *http.Request) {
+	_ = r.ParseForm()
+	alias := strings.TrimSpace(r.FormValue("alias"))
+	mer := strings.TrimSpace(r.FormValue("merchant_norm"))
+	if alias == "" || mer == "" {
+		a.renderAliases(w, r, "alias and merchant are required", nil)
+		return
+	}
+	_, err := a.DB.ExecContext(r.Context(), `INSERT INTO merchant_aliases (alias, merchant_norm) VALUES (?,?) ON CONFLICT(alias) DO UPDATE SET merchant_norm=excluded.merchant_norm, exact=0`, alias, mer)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	http.Redirect(w, r, "/aliases", http.StatusSeeOther)
+}
+
+func (a *App) handleDeleteAlias(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if _, err := a.DB.ExecContext(r.Context(), `DELETE FROM merchant_aliases WHERE id=?`, id); err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	http.Redirect(w, r, "/aliases", http.StatusSeeOther)
+}
+
+func (a *App) handleRenormalise(w http.ResponseWriter, r *http.Request) {
+	_ = r.ParseForm()
+	dryRun := r.FormValue("dry_run") != ""
+	res, err := merchant.Renormalise(r.Context(), a.DB, dryRun)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	verb := "changed"
+	if dryRun {
+		verb = "would change"
+	}
+	msg := fmt.Sprintf("scanned=%d %s=%d manual-skipped=%d overrides-moved=%d", res.Scanned, verb, res.Changed, res.SkippedManual, res.OverridesMoved)
+	a.renderAliases(w, r, msg, res)
+}
+
+// merchantName reads the {name} path segment, which templates path-escape.
+func merchantName(r *http.Request) string {
+	name, err := url.PathUnescape(chi.URLParam(r, "name"))
+	if err != nil {
+		return chi.URLParam(r, "name")
+	}
+	return name
+}
+
+func (a *App) handleMerchants(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	rows, err := a.DB.QueryContext(r.Context(), `
+		SELECT t.merchant_norm, COUNT(*),
+		       SUM(CASE WHEN t.amount_cents < 0 THEN -t.amount_cents ELSE 0 END) AS spend,
+		       MAX(t.txn_date), COALESCE(o.category_norm,'')
+		FROM transactions t
+		LEFT JOIN merchant_category_overrides o ON o.merchant_norm = t.merchant_norm
+		WHERE COALESCE(t.merchant_norm,'') != '' AND (? = '' OR t.merchant_norm LIKE '%' || ? || '%')
+		GROUP BY t.merchant_norm
+		ORDER BY spend DESC
+		LIMIT 500`, q, q)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	defer rows.Close()
+	type row struct {
+		Name     string
+		Count    int
+		Spend    string
+		LastSeen string
+		Override string
+	}
+	var out []row
+	for rows.Next() {
+		var rw row
+		var spend int64
+		_ = rows.Scan(&rw.Name, &rw.Count, &spend, &rw.LastSeen, &rw.Override)
+		rw.Spend = fmtMoney(spend)
+		out = append(out, rw)
+	}
+	a.Tmpl.Render(w, "merchants", map[string]any{"Rows": out, "Q": q})
+}
+
+func (a *App) handleMerchant(w http.ResponseWriter, r *http.Request) {
+	a.renderMerchant(w, r, merchantName(r), "")
+}
+
+func (a *App) renderMerchant(w http.ResponseWriter, r *http.Request, name, msg string) {
+	ctx := r.Context()
+	type summary struct {
+		Count     int
+		Spend     string
+		AvgTicket string
+		FirstSeen string
+		LastSeen  string
+		Override  string
+	}
+	var s summary
+	var spend, avg int64
+	var first, last sql.NullString
+	err := a.DB.QueryRowContext(ctx, `
+		SELECT COUNT(*),
+		       COALESCE(SUM(CASE WHEN amount_cents < 0 THEN -amount_cents ELSE 0 END),0),
+		       COALESCE(CAST(AVG(CASE WHEN amount_cents < 0 THEN -amount_cents END) AS INTEGER),0),
+		       MIN(txn_date), MAX(txn_date)
+		FROM transactions WHERE merchant_norm=?`, name).Scan(&s.Count, &spend, &avg, &first, &last)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	if s.Count == 0 && msg == "" {
+		http.Error(w, "merchant not found", 404)
+		return
+	}
+	s.Spend, s.AvgTicket, s.FirstSeen, s.LastSeen = fmtMoney(spend), fmtMoney(avg), first.String, last.String
+	_ = a.DB.QueryRowContext(ctx, `SELECT category_norm FROM merchant_category_overrides WHERE merchant_norm=?`, name).Scan(&s.Override)
+
+	type month struct {
+		Month string
+		Count int
+		Spend string
+	}
+	var months []month
+	mrows, err := a.DB.QueryContext(ctx, `
+		SELECT substr(txn_date,1,7) AS m, COUNT(*), SUM(CASE WHEN amount_cents < 0 THEN -amount_cents ELSE 0 END)
+		FROM transactions WHERE merchant_norm=?
+		GROUP BY m ORDER BY m DESC`, name)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	for mrows.Next() {
+		var m month
+		var sp int64
+		_ = mrows.Scan(&m.Month, &m.Count, &sp)
+		m.Spend = fmtMoney(sp)
+		months = append(months, m)
+	}
+	mrows.Close()
+
+	var aliases []string
+	arows, err := a.DB.QueryContext(ctx, `SELECT alias FROM merchant_aliases WHERE merchant_norm=? ORDER BY alias`, name)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	for arows.Next() {
+		var al string
+		_ = arows.Scan(&al)
+		aliases = append(aliases, al)
+	}
+	arows.Close()
+
+	type txRow struct {
+		ID      int64
+		Date    string
+		Amount  string
+		Cat     string
+		Raw     string
+		Details string
+	}
+	var txs []txRow
+	trows, err := a.DB.QueryContext(ctx, `
+		SELECT id, txn_date, amount_cents, COALESCE(category_norm,''), COALESCE(merchant_raw,''), COALESCE(details,'')
+		FROM transactions WHERE merchant_norm=?
+		ORDER BY txn_date DESC, id DESC`, name)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	for trows.Next() {
+		var t txRow
+		var amt int64
+		_ = trows.Scan(&t.ID, &t.Date, &amt, &t.Cat, &t.Raw, &t.Details)
+		t.Amount = fmtMoney(amt)
+		txs = append(txs, t)
+	}
+	trows.Close()
+
+	a.Tmpl.Render(w, "merchant", map[string]any{
+		"Name": name, "Summary": s, "Months": months, "Aliases": aliases, "Txs": txs, "Message": msg,
+	})
+}
+
+func (a *App) handleRenameMerchant(w http.ResponseWriter, r *http.Request) {
+	from := merchantName(r)
+	_ = r.ParseForm()
+	to := strings.TrimSpace(r.FormValue("to"))
+	if _, err := merchant.Rename(r.Context(), a.DB, from, to); err != nil {
+		a.renderMerchant(w, r, from, "rename failed: "+err.Error())
+		return
+	}
+	http.Redirect(w, r, "/merchants/"+url.PathEscape(to), http.StatusSeeOther)
+}
@@ -0,0 +1,218 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anthurium-ai/personal-finance/internal/networth"
+	"github.com/go-chi/chi/v5"
+)
+
+// historyMonths is how far back the net worth history goes.
+const historyMonths = 24
+
+func (a *App) handleNetWorth(w http.ResponseWriter, r *http.Request) {
+	a.renderNetWorth(w, r, r.URL.Query().Get("msg"))
+}
+
+func (a *App) renderNetWorth(w http.ResponseWriter, r *http.Request, msg string) {
+	ctx := r.Context()
+	today := time.Now()
+	snaps, err := networth.At(ctx, a.DB, networth.MonthEnds(today, historyMonths)...)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	now := snaps[len(snaps)-1]
+
+	type line struct {
+		Name     string
+		Kind     string
+		Value    string
+		ValuedOn string
+		ItemID   int64
+	}
+	lines := func(ls []networth.Line) []line {
+		var out []line
+		for _, l := range ls {
+			ln := line{Name: l.Name, Kind: l.Kind, Value: fmtMoney(l.ValueCents), ItemID: l.ItemID}
+			if !l.ValuedOn.IsZero() {
+				ln.ValuedOn = l.ValuedOn.Format("2006-01-02")
+			}
+			out = append(out, ln)
+		}
+		return out
+	}
+
+	// history, newest first, and a chart of net worth over it
+	const width, height = 960.0, 200.0
+	lo, hi := int64(0), int64(0)
+	for _, s := range snaps {
+		lo, hi = min(lo, s.NetCents()), max(hi, s.NetCents())
+	}
+	if hi == lo {
+		hi = lo + 100
+	}
+	y := func(c int64) float64 { return height - float64(c-lo)/float64(hi-lo)*height }
+	x := func(i int) float64 { return float64(i) / float64(max(len(snaps)-1, 1)) * width }
+	type month struct {
+		Date        string
+		Accounts    string
+		Assets      string
+		Liabilities string
+		Net         string
+		Change      string
+	}
+	var history []month
+	var pts []string
+	for i, s := range snaps {
+		pts = append(pts, fmt.Sprintf("%.1f,%.1f", x(i), y(s.NetCents())))
+		m := month{
+			Date:        s.Date.Format("2006-01-02"),
+			Accounts:    fmtMoney(s.AccountsCents()),
+			Assets:      fmtMoney(s.AssetsCents()),
+			Liabilities: fmtMoney(s.LiabilitiesCents()),
+			Net:         fmtMoney(s.NetCents()),
+		}
+		if i > 0 {
+			m.Change = fmtMoney(s.NetCents() - snaps[i-1].NetCents())
+		}
+		history = append([]month{m}, history...)
+	}
+
+	items, err := networth.Items(ctx, a.DB)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	vals, err := networth.Valuations(ctx, a.DB)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	names := map[int64]networth.Item{}
+	for _, it := range items {
+		names[it.ID] = it
+	}
+	type valuation struct {
+		ItemID int64
+		Name   string
+		Date   string
+		Value  string
+		Note   string
+	}
+	var valuations []valuation
+	for _, v := range vals {
+		it := names[v.ItemID]
+		val := v.ValueCents
+		if it.Liability {
+			val = -val
+		}
+		valuations = append(valuations, valuation{v.ItemID, it.Name, v.Date.Format("2006-01-02"), fmtMoney(val), v.Note})
+	}
+
+	a.Tmpl.Render(w, "networth", map[string]any{
+		"Net":            fmtMoney(now.NetCents()),
+		"Accounts":       lines(now.Accounts),
+		"AccountsTotal":  fmtMoney(now.AccountsCents()),
+		"Assets":         lines(now.Assets),
+		"AssetsTotal":    fmtMoney(now.AssetsCents()),
+		"Liabilities":    lines(now.Liabilities),
+		"LiabilityTotal": fmtMoney(now.LiabilitiesCents()),
+		"History":        history,
+		"Line":           strings.Join(pts, " "),
+		"Width":          width,
+		"Height":         height,
+		"ZeroY":          fmt.Sprintf("%.1f", y(0)),
+		"High":           fmtMoney(hi),
+		"Low":            fmtMoney(lo),
+		"From":           snaps[0].Date.Format("2006-01-02"),
+		"Items":          items,
+		"Valuations":     valuations,
+		"AssetKinds":     networth.AssetKinds,
+		"LiabilityKinds": networth.LiabilityKinds,
+		"Today":          today.Format("2006-01-02"),
+		"Message":        msg,
+	})
+}
+
+func (a *App) handleAddNetWorthItem(w http.ResponseWriter, r *http.Request) {
+	it := networth.Item{Name: r.FormValue("name"), Liability: r.FormValue("side") == "liability"}
+	it.Kind = r.FormValue("asset_kind")
+	if it.Liability {
+		it.Kind = r.FormValue("liability_kind")
+	}
+	// an opening value is optional; the item counts from its first valuation
+	var v *networth.Valuation
+	if s := strings.TrimSpace(r.FormValue("value")); s != "" {
+		v = &networth.Valuation{}
+		var err error
+		if v.ValueCents, err = parseMoney(s); err != nil {
+			a.renderNetWorth(w, r, "value: "+err.Error())
+			return
+		}
+		if v.Date, err = time.Parse("2006-01-02", r.FormValue("valued_on")); err != nil {
+			a.renderNetWorth(w, r, "valuation date must be YYYY-MM-DD")
+			return
+		}
+	}
+	id, err := networth.CreateItem(r.Context(), a.DB, it)
+	if err != nil {
+		a.renderNetWorth(w, r, err.Error())
+		return
+	}
+	if v != nil {
+		v.ItemID = id
+		if err := networth.Value(r.Context(), a.DB, *v); err != nil {
+			a.renderNetWorth(w, r, err.Error())
+			return
+		}
+	}
+	seeOther(w, r, "/networth", nil, "added "+strings.TrimSpace(it.Name))
+}
+
+func (a *App) handleDeleteNetWorthItem(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err := networth.DeleteItem(r.Context(), a.DB, id); err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	seeOther(w, r, "/networth", nil, "deleted")
+}
+
+func (a *App) handleAddValuation(w http.ResponseWriter, r *http.Request) {
+	var v networth.Valuation
+	var err error
+	v.ItemID, _ = strconv.ParseInt(r.FormValue("item"), 10, 64)
+	if v.ValueCents, err = parseMoney(r.FormValue("value")); err != nil {
+		a.renderNetWorth(w, r, "value: "+err.Error())
+		return
+	}
+	if v.Date, err = time.Parse("2006-01-02", r.FormValue("valued_on")); err != nil {
+		a.renderNetWorth(w, r, "valuation date must be YYYY-MM-DD")
+		return
+	}
+	v.Note = r.FormValue("note")
+	if err := networth.Value(r.Context(), a.DB, v); err != nil {
+		a.renderNetWorth(w, r, err.Error())
+		return
+	}
+	seeOther(w, r, "/networth", nil, fmt.Sprintf("valued at %s on %s", fmtMoney(v.ValueCents), v.Date.Format("2006-01-02")))
+}
+
+func (a *App) handleDeleteValuation(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(r.FormValue("item"), 10, 64)
+	date, err := time.Parse("2006-01-02", r.FormValue("valued_on"))
+	if err != nil {
+		http.Error(w, "bad date", 400)
+		return
+	}
+	if err := networth.DeleteValuation(r.Context(), a.DB, id, date); err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	seeOther(w, r, "/networth", nil, "valuation removed")
+}
@@ -0,0 +1,91 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/anthurium-ai/personal-finance/internal/classify"
+	"github.com/go-chi/chi/v5"
+)
+
+type reviewRow struct {
+	classify.PendingSuggestion
+	Amount     string
+	Confidence string
+}
+
+func (a *App) handleReview(w http.ResponseWriter, r *http.Request) {
+	a.renderReview(w, r, "")
+}
+
+func (a *App) renderReview(w http.ResponseWriter, r *http.Request, msg string) {
+	source := strings.TrimSpace(r.FormValue("source"))
+	list, err := classify.Pending(r.Context(), a.DB, source, 500)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	rows := make([]reviewRow, 0, len(list))
+	for _, p := range list {
+		rows = append(rows, reviewRow{PendingSuggestion: p, Amount: fmtMoney(p.AmountCents), Confidence: fmtConfidence(p.Confidence)})
+	}
+	rules, err := classify.RejectedRules(r.Context(), a.DB)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	cats, _ := classify.KnownCategories(r.Context(), a.DB)
+	a.Tmpl.Render(w, "review", map[string]any{
+		"Rows":       rows,
+		"Rules":      rules,
+		"Source":     source,
+		"Sources":    []string{classify.SourceOverride, classify.SourceRule, classify.SourceModel, classify.SourceLLM},
+		"Categories": cats,
+		"Message":    msg,
+	})
+}
+
+// handleDecideReview accepts or rejects the selected suggestions. An edited
+// category (cat_<id>) turns an accept into a manual change.
+func (a *App) handleDecideReview(w http.ResponseWriter, r *http.Request) {
+	_ = r.ParseForm()
+	accept := r.FormValue("action") == "accept"
+	var decisions []classify.Decision
+	for _, s := range r.Form["id"] {
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			continue
+		}
+		decisions = append(decisions, classify.Decision{ID: id, Accept: accept, Category: r.FormValue("cat_" + s)})
+	}
+	if len(decisions) == 0 {
+		a.renderReview(w, r, "nothing selected")
+		return
+	}
+	res, err := classify.Review(r.Context(), a.DB, decisions)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	a.renderReview(w, r, fmt.Sprintf("accepted=%d edited=%d rejected=%d skipped=%d", res.Accepted, res.Edited, res.Rejected, res.Skipped))
+}
+
+func (a *App) handleQueueSuggestions(w http.ResponseWriter, r *http.Request) {
+	n, err := classify.QueueSuggestions(r.Context(), a.DB)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	a.renderReview(w, r, fmt.Sprintf("%d suggestions queued from overrides, rules and the local model", n))
+}
+
+func (a *App) handleDisableRule(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err := classify.DisableRule(r.Context(), a.DB, id); err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	http.Redirect(w, r, "/review", http.StatusSeeOther)
+}
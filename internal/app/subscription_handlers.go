@@ -0,0 +1,65 @@
+package app
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/anthurium-ai/personal-finance/internal/recurring"
+)
+
+func (a *App) handleSubscriptions(w http.ResponseWriter, r *http.Request) {
+	all, err := recurring.Detect(r.Context(), a.DB, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	type change struct {
+		Date     string
+		From, To string
+	}
+	type row struct {
+		Merchant     string
+		Category     string
+		Cadence      string
+		Charges      int
+		First, Last  string
+		Typical      string
+		Annual       string
+		NextExpected string
+		Missed       bool
+		Changed      bool
+		Changes      []change
+	}
+	var active, ended []row
+	var annual int64
+	for _, s := range all {
+		rw := row{
+			Merchant:     s.Merchant,
+			Category:     s.Category,
+			Cadence:      s.Cadence.Name,
+			Charges:      len(s.Charges),
+			First:        s.First().Format("2006-01-02"),
+			Last:         s.Last().Format("2006-01-02"),
+			Typical:      fmtMoney(s.TypicalCents),
+			Annual:       fmtMoney(s.AnnualCents()),
+			NextExpected: s.NextExpected.Format("2006-01-02"),
+			Missed:       s.Missed,
+			Changed:      s.Changed,
+		}
+		for _, pc := range s.PriceChanges {
+			rw.Changes = append(rw.Changes, change{Date: pc.Date.Format("2006-01-02"), From: fmtMoney(pc.FromCents), To: fmtMoney(pc.ToCents)})
+		}
+		if s.Ended {
+			ended = append(ended, rw)
+			continue
+		}
+		active = append(active, rw)
+		annual += s.AnnualCents()
+	}
+	a.Tmpl.Render(w, "subscriptions", map[string]any{
+		"Active":  active,
+		"Ended":   ended,
+		"Annual":  fmtMoney(annual),
+		"Monthly": fmtMoney(annual / 12),
+	})
+}
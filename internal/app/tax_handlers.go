@@ -0,0 +1,251 @@
+package app
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anthurium-ai/personal-finance/internal/attachment"
+	"github.com/anthurium-ai/personal-finance/internal/classify"
+	"github.com/anthurium-ai/personal-finance/internal/tax"
+	"github.com/go-chi/chi/v5"
+)
+
+type taxItem struct {
+	TxID       int64
+	Date       string
+	Amount     string
+	GST        string
+	Merchant   string
+	Details    string
+	Category   string
+	Flagged    bool
+	Receipt    string
+	ReceiptURL string // when the receipt is a link
+	Note       string
+	Files      []attachmentView
+}
+
+type taxTotal struct {
+	Key        string
+	Label      string
+	Count      int
+	Total      string
+	GST        string
+	Unattached int // items without an attached receipt
+	Items      []taxItem
+}
+
+// taxYear is ?fy=, defaulting to the current financial year.
+func taxYear(r *http.Request) (tax.Year, error) {
+	if s := r.FormValue("fy"); s != "" {
+		return tax.ParseYear(s)
+	}
+	return tax.YearOf(time.Now()), nil
+}
+
+// taxData is what the report page and its printable version show.
+func (a *App) taxData(r *http.Request, y tax.Year) (map[string]any, error) {
+	rep, err := tax.Build(r.Context(), a.DB, y)
+	if err != nil {
+		return nil, err
+	}
+	files, err := a.taxAttachments(r, rep)
+	if err != nil {
+		return nil, err
+	}
+	var totals []taxTotal
+	var gst int64
+	i := 0
+	for _, t := range rep.Totals {
+		tt := taxTotal{Key: t.Key, Label: t.Label, Count: t.Count, Total: fmtMoney(t.AmountCents), GST: fmtMoney(t.GSTCents), Unattached: t.Unattached}
+		for _, it := range rep.Items[i : i+t.Count] {
+			ti := taxItem{TxID: it.TxID, Date: it.Date.Format("2006-01-02"), Amount: fmtMoney(it.AmountCents), Merchant: it.Merchant,
+				Details: it.Details, Category: it.Category, Flagged: it.Flagged, Receipt: it.Receipt, Note: it.Note, Files: files[it.TxID]}
+			if it.HasGST {
+				ti.GST = fmtMoney(it.GSTCents)
+			}
+			if strings.HasPrefix(it.Receipt, "http://") || strings.HasPrefix(it.Receipt, "https://") {
+				ti.ReceiptURL = it.Receipt
+			}
+			tt.Items = append(tt.Items, ti)
+		}
+		i += t.Count
+		gst += t.GSTCents
+		totals = append(totals, tt)
+	}
+	return map[string]any{
+		"Year":    y.String(),
+		"From":    y.Start().Format("2 January 2006"),
+		"To":      y.End().AddDate(0, 0, -1).Format("2 January 2006"),
+		"Prev":    (y - 1).String(),
+		"Next":    (y + 1).String(),
+		"Totals":  totals,
+		"GST":     fmtMoney(gst),
+		"Income":  fmtMoney(rep.IncomeCents),
+		"Expense": fmtMoney(rep.ExpenseCents),
+	}, nil
+}
+
+// taxAttachments returns the attachments of the report's items by
+// transaction.
+func (a *App) taxAttachments(r *http.Request, rep *tax.Report) (map[int64][]attachmentView, error) {
+	var ids []int64
+	for _, it := range rep.Items {
+		if it.Attachments > 0 {
+			ids = append(ids, it.TxID)
+		}
+	}
+	atts, err := attachment.List(r.Context(), a.DB, ids...)
+	if err != nil {
+		return nil, err
+	}
+	out := map[int64][]attachmentView{}
+	for i := range atts {
+		at := &atts[i]
+		out[at.TxID] = append(out[at.TxID], attachmentView{ID: at.ID, Filename: at.Filename, Size: fmtSize(at.Size), Image: at.Image()})
+	}
+	return out, nil
+}
+
+func (a *App) handleTax(w http.ResponseWriter, r *http.Request) {
+	a.renderTax(w, r, r.URL.Query().Get("msg"))
+}
+
+func (a *App) renderTax(w http.ResponseWriter, r *http.Request, msg string) {
+	y, err := taxYear(r)
+	if err != nil {
+		msg, y = err.Error(), tax.YearOf(time.Now())
+	}
+	data, err := a.taxData(r, y)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	maps, err := tax.Mappings(r.Context(), a.DB)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	type mapping struct {
+		Category string
+		Label    string
+	}
+	var mappings []mapping
+	cats, _ := classify.KnownCategories(r.Context(), a.DB)
+	for _, c := range cats {
+		if t, ok := maps[c]; ok {
+			mappings = append(mappings, mapping{c, tax.Label(t)})
+		}
+	}
+	data["Mappings"] = mappings
+	data["Categories"] = cats
+	data["TaxCategories"] = tax.Categories
+	data["Message"] = msg
+	a.Tmpl.Render(w, "tax", data)
+}
+
+func (a *App) handleTaxPrint(w http.ResponseWriter, r *http.Request) {
+	y, err := taxYear(r)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	data, err := a.taxData(r, y)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	data["Printed"] = time.Now().Format("2 January 2006")
+	a.Tmpl.Render(w, "tax_print", data)
+}
+
+// handleTaxCSV exports the year's tax-relevant transactions for an
+// accountant, one row each, amounts in dollars.
+func (a *App) handleTaxCSV(w http.ResponseWriter, r *http.Request) {
+	y, err := taxYear(r)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	rep, err := tax.Build(r.Context(), a.DB, y)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	files, err := a.taxAttachments(r, rep)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	dollars := func(c int64) string { return strings.Replace(fmtMoney(c), "$", "", 1) }
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="tax-%s.csv"`, y))
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"date", "tax_category", "amount", "gst", "category", "merchant", "details", "note", "receipt", "attachments", "transaction_id"})
+	for _, it := range rep.Items {
+		gst := ""
+		if it.HasGST {
+			gst = dollars(it.GSTCents)
+		}
+		var names []string
+		for _, f := range files[it.TxID] {
+			names = append(names, f.Filename)
+		}
+		_ = cw.Write([]string{it.Date.Format("2006-01-02"), tax.Label(it.TaxCategory), dollars(it.AmountCents), gst, csvText(it.Category),
+			csvText(it.Merchant), csvText(it.Details), csvText(it.Note), csvText(it.Receipt), csvText(strings.Join(names, "; ")), strconv.FormatInt(it.TxID, 10)})
+	}
+	cw.Flush()
+}
+
+// csvText quotes text from the bank or the user with a leading ' when a
+// spreadsheet would otherwise run it as a formula ("=HYPERLINK(...)").
+func csvText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+func (a *App) handleMapTaxCategory(w http.ResponseWriter, r *http.Request) {
+	cat := r.FormValue("category")
+	if err := tax.MapCategory(r.Context(), a.DB, cat, r.FormValue("tax_category")); err != nil {
+		a.renderTax(w, r, err.Error())
+		return
+	}
+	q := url.Values{"fy": {r.FormValue("fy")}}
+	if r.FormValue("tax_category") == "" {
+		seeOther(w, r, "/tax", q, fmt.Sprintf("%s is no longer tax relevant", strings.TrimSpace(cat)))
+		return
+	}
+	seeOther(w, r, "/tax", q, fmt.Sprintf("%s counts as %s", strings.TrimSpace(cat), tax.Label(r.FormValue("tax_category"))))
+}
+
+// handleSaveTxTax flags a transaction into (or out of) the tax report; an
+// empty tax category goes back to following its spending category.
+func (a *App) handleSaveTxTax(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	f := tax.Flag{TxID: id, TaxCategory: r.FormValue("tax_category"), Receipt: r.FormValue("receipt"), Note: r.FormValue("tax_note")}
+	var err error
+	if f.TaxCategory == "" {
+		err = tax.ClearFlag(r.Context(), a.DB, id)
+	} else {
+		if s := strings.TrimSpace(r.FormValue("gst")); s != "" {
+			if f.GSTCents, err = parseMoney(s); err != nil {
+				http.Error(w, "gst: "+err.Error(), 400)
+				return
+			}
+			f.HasGST = true
+		}
+		err = tax.SetFlag(r.Context(), a.DB, f)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	http.Redirect(w, r, "/tx/"+strconv.FormatInt(id, 10), http.StatusSeeOther)
+}
@@ -1,11 +1,14 @@
 package app
 
 import (
+	"database/sql"
 	"net/http"
 	"strconv"
 	"strings"
 
+	"github.com/anthurium-ai/personal-finance/internal/attachment"
 	"github.com/anthurium-ai/personal-finance/internal/classify"
+	"github.com/anthurium-ai/personal-finance/internal/tax"
 	"github.com/go-chi/chi/v5"
 )
 
@@ -18,12 +21,30 @@ type txView struct {
 	MerchantRaw string
 	Details     string
 	Notes       string
+
+	// category provenance
+	Source     string
+	Reason     string
+	Confidence string
+	SetAt      string
+	Confirmed  bool
+}
+
+type taxView struct {
+	Category string // the flag's; empty when following the category
+	Mapped   string // the category's tax category label, if any
+	GST      string
+	Receipt  string
+	Note     string
 }
 
 type suggestionView struct {
-	Category string
-	Reason   string
-	Source   string
+	Category   string
+	Reason     string
+	Source     string
+	Confidence string
+	RuleID     int64 // set when Source is rule
+	OverrideID int64 // set when Source is override
 }
 
 func (a *App) handleEditTx(w http.ResponseWriter, r *http.Request) {
@@ -31,45 +52,117 @@ func (a *App) handleEditTx(w http.ResponseWriter, r *http.Request) {
 
 	var t txView
 	var amountCents int64
+	var account string
 	row := a.DB.QueryRow(`
 		SELECT id, txn_date, amount_cents,
-		       COALESCE(NULLIF(category_norm,''), category_raw),
-		       COALESCE(NULLIF(merchant_norm,''), merchant_raw),
-		       merchant_raw, details, COALESCE(notes,'')
+		       COALESCE(NULLIF(category_norm,''), category_raw, ''),
+		       COALESCE(NULLIF(merchant_norm,''), merchant_raw, ''),
+		       COALESCE(merchant_raw,''), COALESCE(details,''), COALESCE(notes,''),
+		       COALESCE(category_source,'bank'), COALESCE(category_reason,''), category_confidence,
+		       COALESCE(category_set_at,''), category_confirmed, COALESCE(account,'')
 		FROM transactions WHERE id=?`, id)
-	if err := row.Scan(&t.ID, &t.Date, &amountCents, &t.Category, &t.Merchant, &t.MerchantRaw, &t.Details, &t.Notes); err != nil {
+	var conf sql.NullFloat64
+	if err := row.Scan(&t.ID, &t.Date, &amountCents, &t.Category, &t.Merchant, &t.MerchantRaw, &t.Details, &t.Notes,
+		&t.Source, &t.Reason, &conf, &t.SetAt, &t.Confirmed, &account); err != nil {
 		http.Error(w, err.Error(), 404)
 		return
 	}
 	t.Amount = fmtMoney(amountCents)
+	if conf.Valid {
+		t.Confidence = fmtConfidence(conf.Float64)
+	}
 
-	// deterministic suggestion
-	sug, _ := classify.SuggestCategory(r.Context(), a.DB, t.Merchant, t.Details)
+	// local suggestion (override, rules, model)
+	sug, _ := classify.SuggestCategory(r.Context(), a.DB, classify.Input{Merchant: t.Merchant, Details: t.Details, AmountCents: amountCents, Account: account})
 	var sv *suggestionView
 	if sug != nil {
-		sv = &suggestionView{Category: sug.Category, Reason: sug.Reason, Source: sug.Source}
+		sv = &suggestionView{Category: sug.Category, Reason: sug.Reason, Source: sug.Source, Confidence: fmtConfidence(sug.Confidence),
+			RuleID: sug.RuleID, OverrideID: sug.OverrideID}
 	}
 	qs := r.URL.Query()
 	if qs.Get("suggest") != "" {
-		sv = &suggestionView{Category: qs.Get("suggest"), Reason: qs.Get("reason"), Source: qs.Get("source")}
+		sv = &suggestionView{Category: qs.Get("suggest"), Reason: qs.Get("reason"), Source: qs.Get("source"), Confidence: qs.Get("confidence")}
 	}
 
-	a.Tmpl.Render(w, "edit_tx", map[string]any{"Tx": t, "Suggestion": sv})
+	var llmName string
+	if a.LLM != nil {
+		llmName = a.LLM.Name()
+	}
+	// tax: the transaction's own flag, else what its category maps to
+	flag, err := tax.GetFlag(r.Context(), a.DB, id)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	maps, _ := tax.Mappings(r.Context(), a.DB)
+	tv := taxView{Mapped: tax.Label(maps[t.Category])}
+	if flag != nil {
+		tv.Category, tv.Receipt, tv.Note = flag.TaxCategory, flag.Receipt, flag.Note
+		if flag.HasGST {
+			tv.GST = strings.Replace(fmtMoney(flag.GSTCents), "$", "", 1)
+		}
+	}
+
+	atts, err := a.attachmentViews(r, id)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+
+	a.Tmpl.Render(w, "edit_tx", map[string]any{"Tx": t, "Suggestion": sv, "LLM": llmName, "Tax": tv, "TaxCategories": tax.Categories,
+		"Attachments": atts, "AttachmentTypes": strings.Join(attachment.Types, ","), "MaxSize": fmtSize(attachment.MaxSize)})
 }
 
 func (a *App) handleSaveTx(w http.ResponseWriter, r *http.Request) {
 	id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
 	_ = r.ParseForm()
 	cat := strings.TrimSpace(r.FormValue("category_norm"))
-	mer := strings.TrimSpace(r.FormValue("merchant_norm"))	
+	mer := strings.TrimSpace(r.FormValue("merchant_norm"))
 	notes := strings.TrimSpace(r.FormValue("notes"))
 
-	_, err := a.DB.Exec(`UPDATE transactions SET category_norm=?, merchant_norm=?, notes=? WHERE id=?`, cat, mer, notes, id)
+	var oldCat, oldMer string
+	_ = a.DB.QueryRow(`SELECT COALESCE(category_norm,''), COALESCE(merchant_norm,'') FROM transactions WHERE id=?`, id).Scan(&oldCat, &oldMer)
+
+	// a hand-edited merchant survives re-normalisation
+	_, err := a.DB.Exec(`UPDATE transactions SET merchant_norm=?, notes=?, merchant_manual=(merchant_manual OR ?) WHERE id=?`, mer, notes, mer != oldMer, id)
 	if err != nil {
 		http.Error(w, err.Error(), 500)
 		return
 	}
 
+	// saving confirms the category; a changed one is recorded as manual unless
+	// the user accepted a suggestion, which keeps the suggestion's provenance
+	if cat != oldCat {
+		sug := &classify.Suggestion{Category: cat, Source: classify.SourceManual, Reason: "edited in portal", Confidence: classify.ConfidenceManual}
+		if src := r.FormValue("source"); isSuggestionSource(src) && r.FormValue("accept") != "" {
+			sug.Source = src
+			sug.Reason = r.FormValue("reason")
+			sug.Confidence, _ = strconv.ParseFloat(r.FormValue("confidence"), 64)
+			sug.RuleID, _ = strconv.ParseInt(r.FormValue("rule_id"), 10, 64)
+			sug.OverrideID, _ = strconv.ParseInt(r.FormValue("override_id"), 10, 64)
+		}
+		err = classify.Record(r.Context(), a.DB, id, sug, true)
+		if err == nil {
+			// queued suggestions are moot once the category is decided here
+			_, err = a.DB.Exec(`UPDATE classification_suggestions SET status=?, decided_at=strftime('%Y-%m-%dT%H:%M:%fZ','now') WHERE tx_id=? AND status='pending'`, classify.StatusSuperseded, id)
+		}
+	} else {
+		_, err = a.DB.Exec(`UPDATE transactions SET category_confirmed=1 WHERE id=?`, id)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+
+	// retrain the local model on this transaction
+	if tx, err := a.DB.BeginTx(r.Context(), nil); err == nil {
+		if classify.Learn(r.Context(), tx, id) == nil {
+			_ = tx.Commit()
+		} else {
+			_ = tx.Rollback()
+		}
+	}
+
 	// learn override: merchant -> category
 	if mer != "" && cat != "" {
 		_, _ = a.DB.Exec(`INSERT INTO merchant_category_overrides (merchant_norm, category_norm) VALUES (?,?) ON CONFLICT(merchant_norm) DO UPDATE SET category_norm=excluded.category_norm`, mer, cat)
@@ -81,37 +174,39 @@ func (a *App) handleSaveTx(w http.ResponseWriter, r *http.Request) {
 func (a *App) handleSuggestTx(w http.ResponseWriter, r *http.Request) {
 	id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
 
-	var merchantRaw, details string
-	var amountCents int64
-	row := a.DB.QueryRow(`SELECT merchant_raw, details, amount_cents FROM transactions WHERE id=?`, id)
-	if err := row.Scan(&merchantRaw, &details, &amountCents); err != nil {
+	var in classify.Input
+	row := a.DB.QueryRow(`SELECT COALESCE(NULLIF(merchant_norm,''), COALESCE(merchant_raw,'')), COALESCE(details,''), amount_cents, COALESCE(account,'') FROM transactions WHERE id=?`, id)
+	if err := row.Scan(&in.Merchant, &in.Details, &in.AmountCents, &in.Account); err != nil {
 		http.Error(w, err.Error(), 404)
 		return
 	}
 
 	// gather some known categories for the prompt
-	catRows, _ := a.DB.Query(`SELECT DISTINCT COALESCE(NULLIF(category_norm,''), category_raw) FROM transactions WHERE COALESCE(NULLIF(category_norm,''), category_raw) != '' LIMIT 50`)
-	var cats []string
-	for catRows != nil && catRows.Next() {
-		var c string
-		_ = catRows.Scan(&c)
-		cats = append(cats, c)
-	}
-	if catRows != nil {
-		catRows.Close()
-	}
+	cats, _ := classify.KnownCategories(r.Context(), a.DB)
 
-	sug, err := classify.SuggestCategoryLLM(r.Context(), merchantRaw, details, amountCents, cats)
+	sug, err := classify.SuggestCategoryLLM(r.Context(), a.DB, a.LLM, in, cats)
 	if err != nil {
 		http.Redirect(w, r, "/tx/"+strconv.FormatInt(id, 10), http.StatusSeeOther)
 		return
 	}
 
 	// show suggestion by re-rendering edit page with suggestion
-	http.Redirect(w, r, "/tx/"+strconv.FormatInt(id, 10)+"?suggest="+urlQueryEscape(sug.Category)+"&reason="+urlQueryEscape(sug.Reason)+"&source=llm", http.StatusSeeOther)
+	http.Redirect(w, r, "/tx/"+strconv.FormatInt(id, 10)+"?suggest="+urlQueryEscape(sug.Category)+"&reason="+urlQueryEscape(sug.Reason)+"&source=llm&confidence="+fmtConfidence(classify.LLMConfidence(sug.Confidence)), http.StatusSeeOther)
 }
 
 func urlQueryEscape(s string) string {
 	r := strings.NewReplacer("%", "%25", " ", "%20", "\n", "%0A", "\r", "")
 	return r.Replace(s)
 }
+
+func fmtConfidence(c float64) string {
+	return strconv.FormatFloat(c, 'f', 2, 64)
+}
+
+func isSuggestionSource(s string) bool {
+	switch s {
+	case classify.SourceOverride, classify.SourceRule, classify.SourceModel, classify.SourceLLM:
+		return true
+	}
+	return false
+}
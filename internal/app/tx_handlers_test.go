@@ -0,0 +1,137 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/anthurium-ai/personal-finance/internal/db"
+	"github.com/anthurium-ai/personal-finance/internal/web"
+)
+
+func newTestApp(t *testing.T) *App {
+	t.Helper()
+	d, err := db.Open(filepath.Join(t.TempDir(), "pf.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Close() })
+	if err := db.Migrate(context.Background(), d); err != nil {
+		t.Fatal(err)
+	}
+	tmpl, err := web.LoadTemplates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &App{DB: d, Tmpl: tmpl, ctx: context.Background()}
+}
+
+func addTestTx(t *testing.T, d *sql.DB, merchant string, cents int64, cat string) int64 {
+	t.Helper()
+	res, err := d.Exec(`INSERT INTO transactions (txn_date, amount_cents, merchant_raw, merchant_norm, category_raw, row_hash)
+		VALUES ('2025-03-01', ?, ?, ?, ?, hex(randomblob(8)))`, cents, merchant, merchant, cat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, _ := res.LastInsertId()
+	return id
+}
+
+func TestSaveTxAcceptSuggestion(t *testing.T) {
+	a := newTestApp(t)
+	h := a.Router()
+
+	res, err := a.DB.Exec(`INSERT INTO category_rules (match_contains, category_norm) VALUES ('WOOLWORTHS','Groceries')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ruleID, _ := res.LastInsertId()
+	res, err = a.DB.Exec(`INSERT INTO merchant_category_overrides (merchant_norm, category_norm) VALUES ('KMART','Household')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	overrideID, _ := res.LastInsertId()
+
+	cases := []struct {
+		name       string
+		merchant   string
+		accept     bool
+		category   string // posted category; empty takes the suggestion's
+		wantCat    string
+		wantSource string
+		wantRule   int64
+		wantOver   int64
+	}{
+		{name: "accept rule", merchant: "WOOLWORTHS", accept: true, wantCat: "Groceries", wantSource: "rule", wantRule: ruleID},
+		{name: "accept override", merchant: "KMART", accept: true, wantCat: "Household", wantSource: "override", wantOver: overrideID},
+		{name: "manual edit", merchant: "WOOLWORTHS", category: "Dining", wantCat: "Dining", wantSource: "manual"},
+	}
+	for _, c := range cases {
+		id := addTestTx(t, a.DB, c.merchant, -1000, "Other")
+		path := "/tx/" + strconv.FormatInt(id, 10)
+
+		// the edit page carries the suggestion's ids in the accept form
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		body, _ := io.ReadAll(rec.Body)
+		form := url.Values{"merchant_norm": {c.merchant}, "category_norm": {c.category}}
+		if c.accept {
+			form = hiddenFields(string(body))
+			form.Set("accept", "1")
+		}
+
+		rec = httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusSeeOther {
+			t.Errorf("%s: status %d, want 303", c.name, rec.Code)
+			continue
+		}
+
+		var cat, source string
+		var rule, over sql.NullInt64
+		var confirmed bool
+		err := a.DB.QueryRow(`SELECT category_norm, category_source, category_rule_id, category_override_id, category_confirmed
+			FROM transactions WHERE id=?`, id).Scan(&cat, &source, &rule, &over, &confirmed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cat != c.wantCat || source != c.wantSource || rule.Int64 != c.wantRule || over.Int64 != c.wantOver || !confirmed {
+			t.Errorf("%s: got %s/%s rule %d override %d confirmed %v, want %s/%s rule %d override %d confirmed",
+				c.name, cat, source, rule.Int64, over.Int64, confirmed, c.wantCat, c.wantSource, c.wantRule, c.wantOver)
+		}
+	}
+}
+
+// hiddenFields returns the hidden inputs of the page's suggestion form.
+func hiddenFields(page string) url.Values {
+	v := url.Values{}
+	i := strings.Index(page, "<h3>Suggestion</h3>")
+	if i < 0 {
+		return v
+	}
+	for _, in := range strings.Split(page[i:], "<input ")[1:] {
+		if !strings.Contains(in, `type="hidden"`) {
+			continue
+		}
+		v.Set(attr(in, "name"), attr(in, "value"))
+	}
+	return v
+}
+
+func attr(tag, name string) string {
+	i := strings.Index(tag, name+`="`)
+	if i < 0 {
+		return ""
+	}
+	s := tag[i+len(name)+2:]
+	return s[:strings.Index(s, `"`)]
+}
@@ -0,0 +1,233 @@
+// Package attachment keeps receipts and other documents for transactions.
+// Files are stored on disk by the SHA-256 of their content, so the same
+// receipt attached twice is kept once; their details live in SQLite.
+package attachment
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/anthurium-ai/personal-finance/internal/db"
+)
+
+// MaxSize is the largest file accepted.
+const MaxSize = 20 << 20
+
+// Types are the content types accepted, as sniffed from the file itself.
+var Types = []string{"image/jpeg", "image/png", "image/gif", "image/webp", "application/pdf"}
+
+// DefaultDir keeps attachments next to the database.
+func DefaultDir(dbPath string) string {
+	return filepath.Join(filepath.Dir(dbPath), "attachments")
+}
+
+// Store is a content-addressed directory: a file with hash abcdef... is at
+// Dir/ab/abcdef....
+type Store struct {
+	Dir string
+}
+
+func (s Store) path(sum string) string {
+	return filepath.Join(s.Dir, sum[:2], sum)
+}
+
+// Open returns the file with the given hash.
+func (s Store) Open(sum string) (*os.File, error) {
+	if len(sum) != sha256.Size*2 {
+		return nil, fmt.Errorf("bad hash %q", sum)
+	}
+	return os.Open(s.path(sum))
+}
+
+// stage copies r to a temporary file in the store, returning its name, hash
+// and size. The caller removes it, or keeps it with keep.
+func (s Store) stage(r io.Reader) (string, string, int64, error) {
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return "", "", 0, err
+	}
+	tmp, err := os.CreateTemp(s.Dir, ".upload-*")
+	if err != nil {
+		return "", "", 0, err
+	}
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(tmp, h), io.LimitReader(r, MaxSize+1))
+	if err == nil {
+		err = tmp.Chmod(0o644)
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && n > MaxSize {
+		err = fmt.Errorf("file is over %d MB", MaxSize>>20)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", "", 0, err
+	}
+	return tmp.Name(), hex.EncodeToString(h.Sum(nil)), n, nil
+}
+
+// keep moves a staged file to its place, reporting whether it was new. A
+// file already stored is left as it is.
+func (s Store) keep(tmp, sum string) (bool, error) {
+	if _, err := os.Stat(s.path(sum)); err == nil {
+		return false, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path(sum)), 0o755); err != nil {
+		return false, err
+	}
+	return true, os.Rename(tmp, s.path(sum))
+}
+
+// Attachment is a file attached to a transaction.
+type Attachment struct {
+	ID          int64
+	TxID        int64
+	SHA256      string
+	Filename    string
+	ContentType string
+	Size        int64
+	CreatedAt   string
+}
+
+// Image is whether the attachment can be shown in an img tag.
+func (a *Attachment) Image() bool { return strings.HasPrefix(a.ContentType, "image/") }
+
+// Add stores the file in r and attaches it to txID. The content type is
+// sniffed from the file; only Types are accepted. The file is put in place
+// inside the insert's transaction, so a Delete of the same content can't
+// remove it in between, and a failed insert leaves no new file behind.
+func Add(ctx context.Context, d *sql.DB, s Store, txID int64, filename string, r io.Reader) (*Attachment, error) {
+	head := make([]byte, 512)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, fmt.Errorf("file is empty")
+	}
+	head = head[:n]
+	ct, _, _ := strings.Cut(http.DetectContentType(head), ";")
+	known := false
+	for _, t := range Types {
+		known = known || t == ct
+	}
+	if !known {
+		return nil, fmt.Errorf("only images and PDFs can be attached, not %s", ct)
+	}
+
+	a := &Attachment{TxID: txID, Filename: filepath.Base(strings.TrimSpace(filename)), ContentType: ct}
+	if a.Filename == "." || a.Filename == "/" {
+		a.Filename = "attachment"
+	}
+	tmp, sum, size, err := s.stage(io.MultiReader(bytes.NewReader(head), r))
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp)
+	a.SHA256, a.Size = sum, size
+
+	tx, err := d.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	res, err := tx.ExecContext(ctx, `INSERT INTO attachments (tx_id, sha256, filename, content_type, size_bytes) VALUES (?,?,?,?,?)`,
+		a.TxID, a.SHA256, a.Filename, a.ContentType, a.Size)
+	if err != nil {
+		return nil, err
+	}
+	if a.ID, err = res.LastInsertId(); err != nil {
+		return nil, err
+	}
+	created, err := s.keep(tmp, sum)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		if created {
+			os.Remove(s.path(sum))
+		}
+		return nil, err
+	}
+	return a, nil
+}
+
+const columns = `id, tx_id, sha256, filename, content_type, size_bytes, created_at`
+
+func scan(row interface{ Scan(...any) error }) (Attachment, error) {
+	var a Attachment
+	err := row.Scan(&a.ID, &a.TxID, &a.SHA256, &a.Filename, &a.ContentType, &a.Size, &a.CreatedAt)
+	return a, err
+}
+
+// Get returns one attachment, or sql.ErrNoRows.
+func Get(ctx context.Context, q db.Querier, id int64) (Attachment, error) {
+	return scan(q.QueryRowContext(ctx, `SELECT `+columns+` FROM attachments WHERE id=?`, id))
+}
+
+// List returns the attachments of the given transactions, oldest first.
+func List(ctx context.Context, q db.Querier, txIDs ...int64) ([]Attachment, error) {
+	if len(txIDs) == 0 {
+		return nil, nil
+	}
+	args := make([]any, len(txIDs))
+	for i, id := range txIDs {
+		args[i] = id
+	}
+	rows, err := q.QueryContext(ctx, `SELECT `+columns+` FROM attachments WHERE tx_id IN (?`+strings.Repeat(",?", len(txIDs)-1)+`) ORDER BY id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Attachment
+	for rows.Next() {
+		a, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+// Delete removes an attachment, and its file once nothing else uses it. The
+// check and the unlink happen inside the delete's transaction, so an Add of
+// the same content waits for it rather than finding the file gone.
+func Delete(ctx context.Context, d *sql.DB, s Store, id int64) error {
+	tx, err := d.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	a, err := Get(ctx, tx, id)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM attachments WHERE id=?`, id); err != nil {
+		return err
+	}
+	var others int
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM attachments WHERE sha256=?`, a.SHA256).Scan(&others); err != nil {
+		return err
+	}
+	if others == 0 {
+		if err := os.Remove(s.path(a.SHA256)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return tx.Commit()
+}
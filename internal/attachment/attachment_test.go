@@ -0,0 +1,163 @@
+package attachment
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/anthurium-ai/personal-finance/internal/db"
+)
+
+// a 1x1 PNG
+var png = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01\x08\x06\x00\x00\x00\x1f\x15\xc4\x89" +
+	"\x00\x00\x00\rIDATx\x9cc\xf8\x0f\x00\x00\x01\x01\x00\x05\x18\xd8N\x00\x00\x00\x00IEND\xaeB`\x82")
+
+func setup(t *testing.T) (*sql.DB, Store, []int64) {
+	t.Helper()
+	dir := t.TempDir()
+	d, err := db.Open(filepath.Join(dir, "pf.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Close() })
+	if err := db.Migrate(context.Background(), d); err != nil {
+		t.Fatal(err)
+	}
+	var ids []int64
+	for _, h := range []string{"a", "b"} {
+		res, err := d.Exec(`INSERT INTO transactions (txn_date, amount_cents, row_hash) VALUES ('2026-03-01', -1000, ?)`, h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		id, _ := res.LastInsertId()
+		ids = append(ids, id)
+	}
+	return d, Store{Dir: filepath.Join(dir, "attachments")}, ids
+}
+
+// files lists what is in the store, temporary files included.
+func files(t *testing.T, s Store) []string {
+	t.Helper()
+	var out []string
+	filepath.WalkDir(s.Dir, func(path string, e os.DirEntry, err error) error {
+		if err == nil && !e.IsDir() {
+			out = append(out, filepath.Base(path))
+		}
+		return nil
+	})
+	return out
+}
+
+func TestAdd(t *testing.T) {
+	ctx := context.Background()
+	d, s, tx := setup(t)
+	sum := sha256.Sum256(png)
+	want := hex.EncodeToString(sum[:])
+
+	a, err := Add(ctx, d, s, tx[0], " ../receipts/coffee.png ", bytes.NewReader(png))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.SHA256 != want || a.Filename != "coffee.png" || a.ContentType != "image/png" || a.Size != int64(len(png)) || !a.Image() {
+		t.Errorf("Add = %+v", a)
+	}
+	got, err := Get(ctx, d, a.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.TxID != tx[0] || got.SHA256 != want || got.Filename != "coffee.png" {
+		t.Errorf("Get = %+v", got)
+	}
+	f, err := s.Open(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	// the same receipt on another transaction is stored once
+	if _, err := Add(ctx, d, s, tx[1], "copy.png", bytes.NewReader(png)); err != nil {
+		t.Fatal(err)
+	}
+	if fs := files(t, s); len(fs) != 1 || fs[0] != want {
+		t.Errorf("store has %v, want just %s", fs, want)
+	}
+	list, err := List(ctx, d, tx...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].Filename != "coffee.png" || list[1].Filename != "copy.png" {
+		t.Errorf("List = %+v", list)
+	}
+}
+
+func TestAddRejected(t *testing.T) {
+	ctx := context.Background()
+	d, s, tx := setup(t)
+	tests := []struct {
+		name string
+		txID int64
+		body []byte
+		err  string
+	}{
+		{"empty", tx[0], nil, "empty"},
+		{"text", tx[0], []byte("just some notes"), "only images and PDFs"},
+		{"too big", tx[0], append(append([]byte{}, png...), make([]byte, MaxSize)...), "over"},
+		{"no such transaction", 999, png, "constraint"},
+	}
+	for _, tt := range tests {
+		_, err := Add(ctx, d, s, tt.txID, "x", bytes.NewReader(tt.body))
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: Add = %v, want an error with %q", tt.name, err, tt.err)
+		}
+	}
+	// nothing is left behind, not even a temporary file
+	if fs := files(t, s); len(fs) != 0 {
+		t.Errorf("store has %v after failed adds", fs)
+	}
+	var n int
+	if err := d.QueryRow(`SELECT COUNT(*) FROM attachments`).Scan(&n); err != nil || n != 0 {
+		t.Errorf("%d attachments (%v), want none", n, err)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	ctx := context.Background()
+	d, s, tx := setup(t)
+	first, err := Add(ctx, d, s, tx[0], "a.png", bytes.NewReader(png))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := Add(ctx, d, s, tx[1], "b.png", bytes.NewReader(png))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the file stays while another attachment uses it
+	if err := Delete(ctx, d, s, first.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Get(ctx, d, first.ID); err != sql.ErrNoRows {
+		t.Errorf("Get after Delete = %v, want sql.ErrNoRows", err)
+	}
+	if fs := files(t, s); len(fs) != 1 {
+		t.Errorf("store has %v, want the shared file kept", fs)
+	}
+
+	if err := Delete(ctx, d, s, second.ID); err != nil {
+		t.Fatal(err)
+	}
+	if fs := files(t, s); len(fs) != 0 {
+		t.Errorf("store has %v, want it empty", fs)
+	}
+
+	// deleting again, or something that never existed, is not an error
+	if err := Delete(ctx, d, s, second.ID); err != nil {
+		t.Errorf("second Delete = %v", err)
+	}
+}
@@ -0,0 +1,82 @@
+// Package backup writes the database and the attachment store to a single
+// .tar.gz: finance.db, a consistent snapshot taken while the portal runs,
+// and attachments/ as it is on disk.
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Write writes a backup of d and attachmentsDir to w. A missing
+// attachments directory is backed up as empty.
+func Write(ctx context.Context, d *sql.DB, attachmentsDir string, w io.Writer) error {
+	tmp, err := os.MkdirTemp("", "pf-backup-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	snapshot := filepath.Join(tmp, "finance.db")
+	if _, err := d.ExecContext(ctx, `VACUUM INTO ?`, snapshot); err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	if err := addFile(tw, snapshot, "finance.db"); err != nil {
+		return err
+	}
+	err = filepath.WalkDir(attachmentsDir, func(path string, e fs.DirEntry, err error) error {
+		if err != nil {
+			if path == attachmentsDir && os.IsNotExist(err) {
+				return fs.SkipAll
+			}
+			return err
+		}
+		// half-written uploads start with a dot
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			return nil
+		}
+		rel, err := filepath.Rel(attachmentsDir, path)
+		if err != nil {
+			return err
+		}
+		return addFile(tw, path, filepath.ToSlash(filepath.Join("attachments", rel)))
+	})
+	if err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func addFile(tw *tar.Writer, path, name string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	hdr, err := tar.FileInfoHeader(st, "")
+	if err != nil {
+		return err
+	}
+	hdr.Name = name
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
@@ -0,0 +1,430 @@
+// Package bills keeps scheduled transactions (rent, insurance, rates, rego)
+// and matches imported transactions to their occurrences.
+package bills
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/anthurium-ai/personal-finance/internal/db"
+)
+
+// Recurrence kinds stored in bills.rule.
+const (
+	RuleMonthly         = "monthly"           // day Day of every Interval months
+	RuleWeekly          = "weekly"            // every Interval weeks from the start date
+	RuleYearly          = "yearly"            // every Interval years on the start date's day
+	RuleLastBusinessDay = "last_business_day" // last weekday of every Interval months
+)
+
+var Rules = []string{RuleMonthly, RuleWeekly, RuleYearly, RuleLastBusinessDay}
+
+// Bill is a scheduled transaction.
+type Bill struct {
+	ID          int64
+	Name        string
+	AmountCents int64 // signed: bills negative, income positive
+	Account     string
+	Category    string
+	MatchText   string // found in a transaction's merchant or details
+	Rule        string
+	Interval    int
+	Day         int // RuleMonthly only; past the month's end means its last day
+	Start       time.Time
+	End         time.Time // zero for open-ended
+
+	ToleranceDays int // how far either side of the due date a payment may land
+	TolerancePct  int // how far the amount may differ, for bills that vary
+}
+
+// Describe is the recurrence in words, e.g. "every month on day 1".
+func (b *Bill) Describe() string {
+	every := func(unit string) string {
+		if b.Interval == 1 {
+			return "every " + unit
+		}
+		return fmt.Sprintf("every %d %ss", b.Interval, unit)
+	}
+	switch b.Rule {
+	case RuleMonthly:
+		return fmt.Sprintf("%s on day %d", every("month"), b.Day)
+	case RuleWeekly:
+		return every("week") + " on " + b.Start.Format("Monday")
+	case RuleYearly:
+		return every("year") + " on " + b.Start.Format("2 January")
+	case RuleLastBusinessDay:
+		return every("month") + " on the last business day"
+	}
+	return b.Rule
+}
+
+// Occurrences returns the bill's due dates in [from, to).
+func (b *Bill) Occurrences(from, to time.Time) []time.Time {
+	if !b.End.IsZero() && b.End.AddDate(0, 0, 1).Before(to) {
+		to = b.End.AddDate(0, 0, 1)
+	}
+	n := max(b.Interval, 1)
+	var out []time.Time
+	for i := 0; ; i++ {
+		var d time.Time
+		switch b.Rule {
+		case RuleMonthly:
+			d = dayOfMonth(b.Start.Year(), b.Start.Month()+time.Month(i*n), b.Day)
+		case RuleWeekly:
+			d = b.Start.AddDate(0, 0, 7*n*i)
+		case RuleYearly:
+			d = dayOfMonth(b.Start.Year()+i*n, b.Start.Month(), b.Start.Day())
+		case RuleLastBusinessDay:
+			d = lastBusinessDay(b.Start.Year(), b.Start.Month()+time.Month(i*n))
+		default:
+			return nil
+		}
+		if !d.Before(to) {
+			return out
+		}
+		if !d.Before(from) && !d.Before(b.Start) {
+			out = append(out, d)
+		}
+	}
+}
+
+// dayOfMonth is day d of the month, clamped to the month's last day.
+func dayOfMonth(y int, m time.Month, d int) time.Time {
+	first := time.Date(y, m, 1, 0, 0, 0, 0, time.UTC)
+	if last := first.AddDate(0, 1, -1).Day(); d > last {
+		d = last
+	}
+	return first.AddDate(0, 0, d-1)
+}
+
+// lastBusinessDay is the month's last Monday to Friday. Public holidays
+// aren't known.
+func lastBusinessDay(y int, m time.Month) time.Time {
+	d := time.Date(y, m+1, 0, 0, 0, 0, 0, time.UTC)
+	for d.Weekday() == time.Saturday || d.Weekday() == time.Sunday {
+		d = d.AddDate(0, 0, -1)
+	}
+	return d
+}
+
+// Validate checks b before it is stored, filling in defaults.
+func (b *Bill) Validate() error {
+	b.Name = strings.TrimSpace(b.Name)
+	b.MatchText = strings.TrimSpace(b.MatchText)
+	if b.MatchText == "" {
+		b.MatchText = b.Name
+	}
+	if b.Interval < 1 {
+		b.Interval = 1
+	}
+	if b.Rule == RuleMonthly && b.Day == 0 {
+		b.Day = b.Start.Day()
+	}
+	switch {
+	case b.Name == "":
+		return fmt.Errorf("name is required")
+	case b.AmountCents == 0:
+		return fmt.Errorf("amount is required")
+	case b.Start.IsZero():
+		return fmt.Errorf("start date is required")
+	case !b.End.IsZero() && b.End.Before(b.Start):
+		return fmt.Errorf("end date is before the start")
+	case b.Rule == RuleMonthly && (b.Day < 1 || b.Day > 31):
+		return fmt.Errorf("day must be 1 to 31")
+	case b.ToleranceDays < 0 || b.TolerancePct < 0:
+		return fmt.Errorf("tolerances can't be negative")
+	}
+	for _, r := range Rules {
+		if r == b.Rule {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown recurrence %q", b.Rule)
+}
+
+// Create stores b and returns its id.
+func Create(ctx context.Context, q db.Querier, b Bill) (int64, error) {
+	if err := b.Validate(); err != nil {
+		return 0, err
+	}
+	var end sql.NullString
+	if !b.End.IsZero() {
+		end = sql.NullString{String: b.End.Format("2006-01-02"), Valid: true}
+	}
+	res, err := q.ExecContext(ctx, `INSERT INTO bills (name, amount_cents, account, category_norm, match_text, rule, interval, day, start_date, end_date, tolerance_days, tolerance_pct)
+		VALUES (?,?,?,?,?,?,?,?,?,?,?,?)`,
+		b.Name, b.AmountCents, b.Account, b.Category, b.MatchText, b.Rule, b.Interval, b.Day, b.Start.Format("2006-01-02"), end, b.ToleranceDays, b.TolerancePct)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+func Delete(ctx context.Context, q db.Querier, id int64) error {
+	_, err := q.ExecContext(ctx, `DELETE FROM bills WHERE id=?`, id)
+	return err
+}
+
+// List returns every bill by name.
+func List(ctx context.Context, q db.Querier) ([]Bill, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT id, name, amount_cents, COALESCE(account,''), COALESCE(category_norm,''), match_text, rule, interval, day,
+		       start_date, COALESCE(end_date,''), tolerance_days, tolerance_pct
+		FROM bills ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Bill
+	for rows.Next() {
+		var b Bill
+		var start, end string
+		if err := rows.Scan(&b.ID, &b.Name, &b.AmountCents, &b.Account, &b.Category, &b.MatchText, &b.Rule, &b.Interval, &b.Day,
+			&start, &end, &b.ToleranceDays, &b.TolerancePct); err != nil {
+			return nil, err
+		}
+		b.Start, _ = time.Parse("2006-01-02", start)
+		if end != "" {
+			b.End, _ = time.Parse("2006-01-02", end)
+		}
+		out = append(out, b)
+	}
+	return out, rows.Err()
+}
+
+// Occurrence states.
+const (
+	StatusPaid     = "paid"     // matched to a transaction, or marked paid
+	StatusDue      = "due"      // not seen yet, still within tolerance
+	StatusOverdue  = "overdue"  // not seen and past due plus tolerance
+	StatusUpcoming = "upcoming" // in the future
+)
+
+// Occurrence is one due date of a bill.
+type Occurrence struct {
+	Bill      *Bill
+	Due       time.Time
+	Status    string
+	TxID      int64 // the matched transaction; 0 if marked paid by hand or unpaid
+	PaidOn    time.Time
+	PaidCents int64
+}
+
+// Calendar returns every occurrence in [from, to) with its state as of today,
+// in date order.
+func Calendar(ctx context.Context, q db.Querier, from, to, today time.Time) ([]Occurrence, error) {
+	today = date(today)
+	bills, err := List(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	paid, err := payments(ctx, q, from, to)
+	if err != nil {
+		return nil, err
+	}
+	var out []Occurrence
+	for i := range bills {
+		b := &bills[i]
+		for _, d := range b.Occurrences(from, to) {
+			o := Occurrence{Bill: b, Due: d}
+			if p, ok := paid[occKey{b.ID, d.Format("2006-01-02")}]; ok {
+				o.Status, o.TxID, o.PaidOn, o.PaidCents = StatusPaid, p.txID, p.date, p.cents
+			} else {
+				switch {
+				case d.AddDate(0, 0, b.ToleranceDays).Before(today):
+					o.Status = StatusOverdue
+				case d.After(today):
+					o.Status = StatusUpcoming
+				default:
+					o.Status = StatusDue
+				}
+			}
+			out = append(out, o)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if !out[i].Due.Equal(out[j].Due) {
+			return out[i].Due.Before(out[j].Due)
+		}
+		return out[i].Bill.Name < out[j].Bill.Name
+	})
+	return out, nil
+}
+
+type occKey struct {
+	bill int64
+	due  string
+}
+
+type payment struct {
+	txID  int64
+	date  time.Time
+	cents int64
+}
+
+// payments reads bill_payments for occurrences due in [from, to).
+func payments(ctx context.Context, q db.Querier, from, to time.Time) (map[occKey]payment, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT p.bill_id, p.due_date, COALESCE(p.tx_id,0), COALESCE(t.txn_date, substr(p.created_at,1,10)), COALESCE(t.amount_cents,0)
+		FROM bill_payments p LEFT JOIN transactions t ON t.id = p.tx_id
+		WHERE p.due_date >= ? AND p.due_date < ?`, from.Format("2006-01-02"), to.Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := map[occKey]payment{}
+	for rows.Next() {
+		var k occKey
+		var p payment
+		var date string
+		if err := rows.Scan(&k.bill, &k.due, &p.txID, &date, &p.cents); err != nil {
+			return nil, err
+		}
+		p.date, _ = time.Parse("2006-01-02", date)
+		out[k] = p
+	}
+	return out, rows.Err()
+}
+
+// MarkPaid records an occurrence as paid without a matching transaction,
+// e.g. paid from an account that isn't imported.
+func MarkPaid(ctx context.Context, q db.Querier, billID int64, due time.Time) error {
+	_, err := q.ExecContext(ctx, `INSERT INTO bill_payments (bill_id, due_date) VALUES (?,?) ON CONFLICT(bill_id, due_date) DO NOTHING`,
+		billID, due.Format("2006-01-02"))
+	return err
+}
+
+// Unmatch forgets an occurrence's payment, so it can be matched again.
+func Unmatch(ctx context.Context, q db.Querier, billID int64, due time.Time) error {
+	_, err := q.ExecContext(ctx, `DELETE FROM bill_payments WHERE bill_id=? AND due_date=?`, billID, due.Format("2006-01-02"))
+	return err
+}
+
+// Match pairs unpaid occurrences due up to today with transactions: same
+// direction, the amount within the bill's tolerance, dated within its
+// tolerance of the due date, on its account if it has one, and with the
+// match text in the merchant or details. Each transaction pays at most one
+// occurrence and each occurrence takes one transaction; pairs are taken
+// closest in date first, so two close occurrences don't trade payments. It
+// returns how many were matched.
+func Match(ctx context.Context, q db.Querier, today time.Time) (int, error) {
+	today = date(today)
+	bills, err := List(ctx, q)
+	if err != nil {
+		return 0, err
+	}
+	type candidate struct {
+		occ  occKey
+		txID int64
+		days float64
+	}
+	var cands []candidate
+	for i := range bills {
+		b := &bills[i]
+		paid, err := payments(ctx, q, b.Start, today.AddDate(0, 0, b.ToleranceDays+1))
+		if err != nil {
+			return 0, err
+		}
+		// a payment can come early, so look a little past today
+		for _, d := range b.Occurrences(b.Start, today.AddDate(0, 0, b.ToleranceDays+1)) {
+			k := occKey{b.ID, d.Format("2006-01-02")}
+			if _, ok := paid[k]; ok {
+				continue
+			}
+			slack := abs(b.AmountCents) * int64(b.TolerancePct) / 100
+			text := likeEscape(b.MatchText)
+			rows, err := q.QueryContext(ctx, `
+				SELECT id, ABS(julianday(txn_date) - julianday(?)) FROM transactions
+				WHERE txn_date >= ? AND txn_date <= ?
+				  AND amount_cents BETWEEN ? AND ?
+				  AND (? = '' OR account = ?)
+				  AND (UPPER(COALESCE(merchant_norm,'')) LIKE '%' || UPPER(?) || '%' ESCAPE '\'
+				       OR UPPER(COALESCE(details,'')) LIKE '%' || UPPER(?) || '%' ESCAPE '\')
+				  AND NOT EXISTS (SELECT 1 FROM bill_payments p WHERE p.tx_id = transactions.id)`,
+				k.due, d.AddDate(0, 0, -b.ToleranceDays).Format("2006-01-02"), d.AddDate(0, 0, b.ToleranceDays).Format("2006-01-02"),
+				b.AmountCents-slack, b.AmountCents+slack, b.Account, b.Account, text, text)
+			if err != nil {
+				return 0, err
+			}
+			for rows.Next() {
+				c := candidate{occ: k}
+				if err := rows.Scan(&c.txID, &c.days); err != nil {
+					rows.Close()
+					return 0, err
+				}
+				cands = append(cands, c)
+			}
+			rows.Close()
+			if err := rows.Err(); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	sort.Slice(cands, func(i, j int) bool {
+		a, b := cands[i], cands[j]
+		switch {
+		case a.days != b.days:
+			return a.days < b.days
+		case a.txID != b.txID:
+			return a.txID < b.txID
+		case a.occ.bill != b.occ.bill:
+			return a.occ.bill < b.occ.bill
+		}
+		return a.occ.due < b.occ.due
+	})
+	usedOcc := map[occKey]bool{}
+	usedTx := map[int64]bool{}
+	n := 0
+	for _, c := range cands {
+		if usedOcc[c.occ] || usedTx[c.txID] {
+			continue
+		}
+		if _, err := q.ExecContext(ctx, `INSERT INTO bill_payments (bill_id, due_date, tx_id) VALUES (?,?,?)`,
+			c.occ.bill, c.occ.due, c.txID); err != nil {
+			return n, err
+		}
+		usedOcc[c.occ], usedTx[c.txID] = true, true
+		n++
+	}
+	return n, nil
+}
+
+// likeEscape escapes s for a LIKE pattern with ESCAPE '\'.
+func likeEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// MatchedTxIDs is every transaction that paid a bill.
+func MatchedTxIDs(ctx context.Context, q db.Querier) (map[int64]bool, error) {
+	rows, err := q.QueryContext(ctx, `SELECT tx_id FROM bill_payments WHERE tx_id IS NOT NULL`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := map[int64]bool{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		out[id] = true
+	}
+	return out, rows.Err()
+}
+
+// date is t's calendar day, as due dates are kept.
+func date(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func abs(c int64) int64 {
+	if c < 0 {
+		return -c
+	}
+	return c
+}
@@ -0,0 +1,164 @@
+package bills
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/anthurium-ai/personal-finance/internal/db"
+)
+
+func day(s string) time.Time {
+	d, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func TestOccurrences(t *testing.T) {
+	tests := []struct {
+		name     string
+		bill     Bill
+		from, to string
+		want     []string
+	}{
+		{
+			name: "monthly on the 31st clamps to short months",
+			bill: Bill{Rule: RuleMonthly, Interval: 1, Day: 31, Start: day("2026-01-31")},
+			from: "2026-01-01", to: "2026-05-01",
+			want: []string{"2026-01-31", "2026-02-28", "2026-03-31", "2026-04-30"},
+		},
+		{
+			name: "monthly starts at the first due date on or after the start",
+			bill: Bill{Rule: RuleMonthly, Interval: 1, Day: 5, Start: day("2026-01-20")},
+			from: "2026-01-01", to: "2026-04-01",
+			want: []string{"2026-02-05", "2026-03-05"},
+		},
+		{
+			name: "quarterly within the window",
+			bill: Bill{Rule: RuleMonthly, Interval: 3, Day: 15, Start: day("2025-08-15")},
+			from: "2026-01-01", to: "2027-01-01",
+			want: []string{"2026-02-15", "2026-05-15", "2026-08-15", "2026-11-15"},
+		},
+		{
+			name: "fortnightly",
+			bill: Bill{Rule: RuleWeekly, Interval: 2, Start: day("2026-03-02")},
+			from: "2026-03-01", to: "2026-04-13",
+			want: []string{"2026-03-02", "2026-03-16", "2026-03-30"},
+		},
+		{
+			name: "yearly from 29 February",
+			bill: Bill{Rule: RuleYearly, Interval: 1, Start: day("2024-02-29")},
+			from: "2024-01-01", to: "2029-01-01",
+			want: []string{"2024-02-29", "2025-02-28", "2026-02-28", "2027-02-28", "2028-02-29"},
+		},
+		{
+			name: "last business day skips weekends",
+			bill: Bill{Rule: RuleLastBusinessDay, Interval: 1, Start: day("2026-01-01")},
+			from: "2026-01-01", to: "2026-06-01",
+			// 31 Jan and 28 Feb are Saturdays, 31 May a Sunday
+			want: []string{"2026-01-30", "2026-02-27", "2026-03-31", "2026-04-30", "2026-05-29"},
+		},
+		{
+			name: "last business day every second month",
+			bill: Bill{Rule: RuleLastBusinessDay, Interval: 2, Start: day("2026-01-01")},
+			from: "2026-01-01", to: "2026-07-01",
+			want: []string{"2026-01-30", "2026-03-31", "2026-05-29"},
+		},
+		{
+			name: "end date is inclusive",
+			bill: Bill{Rule: RuleMonthly, Interval: 1, Day: 1, Start: day("2026-01-01"), End: day("2026-03-01")},
+			from: "2026-01-01", to: "2027-01-01",
+			want: []string{"2026-01-01", "2026-02-01", "2026-03-01"},
+		},
+		{
+			name: "unknown rule",
+			bill: Bill{Rule: "fortnightly-ish", Interval: 1, Start: day("2026-01-01")},
+			from: "2026-01-01", to: "2027-01-01",
+		},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, d := range tt.bill.Occurrences(day(tt.from), day(tt.to)) {
+			got = append(got, d.Format("2006-01-02"))
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	ctx := context.Background()
+	d, err := db.Open(filepath.Join(t.TempDir(), "pf.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if err := db.Migrate(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+
+	gym, err := Create(ctx, d, Bill{Name: "Gym", AmountCents: -2000, Rule: RuleWeekly, Interval: 1, Start: day("2026-03-02"), ToleranceDays: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rent, err := Create(ctx, d, Bill{Name: "Rent", AmountCents: -200000, Account: "everyday", MatchText: "A_B", Rule: RuleMonthly,
+		Day: 1, Start: day("2026-01-01"), ToleranceDays: 3, TolerancePct: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tx := range []struct {
+		date, account, merchant, details string
+		cents                            int64
+	}{
+		{"2026-03-06", "card", "GYM CO", "", -2000},                     // 1: closer to the 9th than the 2nd
+		{"2026-03-16", "card", "GYM CO", "", -2000},                     // 2
+		{"2026-03-16", "card", "GYM CO", "", -2500},                     // 3: amount is off
+		{"2026-01-01", "savings", "RENT", "A_B REALTY", -200000},        // 4: wrong account
+		{"2026-02-02", "everyday", "RENT", "A_B REALTY REF 1", -195000}, // 5: within 5%
+		{"2026-03-01", "everyday", "AXB REALTY", "", -200000},           // 6: _ is not a wildcard
+	} {
+		if _, err := d.Exec(`INSERT INTO transactions (txn_date, amount_cents, account, merchant_norm, details, row_hash) VALUES (?,?,?,?,?,hex(randomblob(8)))`,
+			tx.date, tx.cents, tx.account, tx.merchant, tx.details); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	n, err := Match(ctx, d, day("2026-03-20"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("matched %d, want 3", n)
+	}
+	rows, err := d.Query(`SELECT bill_id, due_date, tx_id FROM bill_payments ORDER BY bill_id, due_date`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	type pay struct {
+		bill int64
+		due  string
+		tx   int64
+	}
+	var got []pay
+	for rows.Next() {
+		var p pay
+		if err := rows.Scan(&p.bill, &p.due, &p.tx); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, p)
+	}
+	want := []pay{{gym, "2026-03-09", 1}, {gym, "2026-03-16", 2}, {rent, "2026-02-01", 5}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("payments = %v, want %v", got, want)
+	}
+
+	if n, err := Match(ctx, d, day("2026-03-20")); err != nil || n != 0 {
+		t.Errorf("second match = %d, %v, want 0", n, err)
+	}
+}
@@ -0,0 +1,161 @@
+// Package budget tracks spending against per-category budgets.
+package budget
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/anthurium-ai/personal-finance/internal/db"
+)
+
+// Period is how long one budget amount lasts.
+type Period struct {
+	Name   string
+	Months int
+}
+
+var Periods = []Period{
+	{Name: "monthly", Months: 1},
+	{Name: "quarterly", Months: 3},
+	{Name: "annual", Months: 12},
+}
+
+// PeriodByName returns the named period.
+func PeriodByName(name string) (Period, bool) {
+	for _, p := range Periods {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Period{}, false
+}
+
+// Budget is one row of budgets: AmountCents to spend on Category each
+// Period, from StartMonth until a later budget for the category replaces it.
+type Budget struct {
+	ID          int64
+	Category    string
+	Period      string
+	AmountCents int64
+	StartMonth  string // YYYY-MM
+}
+
+// List returns every budget, by category then start month.
+func List(ctx context.Context, q db.Querier) ([]Budget, error) {
+	rows, err := q.QueryContext(ctx, `SELECT id, category_norm, period, amount_cents, start_month FROM budgets ORDER BY category_norm, start_month`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Budget
+	for rows.Next() {
+		var b Budget
+		if err := rows.Scan(&b.ID, &b.Category, &b.Period, &b.AmountCents, &b.StartMonth); err != nil {
+			return nil, err
+		}
+		out = append(out, b)
+	}
+	return out, rows.Err()
+}
+
+// Set stores b, replacing the category's budget starting the same month.
+func Set(ctx context.Context, q db.Querier, b Budget) error {
+	b.Category = strings.TrimSpace(b.Category)
+	if b.Category == "" {
+		return fmt.Errorf("category is required")
+	}
+	if _, ok := PeriodByName(b.Period); !ok {
+		return fmt.Errorf("unknown period %q", b.Period)
+	}
+	if b.AmountCents <= 0 {
+		return fmt.Errorf("amount must be more than zero")
+	}
+	if _, err := time.Parse("2006-01", b.StartMonth); err != nil {
+		return fmt.Errorf("start month must be YYYY-MM")
+	}
+	_, err := q.ExecContext(ctx, `INSERT INTO budgets (category_norm, period, amount_cents, start_month) VALUES (?,?,?,?)
+		ON CONFLICT(category_norm, start_month) DO UPDATE SET period=excluded.period, amount_cents=excluded.amount_cents,
+			updated_at=strftime('%Y-%m-%dT%H:%M:%fZ','now')`, b.Category, b.Period, b.AmountCents, b.StartMonth)
+	return err
+}
+
+func Delete(ctx context.Context, q db.Querier, id int64) error {
+	_, err := q.ExecContext(ctx, `DELETE FROM budgets WHERE id=?`, id)
+	return err
+}
+
+// Status is a budget's standing in the period containing a given day.
+type Status struct {
+	Budget
+	From, To       time.Time // the period, To exclusive
+	SpentCents     int64
+	RemainingCents int64 // negative when over
+	// Elapsed is the fraction of the period gone by, and ExpectedCents the
+	// spend that would keep exactly to the budget at that point.
+	Elapsed       float64
+	ExpectedCents int64
+}
+
+func (s *Status) Over() bool { return s.SpentCents > s.AmountCents }
+
+// OverPace reports spending ahead of an even spread over the period.
+func (s *Status) OverPace() bool { return s.SpentCents > s.ExpectedCents }
+
+// UsedRatio is spend as a fraction of the budget.
+func (s *Status) UsedRatio() float64 { return float64(s.SpentCents) / float64(s.AmountCents) }
+
+// ProjectedCents is the period's spend if it carries on at the current pace.
+func (s *Status) ProjectedCents() int64 {
+	if s.Elapsed <= 0 {
+		return s.SpentCents
+	}
+	return int64(float64(s.SpentCents) / s.Elapsed)
+}
+
+// Track returns the budgets in effect on asOf, each with spend over its
+// period up to and including asOf.
+func Track(ctx context.Context, q db.Querier, asOf time.Time) ([]Status, error) {
+	all, err := List(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	month := asOf.Format("2006-01")
+	current := map[string]Budget{}
+	for _, b := range all {
+		// sorted by start month, so the latest one started wins
+		if b.StartMonth <= month {
+			current[b.Category] = b
+		}
+	}
+
+	day := time.Date(asOf.Year(), asOf.Month(), asOf.Day(), 0, 0, 0, 0, time.UTC)
+	out := make([]Status, 0, len(current))
+	for _, b := range current {
+		p, _ := PeriodByName(b.Period)
+		start, err := time.Parse("2006-01", b.StartMonth)
+		if err != nil {
+			continue
+		}
+		// periods run back to back from the start month
+		n := (asOf.Year()-start.Year())*12 + int(asOf.Month()-start.Month())
+		s := Status{Budget: b}
+		s.From = start.AddDate(0, n-n%p.Months, 0)
+		s.To = s.From.AddDate(0, p.Months, 0)
+
+		if err := q.QueryRowContext(ctx, `
+			SELECT COALESCE(SUM(-amount_cents),0) FROM transactions
+			WHERE amount_cents < 0 AND txn_date >= ? AND txn_date <= ? AND `+db.CategorySQL("")+` = ?`,
+			s.From.Format("2006-01-02"), day.Format("2006-01-02"), b.Category).Scan(&s.SpentCents); err != nil {
+			return nil, err
+		}
+		s.RemainingCents = b.AmountCents - s.SpentCents
+		s.Elapsed = (day.Sub(s.From).Hours()/24 + 1) / (s.To.Sub(s.From).Hours() / 24)
+		s.ExpectedCents = int64(float64(b.AmountCents) * s.Elapsed)
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Category < out[j].Category })
+	return out, nil
+}
@@ -0,0 +1,121 @@
+package budget
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/anthurium-ai/personal-finance/internal/db"
+)
+
+func openDB(t *testing.T) *sql.DB {
+	t.Helper()
+	d, err := db.Open(filepath.Join(t.TempDir(), "pf.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Close() })
+	if err := db.Migrate(context.Background(), d); err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestSet(t *testing.T) {
+	ctx := context.Background()
+	d := openDB(t)
+	tests := []struct {
+		name string
+		b    Budget
+		ok   bool
+	}{
+		{"monthly", Budget{Category: " Dining ", Period: "monthly", AmountCents: 50000, StartMonth: "2026-01"}, true},
+		{"same month replaces", Budget{Category: "Dining", Period: "quarterly", AmountCents: 150000, StartMonth: "2026-01"}, true},
+		{"no category", Budget{Period: "monthly", AmountCents: 100, StartMonth: "2026-01"}, false},
+		{"unknown period", Budget{Category: "Dining", Period: "weekly", AmountCents: 100, StartMonth: "2026-01"}, false},
+		{"zero amount", Budget{Category: "Dining", Period: "monthly", StartMonth: "2026-01"}, false},
+		{"bad month", Budget{Category: "Dining", Period: "monthly", AmountCents: 100, StartMonth: "2026-13"}, false},
+	}
+	for _, tt := range tests {
+		if err := Set(ctx, d, tt.b); (err == nil) != tt.ok {
+			t.Errorf("%s: Set = %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+	all, err := List(ctx, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 1 || all[0].Category != "Dining" || all[0].Period != "quarterly" || all[0].AmountCents != 150000 {
+		t.Errorf("List = %+v, want one quarterly Dining budget", all)
+	}
+}
+
+func TestTrack(t *testing.T) {
+	ctx := context.Background()
+	d := openDB(t)
+	for _, b := range []Budget{
+		{Category: "Dining", Period: "monthly", AmountCents: 50000, StartMonth: "2026-01"},
+		{Category: "Travel", Period: "quarterly", AmountCents: 600000, StartMonth: "2025-11"},
+		{Category: "Groceries", Period: "monthly", AmountCents: 40000, StartMonth: "2026-01"},
+		{Category: "Groceries", Period: "monthly", AmountCents: 60000, StartMonth: "2026-03"}, // replaces the one before
+		{Category: "Gifts", Period: "monthly", AmountCents: 10000, StartMonth: "2026-04"},     // not started yet
+	} {
+		if err := Set(ctx, d, b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, tx := range []struct {
+		date, norm, raw string
+		cents           int64
+	}{
+		{"2026-02-28", "Dining", "", -30000}, // last month
+		{"2026-03-01", "Dining", "", -10000},
+		{"2026-03-10", "Dining", "", -15000},
+		{"2026-03-11", "Dining", "", -50000},  // after asOf
+		{"2026-01-31", "Travel", "", -100000}, // previous quarter
+		{"2026-02-05", "Travel", "", -200000},
+		{"2026-03-02", "Travel", "", 50000},     // refunds don't count
+		{"2026-03-03", "", "Groceries", -70000}, // the bank's category stands in
+	} {
+		if _, err := d.Exec(`INSERT INTO transactions (txn_date, amount_cents, category_norm, category_raw, row_hash) VALUES (?,?,?,?,hex(randomblob(8)))`,
+			tx.date, tx.cents, tx.norm, tx.raw); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := Track(ctx, d, time.Date(2026, 3, 10, 18, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		cat       string
+		from, to  string
+		spent     int64
+		remaining int64
+		elapsed   float64
+		over      bool
+		overPace  bool
+	}{
+		{"Dining", "2026-03-01", "2026-04-01", 25000, 25000, 10.0 / 31, false, true},
+		{"Groceries", "2026-03-01", "2026-04-01", 70000, -10000, 10.0 / 31, true, true},
+		{"Travel", "2026-02-01", "2026-05-01", 200000, 400000, 38.0 / 89, false, false},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Track = %+v, want %d budgets", got, len(want))
+	}
+	for i, w := range want {
+		s := got[i]
+		if s.Category != w.cat || s.From.Format("2006-01-02") != w.from || s.To.Format("2006-01-02") != w.to ||
+			s.SpentCents != w.spent || s.RemainingCents != w.remaining || math.Abs(s.Elapsed-w.elapsed) > 1e-9 ||
+			s.Over() != w.over || s.OverPace() != w.overPace {
+			t.Errorf("%s: got %s..%s spent %d remaining %d elapsed %.4f over %v pace %v",
+				w.cat, s.From.Format("2006-01-02"), s.To.Format("2006-01-02"), s.SpentCents, s.RemainingCents, s.Elapsed, s.Over(), s.OverPace())
+		}
+	}
+	if p := got[0].ProjectedCents(); p != 77500 {
+		t.Errorf("Dining projected %d, want 77500", p)
+	}
+}
@@ -0,0 +1,153 @@
+package classify
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+)
+
+// manualSQL matches rows whose category a human set or confirmed. Rows edited before
+// category_source existed are recognised by a category that differs from the bank's.
+const manualSQL = `(COALESCE(category_source,'') = 'manual' OR category_confirmed = 1 OR (category_source IS NULL AND COALESCE(category_norm,'') != '' AND category_norm != COALESCE(category_raw,'')))`
+
+// uncategorisedSQL matches rows still carrying only the bank category (or none).
+const uncategorisedSQL = `(COALESCE(category_source,'bank') = 'bank' AND NOT ` + manualSQL + `)`
+
+type ApplyOptions struct {
+	From string // YYYY-MM-DD inclusive, empty = no lower bound
+	To   string // YYYY-MM-DD inclusive, empty = no upper bound
+
+	// UncategorisedOnly limits the run to rows with only the bank category.
+	// Otherwise rows previously set by overrides/rules are re-evaluated too.
+	UncategorisedOnly bool
+	DryRun            bool
+}
+
+// Change is one row whose category the job changed (or would change).
+type Change struct {
+	TxID       int64
+	Date       string
+	Merchant   string
+	Details    string
+	Old        string
+	New        string
+	Source     string
+	Reason     string
+	RuleID     int64
+	OverrideID int64
+	Confidence float64
+	Review     bool // below the auto-apply threshold: queued for review, not applied
+}
+
+type ApplyResult struct {
+	Scanned       int
+	Changed       int // applied, or would be
+	Queued        int // sent to review, or would be
+	Unchanged     int
+	NoMatch       int
+	SkippedManual int
+	Changes       []Change
+}
+
+// ApplyRules runs overrides and rules over existing transactions and writes
+// the resulting categories, or queues them for review when the auto-apply
+// policy says so. Manually-set categories are never touched.
+func ApplyRules(ctx context.Context, db *sql.DB, opts ApplyOptions) (*ApplyResult, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	c, err := Load(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := LoadPolicy(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	where := []string{"1=1"}
+	var args []any
+	if opts.From != "" {
+		where = append(where, "txn_date >= ?")
+		args = append(args, opts.From)
+	}
+	if opts.To != "" {
+		where = append(where, "txn_date <= ?")
+		args = append(args, opts.To)
+	}
+	if opts.UncategorisedOnly {
+		where = append(where, uncategorisedSQL)
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, txn_date, COALESCE(NULLIF(merchant_norm,''), COALESCE(merchant_raw,'')), COALESCE(details,''),
+		       amount_cents, COALESCE(account,''), COALESCE(category_norm,''), COALESCE(category_source,''), `+manualSQL+`
+		FROM transactions
+		WHERE `+strings.Join(where, " AND ")+`
+		ORDER BY txn_date ASC, id ASC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	res := &ApplyResult{}
+	for rows.Next() {
+		var ch Change
+		var in Input
+		var source string
+		var manual bool
+		if err := rows.Scan(&ch.TxID, &ch.Date, &ch.Merchant, &ch.Details, &in.AmountCents, &in.Account, &ch.Old, &source, &manual); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		res.Scanned++
+		if manual {
+			res.SkippedManual++
+			continue
+		}
+		in.Merchant, in.Details = ch.Merchant, ch.Details
+		s := c.Suggest(in)
+		if s == nil {
+			res.NoMatch++
+			continue
+		}
+		if s.Category == ch.Old && s.Source == source {
+			res.Unchanged++
+			continue
+		}
+		ch.New, ch.Source, ch.Reason = s.Category, s.Source, s.Reason
+		ch.RuleID, ch.OverrideID, ch.Confidence = s.RuleID, s.OverrideID, s.Confidence
+		ch.Review = !policy.Auto(s)
+		res.Changes = append(res.Changes, ch)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if opts.DryRun {
+		for _, ch := range res.Changes {
+			if ch.Review {
+				res.Queued++
+			} else {
+				res.Changed++
+			}
+		}
+		return res, nil
+	}
+	for _, ch := range res.Changes {
+		s := &Suggestion{Category: ch.New, Reason: ch.Reason, Source: ch.Source, RuleID: ch.RuleID, OverrideID: ch.OverrideID, Confidence: ch.Confidence}
+		out, err := Route(ctx, tx, policy, ch.TxID, 0, s)
+		if err != nil {
+			return nil, err
+		}
+		switch out {
+		case Applied:
+			res.Changed++
+		case Queued:
+			res.Queued++
+		}
+	}
+	return res, tx.Commit()
+}
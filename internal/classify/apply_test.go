@@ -0,0 +1,96 @@
+package classify
+
+import (
+	"context"
+	"testing"
+)
+
+func TestApplyRules(t *testing.T) {
+	ctx := context.Background()
+	d := openDB(t)
+	rule, err := d.Exec(`INSERT INTO category_rules (match_contains, category_norm) VALUES ('NETFLIX','Streaming')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ruleID, _ := rule.LastInsertId()
+	override, err := d.Exec(`INSERT INTO merchant_category_overrides (merchant_norm, category_norm) VALUES ('KMART','Household')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	overrideID, _ := override.LastInsertId()
+
+	var ids []int64
+	for _, tx := range []struct {
+		date, merchant, norm, source string
+		confirmed                    bool
+	}{
+		{"2026-01-10", "NETFLIX", "Entertainment", "", false}, // 1: the rule applies
+		{"2026-01-11", "KMART", "Shopping", "bank", false},    // 2: the override applies
+		{"2026-01-12", "CAFE 21", "Dining", "", false},        // 3: nothing matches
+		{"2026-01-13", "NETFLIX", "Dining", "manual", false},  // 4: set by hand
+		{"2026-01-14", "NETFLIX", "Streaming", "rule", false}, // 5: already the rule's
+		{"2025-12-31", "NETFLIX", "Entertainment", "", false}, // 6: before From
+		{"2026-01-15", "KMART", "Shopping", "bank", true},     // 7: confirmed
+	} {
+		res, err := d.Exec(`INSERT INTO transactions (txn_date, amount_cents, merchant_norm, category_raw, category_norm, category_source, category_confirmed, row_hash)
+			VALUES (?, -1000, ?, ?, ?, NULLIF(?,''), ?, hex(randomblob(8)))`, tx.date, tx.merchant, tx.norm, tx.norm, tx.source, tx.confirmed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		id, _ := res.LastInsertId()
+		ids = append(ids, id)
+	}
+
+	// a dry run reports without writing
+	res, err := ApplyRules(ctx, d, ApplyOptions{From: "2026-01-01", DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Scanned != 6 || res.Changed != 2 || res.Queued != 0 || res.Unchanged != 1 || res.NoMatch != 1 || res.SkippedManual != 2 {
+		t.Errorf("dry run = %+v", *res)
+	}
+	if len(res.Changes) != 2 || res.Changes[0].TxID != ids[0] || res.Changes[0].RuleID != ruleID ||
+		res.Changes[1].TxID != ids[1] || res.Changes[1].OverrideID != overrideID {
+		t.Errorf("dry run changes = %+v", res.Changes)
+	}
+	var cat string
+	if err := d.QueryRow(`SELECT category_norm FROM transactions WHERE id=?`, ids[0]).Scan(&cat); err != nil || cat != "Entertainment" {
+		t.Errorf("dry run wrote %q (%v)", cat, err)
+	}
+
+	// overrides held for review; only bank-categorised rows
+	if err := SavePolicy(ctx, d, Threshold{Source: SourceOverride, Auto: false}); err != nil {
+		t.Fatal(err)
+	}
+	res, err = ApplyRules(ctx, d, ApplyOptions{From: "2026-01-01", UncategorisedOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Scanned != 3 || res.Changed != 1 || res.Queued != 1 || res.NoMatch != 1 {
+		t.Errorf("run = %+v", *res)
+	}
+	for _, c := range []struct {
+		tx      int64
+		cat     string
+		source  string
+		rule    int64
+		pending string
+	}{
+		{ids[0], "Streaming", SourceRule, ruleID, ""},
+		{ids[1], "Shopping", "bank", 0, SourceOverride},
+		{ids[2], "Dining", "", 0, ""},
+		{ids[5], "Entertainment", "", 0, ""},
+	} {
+		var cat, source, pending string
+		var rule int64
+		if err := d.QueryRow(`SELECT category_norm, COALESCE(category_source,''), COALESCE(category_rule_id,0),
+			COALESCE((SELECT source FROM classification_suggestions s WHERE s.tx_id=transactions.id AND s.status='pending'),'')
+			FROM transactions WHERE id=?`, c.tx).Scan(&cat, &source, &rule, &pending); err != nil {
+			t.Fatal(err)
+		}
+		if cat != c.cat || source != c.source || rule != c.rule || pending != c.pending {
+			t.Errorf("tx %d: %s from %q rule %d pending %q; want %s from %q rule %d pending %q",
+				c.tx, cat, source, rule, pending, c.cat, c.source, c.rule, c.pending)
+		}
+	}
+}
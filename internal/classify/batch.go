@@ -0,0 +1,295 @@
+package classify
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/anthurium-ai/personal-finance/internal/db"
+)
+
+// JobBatchLLM is the jobs.kind of a batch LLM run.
+const JobBatchLLM = "batch_llm"
+
+type BatchOptions struct {
+	BatchSize int // merchants per prompt
+	Examples  int // few-shot examples from confirmed transactions
+	Limit     int // max merchant groups per run, 0 = all
+}
+
+type BatchResult struct {
+	Merchants   int
+	Cached      int // merchant groups answered from llm_cache
+	Applied     int // transactions categorised under the auto-apply policy
+	Suggestions int // transactions queued for review
+	Failed      int // merchant groups the LLM returned nothing usable for
+}
+
+// merchantGroup is the uncategorised transactions of one merchant.
+type merchantGroup struct {
+	merchant    string
+	details     string // a sample
+	amountCents int64  // a sample
+	txIDs       []int64
+}
+
+// batchSchema is the response contract for a batch prompt.
+var batchSchema = json.RawMessage(`{
+  "type": "object",
+  "properties": {
+    "results": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "properties": {
+          "id": {"type": "integer"},
+          "category": {"type": "string", "minLength": 1},
+          "reason": {"type": "string"},
+          "confidence": {"type": "string", "enum": ["low", "med", "high"]}
+        },
+        "required": ["id", "category", "reason", "confidence"],
+        "additionalProperties": false
+      }
+    }
+  },
+  "required": ["results"],
+  "additionalProperties": false
+}`)
+
+type batchReply struct {
+	Results []struct {
+		ID int `json:"id"`
+		LLMSuggestion
+	} `json:"results"`
+}
+
+// BatchLLM sends uncategorised transactions to the LLM grouped by merchant,
+// several merchants per prompt. Answers are applied or queued for review
+// according to the auto-apply policy. Transactions that already have a
+// pending suggestion are skipped.
+func BatchLLM(ctx context.Context, d *sql.DB, p LLMProvider, jobID int64, opts BatchOptions, progress func(done, total int, msg string)) (*BatchResult, error) {
+	if p == nil {
+		return nil, fmt.Errorf("no LLM provider configured")
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 20
+	}
+	if opts.Examples < 0 {
+		opts.Examples = 0
+	}
+
+	groups, err := uncategorisedGroups(ctx, d, opts.Limit)
+	if err != nil {
+		return nil, err
+	}
+	cats, err := KnownCategories(ctx, d)
+	if err != nil {
+		return nil, err
+	}
+	shots, err := fewShotExamples(ctx, d, opts.Examples)
+	if err != nil {
+		return nil, err
+	}
+	red, err := LoadRedactor(ctx, d)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := LoadPolicy(ctx, d)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &BatchResult{Merchants: len(groups)}
+	queue := func(g merchantGroup, s *LLMSuggestion) error {
+		sug := &Suggestion{Category: s.Category, Reason: s.Reason, Source: SourceLLM, Confidence: LLMConfidence(s.Confidence)}
+		for _, txID := range g.txIDs {
+			out, err := Route(ctx, d, policy, txID, jobID, sug)
+			if err != nil {
+				return err
+			}
+			switch out {
+			case Applied:
+				res.Applied++
+			case Queued:
+				res.Suggestions++
+			}
+		}
+		return nil
+	}
+
+	// merchants answered before never leave the process again
+	var uncached []merchantGroup
+	for _, g := range groups {
+		s, err := cachedSuggestion(ctx, d, g.merchant)
+		if err != nil {
+			return res, err
+		}
+		if s == nil {
+			uncached = append(uncached, g)
+			continue
+		}
+		if err := queue(g, s); err != nil {
+			return res, err
+		}
+		res.Cached++
+	}
+	groups = uncached
+
+	progress(0, len(groups), fmt.Sprintf("starting, %d merchants answered from cache", res.Cached))
+	for start := 0; start < len(groups); start += opts.BatchSize {
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+		end := min(start+opts.BatchSize, len(groups))
+		batch := groups[start:end]
+
+		reply, err := p.Complete(ctx, batchPrompt(red, batch, cats, shots), batchSchema)
+		var parsed batchReply
+		if err == nil {
+			err = decodeStrict(reply, &parsed)
+		}
+		if err != nil {
+			// one bad batch shouldn't sink the run
+			res.Failed += len(batch)
+			progress(end, len(groups), fmt.Sprintf("batch %d-%d failed: %v", start+1, end, truncate(err.Error(), 120)))
+			continue
+		}
+
+		answered := map[int]bool{}
+		for _, r := range parsed.Results {
+			i := r.ID - 1
+			if i < 0 || i >= len(batch) || answered[i] {
+				continue
+			}
+			s := r.LLMSuggestion
+			if s.validate() != nil {
+				continue
+			}
+			answered[i] = true
+			if err := storeSuggestion(ctx, d, p.Name(), batch[i].merchant, &s); err != nil {
+				return res, err
+			}
+			if err := queue(batch[i], &s); err != nil {
+				return res, err
+			}
+		}
+		res.Failed += len(batch) - len(answered)
+		progress(end, len(groups), fmt.Sprintf("%d applied, %d suggestions queued", res.Applied, res.Suggestions))
+	}
+	return res, nil
+}
+
+func uncategorisedGroups(ctx context.Context, q db.Querier, limit int) ([]merchantGroup, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT id, COALESCE(NULLIF(merchant_norm,''), COALESCE(merchant_raw,'')), COALESCE(details,''), amount_cents
+		FROM transactions
+		WHERE `+uncategorisedSQL+`
+		  AND NOT EXISTS (SELECT 1 FROM classification_suggestions s WHERE s.tx_id = transactions.id AND s.status = 'pending')
+		ORDER BY txn_date DESC, id DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []merchantGroup
+	idx := map[string]int{}
+	for rows.Next() {
+		var id, amt int64
+		var mer, details string
+		if err := rows.Scan(&id, &mer, &details, &amt); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(mer) == "" {
+			continue
+		}
+		i, ok := idx[mer]
+		if !ok {
+			if limit > 0 && len(out) >= limit {
+				continue
+			}
+			i = len(out)
+			idx[mer] = i
+			out = append(out, merchantGroup{merchant: mer, details: details, amountCents: amt})
+		}
+		out[i].txIDs = append(out[i].txIDs, id)
+	}
+	return out, rows.Err()
+}
+
+// KnownCategories lists categories in use, for prompts. Uncategorised
+// transactions don't add a category.
+func KnownCategories(ctx context.Context, q db.Querier) ([]string, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT DISTINCT `+db.CategorySQL("")+` AS c
+		FROM transactions WHERE COALESCE(category_norm,'') != '' OR COALESCE(category_raw,'') != ''
+		ORDER BY c LIMIT 200`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var c string
+		if err := rows.Scan(&c); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+type fewShot struct {
+	merchant, details, category string
+}
+
+// fewShotExamples picks one recent confirmed transaction per category.
+func fewShotExamples(ctx context.Context, q db.Querier, n int) ([]fewShot, error) {
+	if n == 0 {
+		return nil, nil
+	}
+	rows, err := q.QueryContext(ctx, `
+		SELECT COALESCE(merchant_norm,''), COALESCE(details,''), category_norm
+		FROM transactions t
+		WHERE category_confirmed = 1 AND COALESCE(category_norm,'') != ''
+		  AND id = (SELECT MAX(id) FROM transactions u WHERE u.category_confirmed = 1 AND u.category_norm = t.category_norm)
+		ORDER BY category_set_at DESC
+		LIMIT ?`, n)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []fewShot
+	for rows.Next() {
+		var f fewShot
+		if err := rows.Scan(&f.merchant, &f.details, &f.category); err != nil {
+			return nil, err
+		}
+		out = append(out, f)
+	}
+	return out, rows.Err()
+}
+
+func batchPrompt(red *Redactor, batch []merchantGroup, cats []string, shots []fewShot) string {
+	var b strings.Builder
+	b.WriteString("You are helping classify personal finance transactions by merchant.\n\n")
+	b.WriteString("Categories (pick one of these unless none fits):\n")
+	b.WriteString(strings.Join(cats, ", "))
+	b.WriteString("\n\n")
+	if len(shots) > 0 {
+		b.WriteString("Examples of how we categorise:\n")
+		for _, s := range shots {
+			fmt.Fprintf(&b, "- %s | %s => %s\n", red.Redact(s.merchant), red.Redact(s.details), s.category)
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("Merchants to classify (id. merchant | sample details | sample amount in cents, negative means spend | transactions):\n")
+	for i, g := range batch {
+		fmt.Fprintf(&b, "%d. %s | %s | %d | %d\n", i+1, red.Redact(g.merchant), red.Redact(g.details), g.amountCents, len(g.txIDs))
+	}
+	b.WriteString(`
+Return JSON only, one result per merchant id:
+{"results":[{"id":1,"category":"...","reason":"...","confidence":"low|med|high"}]}
+`)
+	return b.String()
+}
@@ -0,0 +1,110 @@
+package classify
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+)
+
+func addUncategorised(t *testing.T, d *sql.DB, date, merchant string) int64 {
+	t.Helper()
+	res, err := d.Exec(`INSERT INTO transactions (txn_date, amount_cents, merchant_norm, category_raw, row_hash)
+		VALUES (?, -1000, ?, '', ?)`, date, merchant, date+merchant)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, _ := res.LastInsertId()
+	return id
+}
+
+func TestBatchLLM(t *testing.T) {
+	ctx := context.Background()
+	d := openDB(t)
+
+	addTx(t, d, "WOOLWORTHS", "WOOLWORTHS 1234", -8000, "Groceries")
+	kmart := addUncategorised(t, d, "2026-03-06", "KMART")
+	netflix := []int64{addUncategorised(t, d, "2026-03-05", "NETFLIX"), addUncategorised(t, d, "2026-03-04", "NETFLIX")}
+	spotify := addUncategorised(t, d, "2026-03-03", "SPOTIFY")
+	agl := addUncategorised(t, d, "2026-03-02", "AGL")
+	uber := addUncategorised(t, d, "2026-03-01", "UBER")
+
+	// KMART already waits for review; UBER was answered before
+	if _, err := d.Exec(`INSERT INTO classification_suggestions (tx_id, source, category_norm) VALUES (?, 'model', 'Household')`, kmart); err != nil {
+		t.Fatal(err)
+	}
+	if err := storeSuggestion(ctx, d, "fake", "uber", &LLMSuggestion{Category: "Transport", Reason: "rides", Confidence: "med"}); err != nil {
+		t.Fatal(err)
+	}
+
+	p := &FakeProvider{Replies: []string{
+		// batch 1 is NETFLIX, SPOTIFY; repeated and unknown ids are ignored
+		`{"results":[{"id":1,"category":"Streaming","reason":"video","confidence":"high"},
+			{"id":2,"category":"Music","reason":"audio","confidence":"low"},
+			{"id":1,"category":"Other","reason":"again","confidence":"high"},
+			{"id":7,"category":"Other","reason":"no such merchant","confidence":"high"}]}`,
+		// batch 2 is AGL
+		`not json`,
+	}}
+	var calls int
+	res, err := BatchLLM(ctx, d, p, 0, BatchOptions{BatchSize: 2, Examples: 5}, func(done, total int, msg string) { calls++ })
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := BatchResult{Merchants: 4, Cached: 1, Applied: 2, Suggestions: 2, Failed: 1}
+	if *res != want {
+		t.Errorf("result = %+v, want %+v", *res, want)
+	}
+	if len(p.Prompts) != 2 || calls != 3 {
+		t.Fatalf("%d prompts and %d progress calls, want 2 and 3", len(p.Prompts), calls)
+	}
+	first := p.Prompts[0]
+	if !strings.Contains(first, "NETFLIX") || !strings.Contains(first, "SPOTIFY") || strings.Contains(first, "KMART") || strings.Contains(first, "UBER") {
+		t.Errorf("first prompt has the wrong merchants:\n%s", first)
+	}
+	if !strings.Contains(first, "Groceries") {
+		t.Errorf("first prompt is missing the known categories:\n%s", first)
+	}
+
+	// high confidence applies under the default policy; the rest is queued
+	for _, c := range []struct {
+		tx      int64
+		cat     string
+		pending string
+	}{
+		{netflix[0], "Streaming", ""},
+		{netflix[1], "Streaming", ""},
+		{spotify, "", "Music"},
+		{uber, "", "Transport"},
+		{agl, "", ""},
+	} {
+		var cat, pending string
+		if err := d.QueryRow(`SELECT COALESCE(category_norm,''),
+			COALESCE((SELECT category_norm FROM classification_suggestions WHERE tx_id=transactions.id AND status='pending'),'')
+			FROM transactions WHERE id=?`, c.tx).Scan(&cat, &pending); err != nil {
+			t.Fatal(err)
+		}
+		if cat != c.cat || pending != c.pending {
+			t.Errorf("tx %d: category %q pending %q, want %q and %q", c.tx, cat, pending, c.cat, c.pending)
+		}
+	}
+
+	var cached int
+	if err := d.QueryRow(`SELECT COUNT(*) FROM llm_cache`).Scan(&cached); err != nil || cached != 3 {
+		t.Errorf("llm_cache has %d rows (%v), want 3", cached, err)
+	}
+
+	// a second run only asks about what is still uncategorised and unqueued
+	p2 := &FakeProvider{Replies: []string{`{"results":[{"id":1,"category":"Utilities","reason":"power","confidence":"high"}]}`}}
+	res, err = BatchLLM(ctx, d, p2, 0, BatchOptions{}, func(int, int, string) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (BatchResult{Merchants: 1, Applied: 1}); *res != want {
+		t.Errorf("second run = %+v, want %+v", *res, want)
+	}
+
+	if _, err := BatchLLM(ctx, d, nil, 0, BatchOptions{}, nil); err == nil {
+		t.Error("want an error without a provider")
+	}
+}
@@ -0,0 +1,295 @@
+package classify
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/anthurium-ai/personal-finance/internal/db"
+)
+
+// The local model is a multinomial naive Bayes over tokens of the merchant,
+// details, an amount bucket and the account. It trains on confirmed
+// categories only; model_examples remembers what each transaction contributed
+// so a re-save can be unlearned exactly.
+
+const (
+	// the model stays quiet until it has seen this much
+	modelMinExamples   = 10
+	modelMinCategories = 2
+)
+
+// Features returns the model tokens for a transaction.
+func Features(in Input) []string {
+	seen := map[string]bool{}
+	var out []string
+	add := func(tok string) {
+		if !seen[tok] {
+			seen[tok] = true
+			out = append(out, tok)
+		}
+	}
+	for _, w := range words(in.Merchant) {
+		add("m:" + w)
+	}
+	for _, w := range words(in.Details) {
+		add("d:" + w)
+	}
+	add("amt:" + amountBucket(in.AmountCents))
+	if acct := strings.TrimSpace(in.Account); acct != "" {
+		add("acct:" + acct)
+	}
+	return out
+}
+
+// words lowercases and splits on non-alphanumerics, dropping numbers and 1-letter words.
+func words(s string) []string {
+	var out []string
+	for _, w := range strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len(w) < 2 || strings.IndexFunc(w, unicode.IsLetter) < 0 {
+			continue
+		}
+		out = append(out, w)
+	}
+	return out
+}
+
+func amountBucket(cents int64) string {
+	dir := "out"
+	if cents > 0 {
+		dir = "in"
+	} else {
+		cents = -cents
+	}
+	switch {
+	case cents < 10_00:
+		return dir + ":<10"
+	case cents < 50_00:
+		return dir + ":10-50"
+	case cents < 100_00:
+		return dir + ":50-100"
+	case cents < 500_00:
+		return dir + ":100-500"
+	default:
+		return dir + ":500+"
+	}
+}
+
+// Model is the naive Bayes state loaded from SQLite.
+type Model struct {
+	docs       map[string]int            // category -> examples
+	tokens     map[string]map[string]int // category -> token -> count
+	tokenTotal map[string]int            // category -> sum of token counts
+	vocab      map[string]bool
+	examples   int
+	rejected   map[[2]string]bool // {merchant, category} rejected in review
+}
+
+func newModel() *Model {
+	return &Model{docs: map[string]int{}, tokens: map[string]map[string]int{}, tokenTotal: map[string]int{}, vocab: map[string]bool{},
+		rejected: map[[2]string]bool{}}
+}
+
+// add trains on one example in memory (used by evaluation).
+func (m *Model) add(cat string, features []string) {
+	m.docs[cat]++
+	m.examples++
+	if m.tokens[cat] == nil {
+		m.tokens[cat] = map[string]int{}
+	}
+	for _, f := range features {
+		m.tokens[cat][f]++
+		m.tokenTotal[cat]++
+		m.vocab[f] = true
+	}
+}
+
+// LoadModel reads the trained counts.
+func LoadModel(ctx context.Context, q db.Querier) (*Model, error) {
+	m := newModel()
+
+	rows, err := q.QueryContext(ctx, `SELECT category_norm, COUNT(*) FROM model_examples GROUP BY category_norm`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var cat string
+		var n int
+		if err := rows.Scan(&cat, &n); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		m.docs[cat] = n
+		m.examples += n
+	}
+	rows.Close()
+
+	rows, err = q.QueryContext(ctx, `SELECT merchant_norm, category_norm FROM model_rejections`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var merchant, cat string
+		if err := rows.Scan(&merchant, &cat); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		m.rejected[[2]string{merchant, cat}] = true
+	}
+	rows.Close()
+
+	rows, err = q.QueryContext(ctx, `SELECT category_norm, token, count FROM model_token_counts WHERE count > 0`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var cat, tok string
+		var n int
+		if err := rows.Scan(&cat, &tok, &n); err != nil {
+			return nil, err
+		}
+		if m.tokens[cat] == nil {
+			m.tokens[cat] = map[string]int{}
+		}
+		m.tokens[cat][tok] = n
+		m.tokenTotal[cat] += n
+		m.vocab[tok] = true
+	}
+	return m, rows.Err()
+}
+
+// Examples is the number of transactions the model was trained on.
+func (m *Model) Examples() int { return m.examples }
+
+// Predict returns the most likely category and its posterior probability,
+// or "" when the model has too little data.
+func (m *Model) Predict(features []string) (string, float64) {
+	if m.examples < modelMinExamples || len(m.docs) < modelMinCategories {
+		return "", 0
+	}
+	cats := make([]string, 0, len(m.docs))
+	for c := range m.docs {
+		cats = append(cats, c)
+	}
+	sort.Strings(cats) // deterministic ties
+
+	v := float64(len(m.vocab) + 1)
+	scores := make([]float64, len(cats))
+	best := 0
+	for i, c := range cats {
+		s := math.Log(float64(m.docs[c]) / float64(m.examples))
+		denom := float64(m.tokenTotal[c]) + v
+		for _, f := range features {
+			s += math.Log((float64(m.tokens[c][f]) + 1) / denom)
+		}
+		scores[i] = s
+		if s > scores[best] {
+			best = i
+		}
+	}
+	// softmax for the winner's posterior
+	var sum float64
+	for _, s := range scores {
+		sum += math.Exp(s - scores[best])
+	}
+	return cats[best], 1 / sum
+}
+
+// Rejected is whether cat was rejected for merchant in review.
+func (m *Model) Rejected(merchant, cat string) bool { return m.rejected[[2]string{merchant, cat}] }
+
+// Learn brings the model in line with one transaction: whatever it contributed
+// before is removed, and if its category is confirmed it is added again,
+// lifting any rejection of that category for its merchant.
+func Learn(ctx context.Context, q db.Querier, txID int64) error {
+	var prevCat, prevFeatures string
+	err := q.QueryRowContext(ctx, `SELECT category_norm, features FROM model_examples WHERE tx_id=?`, txID).Scan(&prevCat, &prevFeatures)
+	switch {
+	case err == sql.ErrNoRows:
+	case err != nil:
+		return err
+	default:
+		for _, f := range strings.Split(prevFeatures, "\n") {
+			if _, err := q.ExecContext(ctx, `UPDATE model_token_counts SET count = count - 1 WHERE category_norm=? AND token=?`, prevCat, f); err != nil {
+				return err
+			}
+		}
+		if _, err := q.ExecContext(ctx, `DELETE FROM model_examples WHERE tx_id=?`, txID); err != nil {
+			return err
+		}
+	}
+
+	var in Input
+	var cat string
+	var confirmed bool
+	err = q.QueryRowContext(ctx, `
+		SELECT COALESCE(merchant_norm,''), COALESCE(details,''), amount_cents, COALESCE(account,''),
+		       COALESCE(category_norm,''), category_confirmed
+		FROM transactions WHERE id=?`, txID).Scan(&in.Merchant, &in.Details, &in.AmountCents, &in.Account, &cat, &confirmed)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !confirmed || strings.TrimSpace(cat) == "" {
+		return nil
+	}
+
+	if _, err := q.ExecContext(ctx, `DELETE FROM model_rejections WHERE merchant_norm=? AND category_norm=?`, in.Merchant, cat); err != nil {
+		return err
+	}
+	features := Features(in)
+	if _, err := q.ExecContext(ctx, `INSERT INTO model_examples (tx_id, category_norm, features) VALUES (?,?,?)`, txID, cat, strings.Join(features, "\n")); err != nil {
+		return err
+	}
+	for _, f := range features {
+		if _, err := q.ExecContext(ctx, `INSERT INTO model_token_counts (category_norm, token, count) VALUES (?,?,1)
+			ON CONFLICT(category_norm, token) DO UPDATE SET count = count + 1`, cat, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Retrain rebuilds the model from every confirmed transaction.
+func Retrain(ctx context.Context, d *sql.DB) (int, error) {
+	tx, err := d.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM model_examples`); err != nil {
+		return 0, err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM model_token_counts`); err != nil {
+		return 0, err
+	}
+	rows, err := tx.QueryContext(ctx, `SELECT id FROM transactions WHERE category_confirmed=1 AND COALESCE(category_norm,'') != ''`)
+	if err != nil {
+		return 0, err
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	for _, id := range ids {
+		if err := Learn(ctx, tx, id); err != nil {
+			return 0, err
+		}
+	}
+	return len(ids), tx.Commit()
+}
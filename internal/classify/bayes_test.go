@@ -0,0 +1,128 @@
+package classify
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+)
+
+// addTx inserts a transaction with a confirmed category.
+func addTx(t *testing.T, d *sql.DB, merchant, details string, cents int64, cat string) int64 {
+	t.Helper()
+	res, err := d.Exec(`INSERT INTO transactions (txn_date, amount_cents, account, details, merchant_norm, category_norm, category_confirmed, row_hash)
+		VALUES ('2026-03-01', ?, 'card', ?, ?, ?, 1, ?)`, cents, details, merchant, cat, fmt.Sprintf("%s-%s-%d", merchant, details, cents))
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, _ := res.LastInsertId()
+	return id
+}
+
+func TestLearnPredict(t *testing.T) {
+	ctx := context.Background()
+	d := openDB(t)
+	var ids []int64
+	for i := range 6 {
+		ids = append(ids, addTx(t, d, "WOOLWORTHS", fmt.Sprintf("WOOLWORTHS %d SYDNEY", i), -int64(6000+i*500), "Groceries"))
+		ids = append(ids, addTx(t, d, "UBER", fmt.Sprintf("UBER TRIP %d", i), -int64(1500+i*100), "Transport"))
+	}
+
+	// too little to go on: the model stays quiet
+	for _, id := range ids[:modelMinExamples-1] {
+		if err := Learn(ctx, d, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m, err := LoadModel(ctx, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cat, _ := m.Predict(Features(Input{Merchant: "WOOLWORTHS"})); cat != "" {
+		t.Errorf("predicted %q from %d examples, want nothing", cat, m.Examples())
+	}
+
+	// learning a transaction twice counts it once
+	for _, id := range ids {
+		if err := Learn(ctx, d, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if m, err = LoadModel(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+	if m.Examples() != len(ids) {
+		t.Errorf("%d examples, want %d", m.Examples(), len(ids))
+	}
+
+	tests := []struct {
+		in   Input
+		want string
+	}{
+		{Input{Merchant: "WOOLWORTHS", Details: "WOOLWORTHS 9 MELBOURNE", AmountCents: -7200, Account: "card"}, "Groceries"},
+		{Input{Merchant: "UBER", Details: "UBER TRIP", AmountCents: -1800, Account: "card"}, "Transport"},
+		{Input{Details: "UBER TRIP HELP.UBER.COM", AmountCents: -2200}, "Transport"},
+	}
+	for _, tt := range tests {
+		cat, p := m.Predict(Features(tt.in))
+		if cat != tt.want || p <= 0.5 || p > 1 {
+			t.Errorf("Predict(%+v) = %q %.2f, want %q with more than even odds", tt.in, cat, p, tt.want)
+		}
+	}
+
+	// recategorised and unconfirmed: its old example is unlearned
+	if _, err := d.Exec(`UPDATE transactions SET category_norm='Dining', category_confirmed=0 WHERE id=?`, ids[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := Learn(ctx, d, ids[0]); err != nil {
+		t.Fatal(err)
+	}
+	var groceries, tokens int
+	if err := d.QueryRow(`SELECT COUNT(*) FROM model_examples WHERE category_norm='Groceries'`).Scan(&groceries); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.QueryRow(`SELECT COALESCE(SUM(count),0) FROM model_token_counts WHERE category_norm='Groceries' AND token='d:sydney'`).Scan(&tokens); err != nil {
+		t.Fatal(err)
+	}
+	if groceries != 5 || tokens != 5 {
+		t.Errorf("after unlearning: %d Groceries examples with d:sydney %d times, want 5 and 5", groceries, tokens)
+	}
+
+	// Retrain starts again from the confirmed transactions
+	n, err := Retrain(ctx, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m, err = LoadModel(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+	if n != len(ids)-1 || m.Examples() != n {
+		t.Errorf("retrained on %d, model has %d, want %d", n, m.Examples(), len(ids)-1)
+	}
+}
+
+func TestLearnLiftsRejection(t *testing.T) {
+	ctx := context.Background()
+	d := openDB(t)
+	if _, err := d.Exec(`INSERT INTO model_rejections (merchant_norm, category_norm) VALUES ('NETFLIX','Groceries'), ('NETFLIX','Streaming')`); err != nil {
+		t.Fatal(err)
+	}
+	m, err := LoadModel(ctx, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.Rejected("NETFLIX", "Streaming") || m.Rejected("NETFLIX", "Dining") {
+		t.Error("rejections not loaded")
+	}
+
+	// confirming a category for the merchant lifts that rejection only
+	if err := Learn(ctx, d, addTx(t, d, "NETFLIX", "NETFLIX.COM", -1599, "Streaming")); err != nil {
+		t.Fatal(err)
+	}
+	if m, err = LoadModel(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+	if m.Rejected("NETFLIX", "Streaming") || !m.Rejected("NETFLIX", "Groceries") {
+		t.Errorf("after confirming Streaming: Streaming rejected %v, Groceries rejected %v", m.Rejected("NETFLIX", "Streaming"), m.Rejected("NETFLIX", "Groceries"))
+	}
+}
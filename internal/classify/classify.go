@@ -2,52 +2,188 @@ package classify
 
 import (
 	"context"
-	"database/sql"
+	"fmt"
 	"strings"
+
+	"github.com/anthurium-ai/personal-finance/internal/db"
+)
+
+// Category sources, stored in transactions.category_source.
+const (
+	SourceBank     = "bank"
+	SourceOverride = "override"
+	SourceRule     = "rule"
+	SourceModel    = "model"
+	SourceLLM      = "llm"
+	SourceManual   = "manual"
 )
 
+// Default confidences per source, 0..1.
+const (
+	ConfidenceOverride = 1.0
+	ConfidenceRule     = 0.9
+	ConfidenceBank     = 0.5
+	ConfidenceManual   = 1.0
+)
+
+// Input is what the classifier sees of a transaction.
+type Input struct {
+	Merchant    string // merchant_norm
+	Details     string
+	AmountCents int64
+	Account     string
+}
+
 type Suggestion struct {
-	Category string
-	Reason   string
-	Source   string // override|rule|llm
+	Category   string
+	Reason     string
+	Source     string  // override|rule|model|llm
+	RuleID     int64   // category_rules.id when Source is rule
+	OverrideID int64   // merchant_category_overrides.id when Source is override
+	Confidence float64 // 0..1
 }
 
-// SuggestCategory applies deterministic sources (override + rules).
-// LLM suggestions are handled elsewhere as an optional step.
-func SuggestCategory(ctx context.Context, db *sql.DB, merchantNorm string, details string) (*Suggestion, error) {
-	merchantNorm = strings.TrimSpace(merchantNorm)
-	details = strings.TrimSpace(details)
+// LLMConfidence maps the LLM's low|med|high to a 0..1 confidence.
+func LLMConfidence(level string) float64 {
+	switch strings.ToLower(strings.TrimSpace(level)) {
+	case "high":
+		return 0.9
+	case "med", "medium":
+		return 0.7
+	default:
+		return 0.4
+	}
+}
 
-	// 1) explicit merchant override
-	if merchantNorm != "" {
-		var cat string
-		err := db.QueryRowContext(ctx, `SELECT category_norm FROM merchant_category_overrides WHERE merchant_norm=?`, merchantNorm).Scan(&cat)
-		if err == nil && strings.TrimSpace(cat) != "" {
-			return &Suggestion{Category: cat, Reason: "merchant override", Source: "override"}, nil
-		}
-		if err != nil && err != sql.ErrNoRows {
+type override struct {
+	id       int64
+	category string
+}
+
+type rule struct {
+	id       int64
+	contains string
+	category string
+}
+
+// Classifier holds the deterministic sources (overrides + rules) in memory,
+// so many transactions can be classified without re-querying. The local
+// model is only consulted once attached with LoadModel.
+type Classifier struct {
+	overrides map[string]override
+	rules     []rule
+	model     *Model
+}
+
+// Load reads merchant overrides and enabled rules.
+func Load(ctx context.Context, q db.Querier) (*Classifier, error) {
+	c := &Classifier{overrides: map[string]override{}}
+
+	rows, err := q.QueryContext(ctx, `SELECT id, merchant_norm, category_norm FROM merchant_category_overrides`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var o override
+		var mer string
+		if err := rows.Scan(&o.id, &mer, &o.category); err != nil {
+			rows.Close()
 			return nil, err
 		}
+		mer = strings.TrimSpace(mer)
+		o.category = strings.TrimSpace(o.category)
+		if mer != "" && o.category != "" {
+			c.overrides[mer] = o
+		}
 	}
+	rows.Close()
 
-	// 2) contains rules (simple)
-	rows, err := db.QueryContext(ctx, `SELECT match_contains, category_norm FROM category_rules WHERE enabled=1 ORDER BY id ASC`)
+	rows, err = q.QueryContext(ctx, `SELECT id, match_contains, category_norm FROM category_rules WHERE enabled=1 ORDER BY id ASC`)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	text := strings.ToLower(merchantNorm + " " + details)
 	for rows.Next() {
-		var contains, cat string
-		_ = rows.Scan(&contains, &cat)
-		contains = strings.TrimSpace(contains)
-		if contains == "" {
+		var r rule
+		if err := rows.Scan(&r.id, &r.contains, &r.category); err != nil {
+			return nil, err
+		}
+		r.contains = strings.TrimSpace(r.contains)
+		r.category = strings.TrimSpace(r.category)
+		if r.contains == "" {
 			continue
 		}
-		if strings.Contains(text, strings.ToLower(contains)) {
-			return &Suggestion{Category: strings.TrimSpace(cat), Reason: "rule contains: " + contains, Source: "rule"}, nil
+		c.rules = append(c.rules, r)
+	}
+	return c, rows.Err()
+}
+
+// LoadModel attaches the local model as a source after rules.
+func (c *Classifier) LoadModel(ctx context.Context, q db.Querier) error {
+	m, err := LoadModel(ctx, q)
+	if err != nil {
+		return err
+	}
+	c.model = m
+	return nil
+}
+
+// Suggest applies the merchant override first, then the first matching rule,
+// then the local model if loaded. It returns nil when nothing matches.
+func (c *Classifier) Suggest(in Input) *Suggestion {
+	merchantNorm := strings.TrimSpace(in.Merchant)
+	details := strings.TrimSpace(in.Details)
+
+	// 1) explicit merchant override
+	if o, ok := c.overrides[merchantNorm]; ok && merchantNorm != "" {
+		return &Suggestion{Category: o.category, Reason: "merchant override", Source: SourceOverride, OverrideID: o.id, Confidence: ConfidenceOverride}
+	}
+
+	// 2) contains rules (simple)
+	text := strings.ToLower(merchantNorm + " " + details)
+	for _, r := range c.rules {
+		if strings.Contains(text, strings.ToLower(r.contains)) {
+			return &Suggestion{Category: r.category, Reason: "rule contains: " + r.contains, Source: SourceRule, RuleID: r.id, Confidence: ConfidenceRule}
+		}
+	}
+
+	// 3) local model
+	if c.model != nil {
+		if cat, p := c.model.Predict(Features(in)); cat != "" && !c.model.Rejected(merchantNorm, cat) {
+			return &Suggestion{Category: cat, Reason: fmt.Sprintf("local model (%d examples)", c.model.Examples()), Source: SourceModel, Confidence: p}
 		}
 	}
+	return nil
+}
+
+// SuggestCategory applies the local sources (override, rules, local model).
+// LLM suggestions are handled elsewhere as an optional step.
+func SuggestCategory(ctx context.Context, q db.Querier, in Input) (*Suggestion, error) {
+	c, err := Load(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.LoadModel(ctx, q); err != nil {
+		return nil, err
+	}
+	return c.Suggest(in), nil
+}
 
-	return nil, nil
+// Record writes a suggestion onto a transaction as its category, with provenance.
+// confirmed marks the category as accepted by a human.
+func Record(ctx context.Context, q db.Querier, txID int64, s *Suggestion, confirmed bool) error {
+	_, err := q.ExecContext(ctx, `UPDATE transactions
+		SET category_norm=?, category_source=?, category_rule_id=?, category_override_id=?, category_reason=?,
+		    category_confidence=?, category_set_at=strftime('%Y-%m-%dT%H:%M:%fZ','now'), category_confirmed=?
+		WHERE id=?`,
+		s.Category, s.Source, nullID(s.RuleID), nullID(s.OverrideID), s.Reason, s.Confidence, confirmed, txID)
+	return err
+}
+
+// nullID maps a zero id to NULL.
+func nullID(id int64) any {
+	if id == 0 {
+		return nil
+	}
+	return id
 }
@@ -0,0 +1,287 @@
+package classify
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"sort"
+)
+
+// EvalPipeline scores overrides, then rules, then the local model together:
+// what the edit page suggests.
+const EvalPipeline = "pipeline"
+
+type EvalOptions struct {
+	Split    string  // kfold|time
+	Folds    int     // kfold only
+	TestFrac float64 // time only: newest fraction held out
+	Seed     int64   // kfold shuffle
+
+	LLM      LLMProvider // also score the LLM when set (slow; calls out per example)
+	LLMLimit int         // max examples sent to the LLM, 0 = all
+}
+
+type example struct {
+	in       Input
+	merchRaw string
+	category string
+}
+
+// CategoryStats is one category's row in a source's report.
+type CategoryStats struct {
+	Category  string
+	Support   int // ground-truth examples
+	Predicted int // examples the source put in this category
+	Correct   int
+}
+
+func (c CategoryStats) Precision() float64 { return ratio(c.Correct, c.Predicted) }
+func (c CategoryStats) Recall() float64    { return ratio(c.Correct, c.Support) }
+
+// SourceReport scores one classification source against the holdout.
+type SourceReport struct {
+	Source     string
+	Total      int // examples scored
+	Covered    int // examples the source made a prediction for
+	Correct    int
+	Categories []CategoryStats
+	// Confusion[actual][predicted]; predicted "" means no prediction
+	Confusion map[string]map[string]int
+}
+
+func (s *SourceReport) Coverage() float64 { return ratio(s.Covered, s.Total) }
+
+// Accuracy is over covered examples only.
+func (s *SourceReport) Accuracy() float64 { return ratio(s.Correct, s.Covered) }
+
+type EvalReport struct {
+	Options    EvalOptions
+	Examples   int
+	Categories []string
+	Sources    []*SourceReport
+}
+
+// Evaluate holds out user-confirmed transactions and scores each source on
+// them. Overrides and the local model are rebuilt from the training part of
+// each split, so nothing is scored on what it learned from.
+func Evaluate(ctx context.Context, d *sql.DB, opts EvalOptions) (*EvalReport, error) {
+	if opts.Folds < 2 {
+		opts.Folds = 5
+	}
+	if opts.TestFrac <= 0 || opts.TestFrac >= 1 {
+		opts.TestFrac = 0.2
+	}
+	if opts.Split == "" {
+		opts.Split = "kfold"
+	}
+
+	exs, err := loadExamples(ctx, d)
+	if err != nil {
+		return nil, err
+	}
+	base, err := Load(ctx, d)
+	if err != nil {
+		return nil, err
+	}
+	rulesOnly := &Classifier{overrides: map[string]override{}, rules: base.rules}
+
+	rep := &EvalReport{Options: opts, Examples: len(exs)}
+	names := []string{SourceOverride, SourceRule, SourceModel, EvalPipeline}
+	if opts.LLM != nil {
+		names = append(names, SourceLLM)
+	}
+	scores := map[string]*scorer{}
+	for _, n := range names {
+		scores[n] = newScorer()
+	}
+
+	// the LLM is asked directly: eval answers depend on each fold's
+	// categories, so they are neither read from nor stored in the cache
+	var red *Redactor
+	if opts.LLM != nil {
+		if red, err = LoadRedactor(ctx, d); err != nil {
+			return nil, err
+		}
+	}
+	llmSent := 0
+	for _, fold := range splitExamples(exs, opts) {
+		overrides := map[string]string{}
+		model := newModel()
+		cats := map[string]bool{}
+		for _, e := range fold.train {
+			if e.in.Merchant != "" {
+				overrides[e.in.Merchant] = e.category // train is date-ordered: latest wins
+			}
+			model.add(e.category, Features(e.in))
+			cats[e.category] = true
+		}
+		var catList []string
+		for c := range cats {
+			catList = append(catList, c)
+		}
+		sort.Strings(catList)
+
+		for _, e := range fold.test {
+			ov := overrides[e.in.Merchant]
+			var rule string
+			if s := rulesOnly.Suggest(e.in); s != nil {
+				rule = s.Category
+			}
+			mod, _ := model.Predict(Features(e.in))
+			pipe := firstNonEmpty(ov, rule, mod)
+
+			scores[SourceOverride].add(e.category, ov)
+			scores[SourceRule].add(e.category, rule)
+			scores[SourceModel].add(e.category, mod)
+			scores[EvalPipeline].add(e.category, pipe)
+
+			if opts.LLM != nil && (opts.LLMLimit == 0 || llmSent < opts.LLMLimit) {
+				llmSent++
+				var got string
+				in := e.in
+				if in.Merchant == "" {
+					in.Merchant = e.merchRaw
+				}
+				if s, err := askLLM(ctx, opts.LLM, red, in, catList); err == nil {
+					got = s.Category
+				}
+				scores[SourceLLM].add(e.category, got)
+			}
+		}
+	}
+
+	// columns include categories only ever predicted, e.g. by a stale rule
+	all := map[string]bool{}
+	for _, sc := range scores {
+		for a, row := range sc.confusion {
+			all[a] = true
+			for p := range row {
+				if p != "" {
+					all[p] = true
+				}
+			}
+		}
+	}
+	for c := range all {
+		rep.Categories = append(rep.Categories, c)
+	}
+	sort.Strings(rep.Categories)
+	for _, n := range names {
+		rep.Sources = append(rep.Sources, scores[n].report(n, rep.Categories))
+	}
+	return rep, nil
+}
+
+func loadExamples(ctx context.Context, d *sql.DB) ([]example, error) {
+	rows, err := d.QueryContext(ctx, `
+		SELECT COALESCE(merchant_norm,''), COALESCE(merchant_raw,''), COALESCE(details,''),
+		       amount_cents, COALESCE(account,''), category_norm
+		FROM transactions
+		WHERE category_confirmed=1 AND COALESCE(category_norm,'') != ''
+		ORDER BY txn_date ASC, id ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []example
+	for rows.Next() {
+		var e example
+		if err := rows.Scan(&e.in.Merchant, &e.merchRaw, &e.in.Details, &e.in.AmountCents, &e.in.Account, &e.category); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+type fold struct {
+	train []example
+	test  []example
+}
+
+// splitExamples keeps each side in date order.
+func splitExamples(exs []example, opts EvalOptions) []fold {
+	if opts.Split == "time" {
+		cut := len(exs) - int(float64(len(exs))*opts.TestFrac)
+		return []fold{{train: exs[:cut], test: exs[cut:]}}
+	}
+	assign := rand.New(rand.NewSource(opts.Seed)).Perm(len(exs))
+	folds := make([]fold, opts.Folds)
+	for i, e := range exs {
+		k := assign[i] % opts.Folds
+		for j := range folds {
+			if j == k {
+				folds[j].test = append(folds[j].test, e)
+			} else {
+				folds[j].train = append(folds[j].train, e)
+			}
+		}
+	}
+	return folds
+}
+
+type scorer struct {
+	total, covered, correct int
+	confusion               map[string]map[string]int
+}
+
+func newScorer() *scorer {
+	return &scorer{confusion: map[string]map[string]int{}}
+}
+
+func (s *scorer) add(actual, predicted string) {
+	s.total++
+	if predicted != "" {
+		s.covered++
+		if predicted == actual {
+			s.correct++
+		}
+	}
+	if s.confusion[actual] == nil {
+		s.confusion[actual] = map[string]int{}
+	}
+	s.confusion[actual][predicted]++
+}
+
+func (s *scorer) report(name string, cats []string) *SourceReport {
+	r := &SourceReport{Source: name, Total: s.total, Covered: s.covered, Correct: s.correct, Confusion: s.confusion}
+	predicted := map[string]int{}
+	for _, row := range s.confusion {
+		for p, n := range row {
+			predicted[p] += n
+		}
+	}
+	for _, c := range cats {
+		st := CategoryStats{Category: c, Predicted: predicted[c], Correct: s.confusion[c][c]}
+		for _, n := range s.confusion[c] {
+			st.Support += n
+		}
+		r.Categories = append(r.Categories, st)
+	}
+	return r
+}
+
+func firstNonEmpty(vs ...string) string {
+	for _, v := range vs {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func ratio(a, b int) float64 {
+	if b == 0 {
+		return 0
+	}
+	return float64(a) / float64(b)
+}
+
+// Describe summarises the split for report headers.
+func (o EvalOptions) Describe() string {
+	if o.Split == "time" {
+		return fmt.Sprintf("time split, newest %.0f%% held out", o.TestFrac*100)
+	}
+	return fmt.Sprintf("%d-fold cross-validation (seed %d)", o.Folds, o.Seed)
+}
@@ -0,0 +1,91 @@
+package classify
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestEvaluate(t *testing.T) {
+	ctx := context.Background()
+	d := openDB(t)
+	// in date order; a time split holds out the last two
+	for i, e := range []struct{ merchant, cat string }{
+		{"NETFLIX", "Streaming"}, {"WOOLWORTHS", "Groceries"}, {"KMART", "Household"},
+		{"NETFLIX", "Streaming"}, {"WOOLWORTHS", "Groceries"}, {"KMART", "Household"},
+		{"NETFLIX", "Streaming"}, {"WOOLWORTHS", "Groceries"},
+		{"NETFLIX", "Streaming"}, // held out: the override knows it
+		{"ALDI", "Groceries"},    // held out: only a wrong rule matches
+	} {
+		addTx(t, d, e.merchant, fmt.Sprintf("%s %d", e.merchant, i), -1000, e.cat)
+	}
+	if _, err := d.Exec(`INSERT INTO transactions (txn_date, amount_cents, merchant_norm, category_norm, row_hash)
+		VALUES ('2026-03-02', -500, 'ALDI', 'Dining', 'unconfirmed')`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Exec(`INSERT INTO category_rules (match_contains, category_norm) VALUES ('ALDI','Dining')`); err != nil {
+		t.Fatal(err)
+	}
+
+	p := &FakeProvider{Replies: []string{`{"category":"Groceries","reason":"shop","confidence":"med"}`}}
+	rep, err := Evaluate(ctx, d, EvalOptions{Split: "time", TestFrac: 0.2, LLM: p, LLMLimit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Examples != 10 {
+		t.Errorf("%d examples, want 10 confirmed", rep.Examples)
+	}
+	want := map[string][3]int{ // total, covered, correct
+		SourceOverride: {2, 1, 1},
+		SourceRule:     {2, 1, 0},
+		EvalPipeline:   {2, 2, 1},
+		SourceLLM:      {1, 1, 0},
+	}
+	got := map[string]*SourceReport{}
+	for _, s := range rep.Sources {
+		got[s.Source] = s
+	}
+	for src, w := range want {
+		s := got[src]
+		if s == nil {
+			t.Errorf("%s: no report", src)
+			continue
+		}
+		if [3]int{s.Total, s.Covered, s.Correct} != w {
+			t.Errorf("%s: total/covered/correct %d/%d/%d, want %v", src, s.Total, s.Covered, s.Correct, w)
+		}
+	}
+	if s := got[SourceModel]; s == nil || s.Total != 2 {
+		t.Errorf("model report = %+v, want 2 scored", s)
+	}
+	if len(p.Prompts) != 1 {
+		t.Errorf("%d LLM prompts, want 1", len(p.Prompts))
+	}
+
+	// a category only ever predicted still gets a column
+	pipe := got[EvalPipeline]
+	stats := map[string]CategoryStats{}
+	for _, c := range pipe.Categories {
+		stats[c.Category] = c
+	}
+	if c := stats["Dining"]; c.Support != 0 || c.Predicted != 1 || c.Precision() != 0 {
+		t.Errorf("pipeline Dining = %+v", c)
+	}
+	if c := stats["Streaming"]; c.Precision() != 1 || c.Recall() != 1 {
+		t.Errorf("pipeline Streaming = %+v", c)
+	}
+	if n := pipe.Confusion["Groceries"]["Dining"]; n != 1 {
+		t.Errorf("pipeline confused Groceries for Dining %d times, want 1", n)
+	}
+
+	// k-fold scores every example once
+	rep, err = Evaluate(ctx, d, EvalOptions{Split: "kfold", Folds: 3, Seed: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range rep.Sources {
+		if s.Total != 10 {
+			t.Errorf("kfold %s scored %d, want 10", s.Source, s.Total)
+		}
+	}
+}
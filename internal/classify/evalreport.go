@@ -0,0 +1,136 @@
+package classify
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"text/tabwriter"
+)
+
+type confusion struct {
+	Actual    string
+	Predicted string
+	Count     int
+}
+
+// topConfusions lists off-diagonal cells, largest first. Abstentions are not confusions.
+func (s *SourceReport) topConfusions(n int) []confusion {
+	var out []confusion
+	for a, row := range s.Confusion {
+		for p, c := range row {
+			if p != "" && p != a {
+				out = append(out, confusion{Actual: a, Predicted: p, Count: c})
+			}
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Actual+out[i].Predicted < out[j].Actual+out[j].Predicted
+	})
+	if len(out) > n {
+		out = out[:n]
+	}
+	return out
+}
+
+// WriteText writes the summary, per-category stats and top confusions.
+func (r *EvalReport) WriteText(w io.Writer) error {
+	fmt.Fprintf(w, "Evaluation: %d confirmed transactions, %s\n\n", r.Examples, r.Options.Describe())
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SOURCE\tCOVERAGE\tACCURACY\tCORRECT\tCOVERED\tSCORED")
+	for _, s := range r.Sources {
+		fmt.Fprintf(tw, "%s\t%.1f%%\t%.1f%%\t%d\t%d\t%d\n", s.Source, s.Coverage()*100, s.Accuracy()*100, s.Correct, s.Covered, s.Total)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	for _, s := range r.Sources {
+		fmt.Fprintf(w, "\n== %s ==\n", s.Source)
+		tw = tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "CATEGORY\tSUPPORT\tPREDICTED\tPRECISION\tRECALL")
+		for _, c := range s.Categories {
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%.2f\t%.2f\n", c.Category, c.Support, c.Predicted, c.Precision(), c.Recall())
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		if conf := s.topConfusions(10); len(conf) > 0 {
+			fmt.Fprintln(w, "top confusions (actual -> predicted):")
+			for _, c := range conf {
+				fmt.Fprintf(w, "  %s -> %s: %d\n", c.Actual, c.Predicted, c.Count)
+			}
+		}
+	}
+	return nil
+}
+
+// WriteHTML writes a standalone page including full confusion matrices.
+func (r *EvalReport) WriteHTML(w io.Writer) error {
+	return evalHTML.Execute(w, r)
+}
+
+var evalHTML = template.Must(template.New("eval").Funcs(template.FuncMap{
+	"pct": func(f float64) string { return fmt.Sprintf("%.1f%%", f*100) },
+	"f2":  func(f float64) string { return fmt.Sprintf("%.2f", f) },
+	"cell": func(s *SourceReport, actual, predicted string) int {
+		return s.Confusion[actual][predicted]
+	},
+}).Parse(`<!doctype html>
+<html>
+<head>
+  <meta charset="utf-8" />
+  <title>Classifier evaluation · pfportal</title>
+  <style>
+    body { font-family: ui-sans-serif, system-ui, -apple-system; margin: 24px; }
+    table { border-collapse: collapse; margin-bottom: 16px; }
+    th, td { padding: 6px 8px; border-bottom: 1px solid #eee; font-size: 13px; text-align: right; }
+    th:first-child, td:first-child { text-align: left; }
+    .muted { color: #666; }
+    .hit { background: #e7f6ec; }
+    .miss { background: #fdecea; }
+  </style>
+</head>
+<body>
+<h2>Classifier evaluation</h2>
+<p class="muted">{{.Examples}} confirmed transactions · {{.Options.Describe}}</p>
+
+<table>
+  <thead><tr><th>Source</th><th>Coverage</th><th>Accuracy</th><th>Correct</th><th>Covered</th><th>Scored</th></tr></thead>
+  <tbody>
+  {{range .Sources}}
+    <tr><td>{{.Source}}</td><td>{{pct .Coverage}}</td><td>{{pct .Accuracy}}</td><td>{{.Correct}}</td><td>{{.Covered}}</td><td>{{.Total}}</td></tr>
+  {{end}}
+  </tbody>
+</table>
+
+{{$cats := .Categories}}
+{{range $s := .Sources}}
+<h3>{{$s.Source}}</h3>
+<table>
+  <thead><tr><th>Category</th><th>Support</th><th>Predicted</th><th>Precision</th><th>Recall</th></tr></thead>
+  <tbody>
+  {{range $s.Categories}}
+    <tr><td>{{.Category}}</td><td>{{.Support}}</td><td>{{.Predicted}}</td><td>{{f2 .Precision}}</td><td>{{f2 .Recall}}</td></tr>
+  {{end}}
+  </tbody>
+</table>
+<p class="muted">Confusion matrix: rows are actual, columns predicted.</p>
+<table>
+  <thead><tr><th></th>{{range $cats}}<th>{{.}}</th>{{end}}<th class="muted">none</th></tr></thead>
+  <tbody>
+  {{range $a := $cats}}
+    <tr><td>{{$a}}</td>
+    {{range $p := $cats}}{{$n := cell $s $a $p}}<td class="{{if $n}}{{if eq $a $p}}hit{{else}}miss{{end}}{{end}}">{{if $n}}{{$n}}{{end}}</td>{{end}}
+    <td class="muted">{{with cell $s $a ""}}{{.}}{{end}}</td></tr>
+  {{end}}
+  </tbody>
+</table>
+{{end}}
+</body>
+</html>
+`))
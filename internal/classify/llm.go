@@ -1,24 +1,139 @@
 package classify
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
-	"os/exec"
+	"os"
 	"strings"
 	"time"
+
+	"github.com/anthurium-ai/personal-finance/internal/db"
 )
 
 type LLMSuggestion struct {
-	Category string `json:"category"`
-	Reason   string `json:"reason"`
+	Category   string `json:"category"`
+	Reason     string `json:"reason"`
 	Confidence string `json:"confidence"` // low|med|high
+
+	Cached bool `json:"-"` // answered from llm_cache, nothing was sent
+}
+
+// LLMProvider sends a prompt to a language model and returns its raw reply.
+// schema is the JSON schema the reply must satisfy; backends that support
+// structured output pass it on, the caller validates the reply either way.
+type LLMProvider interface {
+	Name() string
+	Complete(ctx context.Context, prompt string, schema json.RawMessage) (string, error)
+}
+
+// suggestionSchema is the response contract for a single suggestion.
+var suggestionSchema = json.RawMessage(`{
+  "type": "object",
+  "properties": {
+    "category": {"type": "string", "minLength": 1},
+    "reason": {"type": "string"},
+    "confidence": {"type": "string", "enum": ["low", "med", "high"]}
+  },
+  "required": ["category", "reason", "confidence"],
+  "additionalProperties": false
+}`)
+
+// LLMConfig selects and configures a provider.
+type LLMConfig struct {
+	Provider string // codex|openai|ollama|fake|none
+	BaseURL  string
+	Model    string
+	APIKey   string
+	Timeout  time.Duration
+}
+
+// RegisterFlags binds the config to flags, defaulting from PF_LLM_* env vars.
+// The API key is read from the environment only.
+func (c *LLMConfig) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.Provider, "llm-provider", envOr("PF_LLM_PROVIDER", "codex"), "LLM provider: codex|openai|ollama|fake|none")
+	fs.StringVar(&c.BaseURL, "llm-base-url", os.Getenv("PF_LLM_BASE_URL"), "LLM endpoint base URL (openai/ollama)")
+	fs.StringVar(&c.Model, "llm-model", os.Getenv("PF_LLM_MODEL"), "LLM model name (openai/ollama)")
+	timeout, err := time.ParseDuration(envOr("PF_LLM_TIMEOUT", "20s"))
+	if err != nil {
+		timeout = 20 * time.Second
+	}
+	fs.DurationVar(&c.Timeout, "llm-timeout", timeout, "per-request LLM timeout")
+	c.APIKey = envOr("PF_LLM_API_KEY", os.Getenv("OPENAI_API_KEY"))
 }
 
-// SuggestCategoryLLM calls `codex exec` to propose a category.
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// NewLLMProvider builds the configured provider. "none" returns nil, nil.
+func NewLLMProvider(cfg LLMConfig) (LLMProvider, error) {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 20 * time.Second
+	}
+	switch cfg.Provider {
+	case "", "codex":
+		return &CodexProvider{Timeout: cfg.Timeout}, nil
+	case "openai":
+		if cfg.BaseURL == "" {
+			cfg.BaseURL = "https://api.openai.com/v1"
+		}
+		if cfg.Model == "" {
+			return nil, fmt.Errorf("openai provider needs a model")
+		}
+		return &OpenAIProvider{BaseURL: cfg.BaseURL, Model: cfg.Model, APIKey: cfg.APIKey, Timeout: cfg.Timeout}, nil
+	case "ollama":
+		if cfg.BaseURL == "" {
+			cfg.BaseURL = "http://127.0.0.1:11434"
+		}
+		if cfg.Model == "" {
+			return nil, fmt.Errorf("ollama provider needs a model")
+		}
+		return &OllamaProvider{BaseURL: cfg.BaseURL, Model: cfg.Model, Timeout: cfg.Timeout}, nil
+	case "fake":
+		return &FakeProvider{Replies: []string{`{"category":"Uncategorised","reason":"fake provider","confidence":"low"}`}}, nil
+	case "none":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown LLM provider %q", cfg.Provider)
+	}
+}
+
+// SuggestCategoryLLM asks the provider to propose a category.
 // This is optional and should be used as assist-only.
-func SuggestCategoryLLM(ctx context.Context, merchantRaw, details string, amountCents int64, existingCategories []string) (*LLMSuggestion, error) {
+// Answers are cached per merchant (in.Merchant, normalised) and prompt
+// version, and the merchant and details are redacted before they are sent.
+func SuggestCategoryLLM(ctx context.Context, q db.Querier, p LLMProvider, in Input, existingCategories []string) (*LLMSuggestion, error) {
+	if p == nil {
+		return nil, fmt.Errorf("no LLM provider configured")
+	}
+	if strings.TrimSpace(in.Merchant) != "" {
+		if sug, err := cachedSuggestion(ctx, q, in.Merchant); err != nil || sug != nil {
+			return sug, err
+		}
+	}
+	red, err := LoadRedactor(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	sug, err := askLLM(ctx, p, red, in, existingCategories)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(in.Merchant) != "" {
+		if err := storeSuggestion(ctx, q, p.Name(), in.Merchant, sug); err != nil {
+			return nil, err
+		}
+	}
+	return sug, nil
+}
+
+// askLLM sends one redacted prompt to the provider, bypassing the cache.
+func askLLM(ctx context.Context, p LLMProvider, red *Redactor, in Input, existingCategories []string) (*LLMSuggestion, error) {
 	prompt := fmt.Sprintf(`You are helping classify personal finance transactions.
 
 Merchant: %s
@@ -30,32 +145,68 @@ Known categories (pick one if appropriate):
 
 Return JSON only:
 {"category":"...","reason":"...","confidence":"low|med|high"}
-`, strings.TrimSpace(merchantRaw), strings.TrimSpace(details), amountCents, strings.Join(existingCategories, ", "))
+`, red.Redact(strings.TrimSpace(in.Merchant)), red.Redact(strings.TrimSpace(in.Details)), in.AmountCents, strings.Join(existingCategories, ", "))
 
-	cctx, cancel := context.WithTimeout(ctx, 20*time.Second)
-	defer cancel()
+	reply, err := p.Complete(ctx, prompt, suggestionSchema)
+	if err != nil {
+		return nil, err
+	}
+	var sug LLMSuggestion
+	if err := decodeStrict(reply, &sug); err != nil {
+		return nil, fmt.Errorf("%s: %w", p.Name(), err)
+	}
+	if err := sug.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", p.Name(), err)
+	}
+	return &sug, nil
+}
 
-	cmd := exec.CommandContext(cctx, "codex", "exec", prompt)
-	var out bytes.Buffer
-	cmd.Stdout = &out
-	cmd.Stderr = &out
-	if err := cmd.Run(); err != nil {
-		return nil, fmt.Errorf("codex exec failed: %w: %s", err, strings.TrimSpace(out.String()))
+func (s *LLMSuggestion) validate() error {
+	s.Category = strings.TrimSpace(s.Category)
+	if s.Category == "" {
+		return fmt.Errorf("reply has empty category")
+	}
+	switch s.Confidence {
+	case "low", "med", "high":
+	default:
+		return fmt.Errorf("reply has invalid confidence %q", s.Confidence)
 	}
+	return nil
+}
 
-	b := bytes.TrimSpace(out.Bytes())
-	var sug LLMSuggestion
-	if err := json.Unmarshal(b, &sug); err != nil {
-		// try to salvage by extracting last {...}
-		s := out.String()
+// decodeStrict decodes the JSON object in reply into v, rejecting unknown
+// fields and trailing data. Code fences and chatter around a single object
+// are tolerated since not every backend enforces the schema.
+func decodeStrict(reply string, v any) error {
+	s := strings.TrimSpace(reply)
+	s = strings.TrimPrefix(s, "```json")
+	s = strings.TrimPrefix(s, "```")
+	s = strings.TrimSuffix(s, "```")
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "{") && !strings.HasPrefix(s, "[") {
+		// salvage the last {...}
 		i := strings.LastIndex(s, "{")
 		j := strings.LastIndex(s, "}")
-		if i >= 0 && j > i {
-			if err2 := json.Unmarshal([]byte(s[i:j+1]), &sug); err2 == nil {
-				return &sug, nil
-			}
+		if i < 0 || j < i {
+			return fmt.Errorf("reply was not JSON: %s", truncate(reply, 200))
 		}
-		return nil, fmt.Errorf("codex output was not JSON: %s", strings.TrimSpace(out.String()))
+		s = s[i : j+1]
 	}
-	return &sug, nil
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("reply did not match schema: %w: %s", err, truncate(reply, 200))
+	}
+	if dec.More() {
+		return fmt.Errorf("reply has trailing data: %s", truncate(reply, 200))
+	}
+	return nil
+}
+
+func truncate(s string, n int) string {
+	s = strings.TrimSpace(s)
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
 }
@@ -0,0 +1,188 @@
+package classify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CodexProvider shells out to `codex exec`.
+type CodexProvider struct {
+	Bin     string // default "codex"
+	Timeout time.Duration
+}
+
+func (p *CodexProvider) Name() string { return "codex" }
+
+func (p *CodexProvider) Complete(ctx context.Context, prompt string, _ json.RawMessage) (string, error) {
+	bin := p.Bin
+	if bin == "" {
+		bin = "codex"
+	}
+	cctx, cancel := context.WithTimeout(ctx, p.Timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(cctx, bin, "exec", prompt)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("codex exec failed: %w: %s", err, strings.TrimSpace(out.String()))
+	}
+	return out.String(), nil
+}
+
+// OpenAIProvider talks to an OpenAI-compatible /chat/completions endpoint,
+// asking for a strict json_schema response.
+type OpenAIProvider struct {
+	BaseURL string // e.g. https://api.openai.com/v1
+	Model   string
+	APIKey  string
+	Timeout time.Duration
+	Client  *http.Client // default http.DefaultClient
+}
+
+func (p *OpenAIProvider) Name() string { return "openai:" + p.Model }
+
+func (p *OpenAIProvider) Complete(ctx context.Context, prompt string, schema json.RawMessage) (string, error) {
+	body := map[string]any{
+		"model": p.Model,
+		"messages": []map[string]string{
+			{"role": "user", "content": prompt},
+		},
+		"temperature": 0,
+	}
+	if len(schema) > 0 {
+		body["response_format"] = map[string]any{
+			"type":        "json_schema",
+			"json_schema": map[string]any{"name": "response", "schema": schema, "strict": true},
+		}
+	}
+	var resp struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	headers := map[string]string{}
+	if p.APIKey != "" {
+		headers["Authorization"] = "Bearer " + p.APIKey
+	}
+	if err := postJSON(ctx, p.Client, p.Timeout, strings.TrimRight(p.BaseURL, "/")+"/chat/completions", headers, body, &resp); err != nil {
+		return "", err
+	}
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("openai: no choices in response")
+	}
+	return resp.Choices[0].Message.Content, nil
+}
+
+// OllamaProvider talks to an Ollama-style /api/chat endpoint, passing the
+// schema as the structured output format.
+type OllamaProvider struct {
+	BaseURL string // e.g. http://127.0.0.1:11434
+	Model   string
+	Timeout time.Duration
+	Client  *http.Client // default http.DefaultClient
+}
+
+func (p *OllamaProvider) Name() string { return "ollama:" + p.Model }
+
+func (p *OllamaProvider) Complete(ctx context.Context, prompt string, schema json.RawMessage) (string, error) {
+	body := map[string]any{
+		"model": p.Model,
+		"messages": []map[string]string{
+			{"role": "user", "content": prompt},
+		},
+		"stream":  false,
+		"options": map[string]any{"temperature": 0},
+	}
+	if len(schema) > 0 {
+		body["format"] = schema
+	}
+	var resp struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+	}
+	if err := postJSON(ctx, p.Client, p.Timeout, strings.TrimRight(p.BaseURL, "/")+"/api/chat", nil, body, &resp); err != nil {
+		return "", err
+	}
+	return resp.Message.Content, nil
+}
+
+func postJSON(ctx context.Context, client *http.Client, timeout time.Duration, url string, headers map[string]string, body, out any) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	rb, err := io.ReadAll(io.LimitReader(res.Body, 4<<20))
+	if err != nil {
+		return err
+	}
+	if res.StatusCode/100 != 2 {
+		return fmt.Errorf("POST %s: %s: %s", url, res.Status, truncate(string(rb), 300))
+	}
+	if err := json.Unmarshal(rb, out); err != nil {
+		return fmt.Errorf("POST %s: decode response: %w", url, err)
+	}
+	return nil
+}
+
+// FakeProvider returns canned replies, for tests and offline development.
+// Replies are used in order; the last one repeats.
+type FakeProvider struct {
+	Replies []string
+	Err     error
+
+	mu      sync.Mutex
+	Prompts []string // every prompt received
+}
+
+func (p *FakeProvider) Name() string { return "fake" }
+
+func (p *FakeProvider) Complete(_ context.Context, prompt string, _ json.RawMessage) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n := len(p.Prompts)
+	p.Prompts = append(p.Prompts, prompt)
+	if p.Err != nil {
+		return "", p.Err
+	}
+	if len(p.Replies) == 0 {
+		return "", fmt.Errorf("fake provider has no replies")
+	}
+	if n >= len(p.Replies) {
+		n = len(p.Replies) - 1
+	}
+	return p.Replies[n], nil
+}
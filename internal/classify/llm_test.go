@@ -0,0 +1,92 @@
+package classify
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/anthurium-ai/personal-finance/internal/db"
+)
+
+// openDB is a migrated database of the test's own.
+func openDB(t *testing.T) *sql.DB {
+	t.Helper()
+	d, err := db.Open(filepath.Join(t.TempDir(), "pf.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Close() })
+	if err := db.Migrate(context.Background(), d); err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestDecodeStrict(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply string
+		want  string // category, or the error
+	}{
+		{"plain", `{"category":"Groceries","reason":"supermarket","confidence":"high"}`, "Groceries"},
+		{"fenced", "```json\n{\"category\":\"Groceries\",\"reason\":\"\",\"confidence\":\"low\"}\n```", "Groceries"},
+		{"bare fence", "```\n{\"category\":\"Transport\",\"reason\":\"\",\"confidence\":\"med\"}\n```", "Transport"},
+		{"chatter around", `Sure! Here you go: {"category":"Dining","reason":"cafe","confidence":"med"} Hope that helps.`, "Dining"},
+		{"unknown field", `{"category":"Groceries","reason":"","confidence":"high","extra":1}`, "did not match schema"},
+		{"wrong type", `{"category":["Groceries"],"reason":"","confidence":"high"}`, "did not match schema"},
+		{"trailing object", `{"category":"A","reason":"","confidence":"high"} {"category":"B"}`, "trailing data"},
+		{"not json", `I think it's groceries`, "was not JSON"},
+		{"empty", ``, "was not JSON"},
+	}
+	for _, tt := range tests {
+		var s LLMSuggestion
+		err := decodeStrict(tt.reply, &s)
+		got := s.Category
+		if err != nil {
+			got = err.Error()
+		}
+		if !strings.Contains(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAskLLM(t *testing.T) {
+	in := Input{Merchant: "WOOLWORTHS", Details: "WOOLWORTHS 1234 SYDNEY card 4321 5678 9012 3456", AmountCents: -8250}
+	tests := []struct {
+		name  string
+		reply string
+		err   error
+		want  string // category, or the error
+	}{
+		{"good", `{"category":" Groceries ","reason":"supermarket","confidence":"high"}`, nil, "Groceries"},
+		{"empty category", `{"category":"  ","reason":"","confidence":"high"}`, nil, "empty category"},
+		{"bad confidence", `{"category":"Groceries","reason":"","confidence":"very"}`, nil, `invalid confidence "very"`},
+		{"missing confidence", `{"category":"Groceries","reason":""}`, nil, `invalid confidence ""`},
+		{"extra field", `{"category":"Groceries","reason":"","confidence":"high","tags":[]}`, nil, "did not match schema"},
+		{"provider error", "", errors.New("connection refused"), "connection refused"},
+	}
+	for _, tt := range tests {
+		p := &FakeProvider{Replies: []string{tt.reply}, Err: tt.err}
+		sug, err := askLLM(context.Background(), p, &Redactor{}, in, []string{"Groceries", "Transport"})
+		got := ""
+		if err != nil {
+			got = err.Error()
+		} else {
+			got = sug.Category
+		}
+		if !strings.Contains(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+		if len(p.Prompts) != 1 {
+			t.Errorf("%s: %d prompts sent, want 1", tt.name, len(p.Prompts))
+			continue
+		}
+		if strings.Contains(p.Prompts[0], "4321") || !strings.Contains(p.Prompts[0], "Groceries, Transport") {
+			t.Errorf("%s: prompt not redacted or missing categories:\n%s", tt.name, p.Prompts[0])
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package classify
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/anthurium-ai/personal-finance/internal/db"
+)
+
+// PromptVersion keys the suggestion cache. Bump it when the prompts or the
+// redaction change enough that cached answers should no longer be trusted.
+const PromptVersion = "v1"
+
+// cacheKey is how a merchant is looked up in llm_cache.
+func cacheKey(merchant string) string {
+	return strings.ToUpper(strings.TrimSpace(merchant))
+}
+
+// cachedSuggestion returns the stored answer for merchant, or nil.
+func cachedSuggestion(ctx context.Context, q db.Querier, merchant string) (*LLMSuggestion, error) {
+	var s LLMSuggestion
+	err := q.QueryRowContext(ctx, `SELECT category_norm, COALESCE(reason,''), confidence FROM llm_cache WHERE merchant_norm=? AND prompt_version=?`,
+		cacheKey(merchant), PromptVersion).Scan(&s.Category, &s.Reason, &s.Confidence)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.Cached = true
+	return &s, nil
+}
+
+func storeSuggestion(ctx context.Context, q db.Querier, provider, merchant string, s *LLMSuggestion) error {
+	_, err := q.ExecContext(ctx, `INSERT INTO llm_cache (merchant_norm, prompt_version, category_norm, reason, confidence, provider) VALUES (?,?,?,?,?,?)
+		ON CONFLICT(merchant_norm, prompt_version) DO UPDATE SET category_norm=excluded.category_norm, reason=excluded.reason,
+			confidence=excluded.confidence, provider=excluded.provider, created_at=excluded.created_at`,
+		cacheKey(merchant), PromptVersion, s.Category, s.Reason, s.Confidence, provider)
+	return err
+}
+
+// ClearLLMCache drops every cached suggestion and returns how many there were.
+func ClearLLMCache(ctx context.Context, q db.Querier) (int64, error) {
+	res, err := q.ExecContext(ctx, `DELETE FROM llm_cache`)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// auditProvider records every prompt sent, with the reply or error, in
+// llm_audit.
+type auditProvider struct {
+	LLMProvider
+	db *sql.DB
+}
+
+// WithAudit wraps p so that everything sent to it is logged. A nil p stays nil.
+func WithAudit(p LLMProvider, d *sql.DB) LLMProvider {
+	if p == nil {
+		return nil
+	}
+	return &auditProvider{LLMProvider: p, db: d}
+}
+
+func (p *auditProvider) Complete(ctx context.Context, prompt string, schema json.RawMessage) (string, error) {
+	reply, err := p.LLMProvider.Complete(ctx, prompt, schema)
+	var errMsg sql.NullString
+	if err != nil {
+		errMsg = sql.NullString{String: err.Error(), Valid: true}
+	}
+	// logged even if the caller has gone away, since the prompt was sent
+	if _, aerr := p.db.ExecContext(context.WithoutCancel(ctx), `INSERT INTO llm_audit (provider, prompt, reply, error) VALUES (?,?,?,?)`,
+		p.Name(), prompt, reply, errMsg); aerr != nil && err == nil {
+		// refuse to use a reply we could not account for
+		return "", aerr
+	}
+	return reply, err
+}
+
+type AuditEntry struct {
+	ID        int64
+	CreatedAt string
+	Provider  string
+	Prompt    string
+	Reply     string
+	Error     string
+}
+
+// RecentAudit lists the latest audit entries, newest first.
+func RecentAudit(ctx context.Context, q db.Querier, limit int) ([]AuditEntry, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT id, created_at, provider, prompt, COALESCE(reply,''), COALESCE(error,'')
+		FROM llm_audit ORDER BY id DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []AuditEntry
+	for rows.Next() {
+		var e AuditEntry
+		if err := rows.Scan(&e.ID, &e.CreatedAt, &e.Provider, &e.Prompt, &e.Reply, &e.Error); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
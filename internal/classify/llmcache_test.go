@@ -0,0 +1,38 @@
+package classify
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSuggestCategoryLLMCache(t *testing.T) {
+	ctx := context.Background()
+	d := openDB(t)
+	p := &FakeProvider{Replies: []string{
+		`{"category":"Streaming","reason":"video","confidence":"high"}`,
+		`{"category":"Other","reason":"","confidence":"low"}`,
+	}}
+	in := Input{Merchant: "Netflix", AmountCents: -1599}
+
+	first, err := SuggestCategoryLLM(ctx, d, p, in, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := SuggestCategoryLLM(ctx, d, p, Input{Merchant: " NETFLIX "}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Cached || !second.Cached || second.Category != "Streaming" || len(p.Prompts) != 1 {
+		t.Errorf("got %+v then %+v after %d prompts; want the second from the cache", first, second, len(p.Prompts))
+	}
+
+	// a rejected reply isn't cached
+	bad := &FakeProvider{Replies: []string{`{"category":"","reason":"","confidence":"high"}`}}
+	if _, err := SuggestCategoryLLM(ctx, d, bad, Input{Merchant: "SPOTIFY"}, nil); err == nil {
+		t.Error("want an error for an empty category")
+	}
+	var n int
+	if err := d.QueryRow(`SELECT COUNT(*) FROM llm_cache`).Scan(&n); err != nil || n != 1 {
+		t.Errorf("llm_cache has %d rows (%v), want 1", n, err)
+	}
+}
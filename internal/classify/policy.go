@@ -0,0 +1,236 @@
+package classify
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/anthurium-ai/personal-finance/internal/db"
+)
+
+// Threshold is one source's auto-apply setting: when Auto is set,
+// suggestions with at least MinConfidence are applied without review.
+type Threshold struct {
+	Source        string
+	Auto          bool
+	MinConfidence float64
+}
+
+// Policy decides which suggestions are applied automatically and which go
+// to the review queue, keyed by source.
+type Policy map[string]Threshold
+
+// PolicySources are the sources a policy covers, in pipeline order.
+var PolicySources = []string{SourceOverride, SourceRule, SourceModel, SourceLLM}
+
+// DefaultPolicy applies overrides and rules always, the local model at 0.9
+// and up, and LLM answers only when "high".
+func DefaultPolicy() Policy {
+	return Policy{
+		SourceOverride: {Source: SourceOverride, Auto: true, MinConfidence: 0},
+		SourceRule:     {Source: SourceRule, Auto: true, MinConfidence: 0},
+		SourceModel:    {Source: SourceModel, Auto: true, MinConfidence: 0.9},
+		SourceLLM:      {Source: SourceLLM, Auto: true, MinConfidence: LLMConfidence("high")},
+	}
+}
+
+// LoadPolicy reads auto_apply_policy over the defaults.
+func LoadPolicy(ctx context.Context, q db.Querier) (Policy, error) {
+	p := DefaultPolicy()
+	rows, err := q.QueryContext(ctx, `SELECT source, auto, min_confidence FROM auto_apply_policy`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var t Threshold
+		if err := rows.Scan(&t.Source, &t.Auto, &t.MinConfidence); err != nil {
+			return nil, err
+		}
+		p[t.Source] = t
+	}
+	return p, rows.Err()
+}
+
+// SavePolicy stores one source's threshold.
+func SavePolicy(ctx context.Context, q db.Querier, t Threshold) error {
+	if t.MinConfidence < 0 || t.MinConfidence > 1 {
+		return fmt.Errorf("%s: confidence must be between 0 and 1", t.Source)
+	}
+	_, err := q.ExecContext(ctx, `INSERT INTO auto_apply_policy (source, auto, min_confidence) VALUES (?,?,?)
+		ON CONFLICT(source) DO UPDATE SET auto=excluded.auto, min_confidence=excluded.min_confidence,
+			updated_at=strftime('%Y-%m-%dT%H:%M:%fZ','now')`, t.Source, t.Auto, t.MinConfidence)
+	return err
+}
+
+// List returns the thresholds in pipeline order.
+func (p Policy) List() []Threshold {
+	out := make([]Threshold, 0, len(PolicySources))
+	for _, s := range PolicySources {
+		out = append(out, p[s])
+	}
+	return out
+}
+
+// Auto reports whether s should be applied without review.
+func (p Policy) Auto(s *Suggestion) bool {
+	t, ok := p[s.Source]
+	// confidences are stored as floats; don't let 0.9 fall below 0.9
+	return ok && t.Auto && s.Confidence+1e-9 >= t.MinConfidence
+}
+
+// Outcome is what Route did with a suggestion.
+type Outcome int
+
+const (
+	Skipped Outcome = iota // manual category, already rejected, or already queued
+	Applied
+	Queued
+)
+
+// Route applies s to the transaction if the policy allows, recording the
+// previous category in auto_applied so it can be reverted, or otherwise
+// queues it for review. Manually-set categories are never touched, and a
+// category rejected for the transaction before is not suggested again.
+func Route(ctx context.Context, q db.Querier, p Policy, txID, jobID int64, s *Suggestion) (Outcome, error) {
+	var manual, rejected, queued bool
+	err := q.QueryRowContext(ctx, `
+		SELECT `+manualSQL+`,
+		       EXISTS (SELECT 1 FROM classification_suggestions s WHERE s.tx_id = transactions.id AND s.category_norm = ? AND s.status = 'rejected'),
+		       EXISTS (SELECT 1 FROM classification_suggestions s WHERE s.tx_id = transactions.id AND s.category_norm = ? AND s.status = 'pending')
+		FROM transactions WHERE id=?`, s.Category, s.Category, txID).Scan(&manual, &rejected, &queued)
+	if err != nil {
+		return Skipped, err
+	}
+	if manual || rejected {
+		return Skipped, nil
+	}
+
+	if !p.Auto(s) {
+		if queued {
+			return Skipped, nil
+		}
+		_, err := q.ExecContext(ctx, `INSERT INTO classification_suggestions (tx_id, job_id, source, rule_id, category_norm, reason, confidence)
+			VALUES (?,?,?,?,?,?,?)`, txID, nullID(jobID), s.Source, nullID(s.RuleID), s.Category, s.Reason, s.Confidence)
+		if err != nil {
+			return Skipped, err
+		}
+		return Queued, nil
+	}
+
+	_, err = q.ExecContext(ctx, `
+		INSERT INTO auto_applied (tx_id, job_id, source, category_norm, reason, confidence,
+			prev_category_norm, prev_source, prev_rule_id, prev_override_id, prev_reason, prev_confidence, prev_set_at)
+		SELECT id, ?, ?, ?, ?, ?,
+			category_norm, category_source, category_rule_id, category_override_id, category_reason, category_confidence, category_set_at
+		FROM transactions WHERE id=?`, nullID(jobID), s.Source, s.Category, s.Reason, s.Confidence, txID)
+	if err != nil {
+		return Skipped, err
+	}
+	if err := Record(ctx, q, txID, s, false); err != nil {
+		return Skipped, err
+	}
+	// a queued alternative is moot now
+	if _, err := q.ExecContext(ctx, `UPDATE classification_suggestions SET status=?, decided_at=strftime('%Y-%m-%dT%H:%M:%fZ','now')
+		WHERE tx_id=? AND status='pending'`, StatusSuperseded, txID); err != nil {
+		return Skipped, err
+	}
+	return Applied, nil
+}
+
+// AutoApplied is one automatic decision in the history.
+type AutoApplied struct {
+	ID         int64
+	CreatedAt  string
+	TxID       int64
+	Date       string
+	Merchant   string
+	Source     string
+	Category   string
+	Reason     string
+	Confidence float64
+	Previous   string // category before
+	RevertedAt string
+}
+
+// History lists automatic decisions, newest first. source filters by
+// source when not empty.
+func History(ctx context.Context, q db.Querier, source string, limit int) ([]AutoApplied, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT h.id, h.created_at, h.tx_id, t.txn_date, COALESCE(NULLIF(t.merchant_norm,''), t.merchant_raw, ''),
+		       h.source, h.category_norm, COALESCE(h.reason,''), COALESCE(h.confidence,0),
+		       COALESCE(h.prev_category_norm,''), COALESCE(h.reverted_at,'')
+		FROM auto_applied h JOIN transactions t ON t.id = h.tx_id
+		WHERE ? = '' OR h.source = ?
+		ORDER BY h.id DESC LIMIT ?`, source, source, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []AutoApplied
+	for rows.Next() {
+		var h AutoApplied
+		if err := rows.Scan(&h.ID, &h.CreatedAt, &h.TxID, &h.Date, &h.Merchant, &h.Source, &h.Category, &h.Reason,
+			&h.Confidence, &h.Previous, &h.RevertedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, h)
+	}
+	return out, rows.Err()
+}
+
+// Errors returned by Revert.
+var (
+	ErrNoDecision   = errors.New("no such decision, or already reverted")
+	ErrChangedSince = errors.New("category has changed since it was applied")
+)
+
+// Revert undoes an automatic decision: the transaction gets its previous
+// category back, and the decision counts as a rejection, so the override or
+// cached LLM answer behind it is dropped and it is not applied again.
+func Revert(ctx context.Context, d *sql.DB, id int64) error {
+	tx, err := d.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var txID, ruleID int64
+	var source, cat, reason, merchant string
+	var conf sql.NullFloat64
+	var current bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT h.tx_id, h.source, COALESCE(t.category_rule_id,0), h.category_norm, COALESCE(h.reason,''), h.confidence, COALESCE(t.merchant_norm,''),
+		       COALESCE(t.category_norm,'') = h.category_norm AND COALESCE(t.category_source,'') = h.source AND t.category_confirmed = 0
+		FROM auto_applied h JOIN transactions t ON t.id = h.tx_id
+		WHERE h.id=? AND h.reverted_at IS NULL`, id).Scan(&txID, &source, &ruleID, &cat, &reason, &conf, &merchant, &current)
+	if err == sql.ErrNoRows {
+		return ErrNoDecision
+	}
+	if err != nil {
+		return err
+	}
+	if !current {
+		return ErrChangedSince
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE transactions SET
+			(category_norm, category_source, category_rule_id, category_override_id, category_reason, category_confidence, category_set_at) =
+			(SELECT prev_category_norm, prev_source, prev_rule_id, prev_override_id, prev_reason, prev_confidence, prev_set_at FROM auto_applied WHERE id=?)
+		WHERE id=?`, id, txID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE auto_applied SET reverted_at=strftime('%Y-%m-%dT%H:%M:%fZ','now') WHERE id=?`, id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO classification_suggestions (tx_id, source, rule_id, category_norm, reason, confidence, status, decided_at)
+		VALUES (?,?,?,?,?,?,?,strftime('%Y-%m-%dT%H:%M:%fZ','now'))`, txID, source, nullID(ruleID), cat, reason, conf, StatusRejected); err != nil {
+		return err
+	}
+	if err := forget(ctx, tx, source, ruleID, merchant, cat); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
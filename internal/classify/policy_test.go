@@ -0,0 +1,121 @@
+package classify
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+)
+
+func TestPolicyAuto(t *testing.T) {
+	ctx := context.Background()
+	d := openDB(t)
+	if err := SavePolicy(ctx, d, Threshold{Source: SourceModel, Auto: true, MinConfidence: 1.5}); err == nil {
+		t.Error("want an error for a confidence over 1")
+	}
+	if err := SavePolicy(ctx, d, Threshold{Source: SourceModel, Auto: true, MinConfidence: 0.7}); err != nil {
+		t.Fatal(err)
+	}
+	p, err := LoadPolicy(ctx, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		source string
+		conf   float64
+		want   bool
+	}{
+		{SourceOverride, ConfidenceOverride, true},
+		{SourceRule, ConfidenceRule, true},
+		{SourceModel, 0.7, true}, // saved threshold, inclusive
+		{SourceModel, 0.69, false},
+		{SourceLLM, LLMConfidence("high"), true},
+		{SourceLLM, LLMConfidence("med"), false},
+		{SourceManual, 1, false}, // not a policy source
+	}
+	for _, tt := range tests {
+		if got := p.Auto(&Suggestion{Source: tt.source, Confidence: tt.conf}); got != tt.want {
+			t.Errorf("Auto(%s at %.2f) = %v, want %v", tt.source, tt.conf, got, tt.want)
+		}
+	}
+}
+
+func TestRouteRevert(t *testing.T) {
+	ctx := context.Background()
+	d := openDB(t)
+	p := DefaultPolicy()
+	bank := func(merchant string) int64 {
+		res, err := d.Exec(`INSERT INTO transactions (txn_date, amount_cents, merchant_norm, category_raw, category_norm, row_hash)
+			VALUES ('2026-03-01', -1000, ?, 'Shopping', 'Shopping', ?)`, merchant, merchant)
+		if err != nil {
+			t.Fatal(err)
+		}
+		id, _ := res.LastInsertId()
+		return id
+	}
+	res, err := d.Exec(`INSERT INTO merchant_category_overrides (merchant_norm, category_norm) VALUES ('KMART','Household')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	overrideID, _ := res.LastInsertId()
+	kmart, bp, uber := bank("KMART"), bank("BP"), bank("UBER")
+	manual := addTx(t, d, "COLES", "COLES", -1000, "Groceries")
+
+	route := func(txID int64, s *Suggestion, want Outcome) {
+		t.Helper()
+		got, err := Route(ctx, d, p, txID, 0, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("Route(%d, %s %s) = %v, want %v", txID, s.Source, s.Category, got, want)
+		}
+	}
+	ov := &Suggestion{Category: "Household", Source: SourceOverride, OverrideID: overrideID, Reason: "merchant override", Confidence: ConfidenceOverride}
+	low := &Suggestion{Category: "Fuel", Source: SourceModel, Reason: "model", Confidence: 0.5}
+	route(kmart, ov, Applied)
+	route(bp, low, Queued)
+	route(bp, low, Skipped) // already queued
+	route(manual, ov, Skipped)
+	route(uber, &Suggestion{Category: "Transport", Source: SourceRule, Reason: "rule", Confidence: ConfidenceRule}, Applied)
+
+	hist, err := History(ctx, d, SourceOverride, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hist) != 1 || hist[0].TxID != kmart || hist[0].Category != "Household" || hist[0].Previous != "Shopping" {
+		t.Fatalf("override history = %+v", hist)
+	}
+
+	// reverting restores the bank category and forgets the override
+	if err := Revert(ctx, d, hist[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	var cat string
+	var source, overrideSet sql.NullString
+	if err := d.QueryRow(`SELECT category_norm, category_source, category_override_id FROM transactions WHERE id=?`, kmart).Scan(&cat, &source, &overrideSet); err != nil {
+		t.Fatal(err)
+	}
+	if cat != "Shopping" || source.Valid || overrideSet.Valid {
+		t.Errorf("after revert: %s from %v override %v, want Shopping from the bank", cat, source, overrideSet)
+	}
+	var overrides int
+	if err := d.QueryRow(`SELECT COUNT(*) FROM merchant_category_overrides`).Scan(&overrides); err != nil || overrides != 0 {
+		t.Errorf("%d overrides left (%v), want the reverted one gone", overrides, err)
+	}
+	route(kmart, ov, Skipped) // rejected for this transaction now
+	if err := Revert(ctx, d, hist[0].ID); err != ErrNoDecision {
+		t.Errorf("second revert = %v, want ErrNoDecision", err)
+	}
+
+	// a category changed by hand since is not reverted
+	hist, err = History(ctx, d, SourceRule, 10)
+	if err != nil || len(hist) != 1 {
+		t.Fatalf("rule history = %+v, %v", hist, err)
+	}
+	if err := Record(ctx, d, uber, &Suggestion{Category: "Travel", Source: SourceManual, Confidence: ConfidenceManual}, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := Revert(ctx, d, hist[0].ID); err != ErrChangedSince {
+		t.Errorf("revert after an edit = %v, want ErrChangedSince", err)
+	}
+}
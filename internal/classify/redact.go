@@ -0,0 +1,74 @@
+package classify
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/anthurium-ai/personal-finance/internal/db"
+)
+
+// Redactor masks personal data in transaction text before it is put in an
+// LLM prompt: emails, BSBs, long numbers (cards, accounts, references), any
+// remaining digits, names from redaction_terms, and the words after transfer
+// keywords ("TRANSFER TO JANE CITIZEN").
+type Redactor struct {
+	names []*regexp.Regexp // longest term first
+}
+
+var (
+	emailRe   = regexp.MustCompile(`(?i)[a-z0-9._%+\-]+@[a-z0-9.\-]+\.[a-z]{2,}`)
+	bsbRe     = regexp.MustCompile(`\b\d{3}[- ]\d{3}\b`)
+	longNumRe = regexp.MustCompile(`\d[\d \-]{4,}\d`)
+	digitRe   = regexp.MustCompile(`\d`)
+	// up to three words after a transfer keyword are taken to be a payee/payer name
+	transferRe = regexp.MustCompile(`(?i)\b(TRANSFER TO|TRANSFER FROM|TFR TO|TFR FROM|PAYMENT TO|PAYMENT FROM|PAY TO)\s+([A-Z][A-Z'\-]*(?:\s+[A-Z][A-Z'\-]*){0,2})`)
+)
+
+// LoadRedactor reads the user's extra terms (household names etc.).
+func LoadRedactor(ctx context.Context, q db.Querier) (*Redactor, error) {
+	rows, err := q.QueryContext(ctx, `SELECT term FROM redaction_terms`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var terms []string
+	for rows.Next() {
+		var t string
+		if err := rows.Scan(&t); err != nil {
+			return nil, err
+		}
+		if t = strings.TrimSpace(t); t != "" {
+			terms = append(terms, t)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(terms, func(i, j int) bool { return len(terms[i]) > len(terms[j]) })
+	r := &Redactor{}
+	for _, t := range terms {
+		r.names = append(r.names, regexp.MustCompile(`(?i)\b`+regexp.QuoteMeta(t)+`\b`))
+	}
+	return r, nil
+}
+
+// Redact returns s with personal data masked.
+func (r *Redactor) Redact(s string) string {
+	s = emailRe.ReplaceAllString(s, "[email]")
+	s = bsbRe.ReplaceAllString(s, "[bsb]")
+	s = longNumRe.ReplaceAllString(s, "[number]")
+	s = digitRe.ReplaceAllString(s, "#")
+	for _, re := range r.names {
+		s = re.ReplaceAllString(s, "[name]")
+	}
+	s = transferRe.ReplaceAllStringFunc(s, func(m string) string {
+		sub := transferRe.FindStringSubmatch(m)
+		if strings.HasPrefix(sub[2], "[") {
+			return m
+		}
+		return sub[1] + " [name]"
+	})
+	return s
+}
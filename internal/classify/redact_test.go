@@ -0,0 +1,44 @@
+package classify
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRedactor(t *testing.T) {
+	ctx := context.Background()
+	d := openDB(t)
+	for _, term := range []string{"Jane", "Jane Citizen", " ", "Smithers"} {
+		if _, err := d.Exec(`INSERT INTO redaction_terms (term) VALUES (?)`, term); err != nil {
+			t.Fatal(err)
+		}
+	}
+	red, err := LoadRedactor(ctx, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		in, want string
+	}{
+		{"WOOLWORTHS SYDNEY", "WOOLWORTHS SYDNEY"},
+		{"CARD 4321 5678 9012 3456", "CARD [number]"},
+		{"REF 123456789", "REF [number]"},
+		{"BSB 062-000 ACC", "BSB [bsb] ACC"},
+		{"PAYPAL jane.citizen@example.com", "PAYPAL [email]"},
+		{"STORE 12", "STORE ##"},
+		// the longest term wins, matching whole words in any case
+		{"GIFT FOR jane citizen", "GIFT FOR [name]"},
+		{"JANEWAY CAFE", "JANEWAY CAFE"},
+		{"MR SMITHERS", "MR [name]"},
+		// words after a transfer keyword are a name, unless already masked
+		{"TRANSFER TO BOB BROWN RENT", "TRANSFER TO [name]"},
+		{"PAYMENT FROM Jane", "PAYMENT FROM [name]"},
+		{"TFR TO 062-000 12345678", "TFR TO [bsb] [number]"},
+	}
+	for _, tt := range tests {
+		if got := red.Redact(tt.in); got != tt.want {
+			t.Errorf("Redact(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
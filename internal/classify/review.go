@@ -0,0 +1,266 @@
+package classify
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/anthurium-ai/personal-finance/internal/db"
+)
+
+// Statuses stored in classification_suggestions.status.
+const (
+	StatusPending    = "pending"
+	StatusAccepted   = "accepted"
+	StatusRejected   = "rejected"
+	StatusSuperseded = "superseded" // another suggestion for the transaction was accepted
+)
+
+// PendingSuggestion is a queued suggestion with its transaction.
+type PendingSuggestion struct {
+	ID          int64
+	TxID        int64
+	Date        string
+	AmountCents int64
+	Merchant    string
+	Details     string
+	Current     string // the transaction's category now
+	Source      string
+	Category    string
+	Reason      string
+	Confidence  float64
+}
+
+// Pending lists pending suggestions, most confident first. source filters
+// by source when not empty.
+func Pending(ctx context.Context, q db.Querier, source string, limit int) ([]PendingSuggestion, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT s.id, t.id, t.txn_date, t.amount_cents,
+		       COALESCE(NULLIF(t.merchant_norm,''), t.merchant_raw, ''), COALESCE(t.details,''),
+		       COALESCE(NULLIF(t.category_norm,''), t.category_raw, ''),
+		       s.source, s.category_norm, COALESCE(s.reason,''), COALESCE(s.confidence,0)
+		FROM classification_suggestions s
+		JOIN transactions t ON t.id = s.tx_id
+		WHERE s.status = 'pending' AND (? = '' OR s.source = ?)
+		ORDER BY s.confidence DESC, t.txn_date DESC, s.id
+		LIMIT ?`, source, source, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []PendingSuggestion
+	for rows.Next() {
+		var p PendingSuggestion
+		if err := rows.Scan(&p.ID, &p.TxID, &p.Date, &p.AmountCents, &p.Merchant, &p.Details, &p.Current,
+			&p.Source, &p.Category, &p.Reason, &p.Confidence); err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+// QueueSuggestions runs overrides, rules and the local model over
+// uncategorised transactions and queues what they propose for review instead
+// of applying it. Transactions with a pending suggestion are skipped, as are
+// proposals already rejected for that transaction.
+func QueueSuggestions(ctx context.Context, d *sql.DB) (int, error) {
+	cls, err := Load(ctx, d)
+	if err != nil {
+		return 0, err
+	}
+	if err := cls.LoadModel(ctx, d); err != nil {
+		return 0, err
+	}
+
+	rows, err := d.QueryContext(ctx, `
+		SELECT id, COALESCE(merchant_norm,''), COALESCE(details,''), amount_cents, COALESCE(account,'')
+		FROM transactions
+		WHERE `+uncategorisedSQL+`
+		  AND NOT EXISTS (SELECT 1 FROM classification_suggestions s WHERE s.tx_id = transactions.id AND s.status = 'pending')`)
+	if err != nil {
+		return 0, err
+	}
+	type cand struct {
+		id int64
+		s  *Suggestion
+	}
+	var cands []cand
+	for rows.Next() {
+		var id int64
+		var in Input
+		if err := rows.Scan(&id, &in.Merchant, &in.Details, &in.AmountCents, &in.Account); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if s := cls.Suggest(in); s != nil {
+			cands = append(cands, cand{id, s})
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for _, c := range cands {
+		res, err := d.ExecContext(ctx, `
+			INSERT INTO classification_suggestions (tx_id, source, rule_id, category_norm, reason, confidence)
+			SELECT ?,?,?,?,?,?
+			WHERE NOT EXISTS (SELECT 1 FROM classification_suggestions WHERE tx_id=? AND category_norm=? AND status='rejected')`,
+			c.id, c.s.Source, nullID(c.s.RuleID), c.s.Category, c.s.Reason, c.s.Confidence, c.id, c.s.Category)
+		if err != nil {
+			return n, err
+		}
+		k, _ := res.RowsAffected()
+		n += int(k)
+	}
+	return n, nil
+}
+
+// Decision is one reviewed suggestion. An accept with Category set (and
+// different from the suggestion) is an edit: the transaction gets that
+// category as a manual change.
+type Decision struct {
+	ID       int64
+	Accept   bool
+	Category string
+}
+
+type ReviewResult struct {
+	Accepted int
+	Edited   int
+	Rejected int
+	Skipped  int // no longer pending
+}
+
+// Review applies decisions in one transaction.
+//
+// Accepting sets and confirms the transaction's category, keeping the
+// suggestion's provenance unless it was edited, teaches the local model, and
+// points the merchant's override at the category. Other pending suggestions
+// for the transaction are superseded.
+//
+// Rejecting stops the suggestion coming back: an override that produced it
+// is removed, as is the cached LLM answer for the merchant, and the same
+// category is not queued again for that transaction.
+func Review(ctx context.Context, d *sql.DB, decisions []Decision) (*ReviewResult, error) {
+	tx, err := d.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	res := &ReviewResult{}
+	for _, dec := range decisions {
+		var txID, ruleID int64
+		var source, cat, reason, merchant string
+		var conf sql.NullFloat64
+		err := tx.QueryRowContext(ctx, `
+			SELECT s.tx_id, s.source, COALESCE(s.rule_id,0), s.category_norm, COALESCE(s.reason,''), s.confidence, COALESCE(t.merchant_norm,'')
+			FROM classification_suggestions s JOIN transactions t ON t.id = s.tx_id
+			WHERE s.id=? AND s.status='pending'`, dec.ID).Scan(&txID, &source, &ruleID, &cat, &reason, &conf, &merchant)
+		if err == sql.ErrNoRows {
+			res.Skipped++
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		status := StatusRejected
+		if dec.Accept {
+			status = StatusAccepted
+			s := &Suggestion{Category: cat, Source: source, RuleID: ruleID, Reason: reason, Confidence: conf.Float64}
+			if edited := strings.TrimSpace(dec.Category); edited != "" && edited != cat {
+				s = &Suggestion{Category: edited, Source: SourceManual, Reason: fmt.Sprintf("edited in review (%s suggested %s)", source, cat), Confidence: ConfidenceManual}
+				res.Edited++
+			} else {
+				res.Accepted++
+			}
+			if err := Record(ctx, tx, txID, s, true); err != nil {
+				return nil, err
+			}
+			if err := Learn(ctx, tx, txID); err != nil {
+				return nil, err
+			}
+			if merchant != "" {
+				if _, err := tx.ExecContext(ctx, `INSERT INTO merchant_category_overrides (merchant_norm, category_norm) VALUES (?,?)
+					ON CONFLICT(merchant_norm) DO UPDATE SET category_norm=excluded.category_norm`, merchant, s.Category); err != nil {
+					return nil, err
+				}
+			}
+			if _, err := tx.ExecContext(ctx, `UPDATE classification_suggestions SET status=?, decided_at=strftime('%Y-%m-%dT%H:%M:%fZ','now')
+				WHERE tx_id=? AND status='pending' AND id != ?`, StatusSuperseded, txID, dec.ID); err != nil {
+				return nil, err
+			}
+		} else {
+			res.Rejected++
+			if err := forget(ctx, tx, source, ruleID, merchant, cat); err != nil {
+				return nil, err
+			}
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE classification_suggestions SET status=?, decided_at=strftime('%Y-%m-%dT%H:%M:%fZ','now') WHERE id=?`, status, dec.ID); err != nil {
+			return nil, err
+		}
+	}
+	return res, tx.Commit()
+}
+
+// forget feeds a rejection back to the source that suggested cat for
+// merchant: the merchant override or cached LLM answer is dropped, the local
+// model stops suggesting cat for the merchant, and the rule's rejections are
+// counted so it shows up on the review page.
+func forget(ctx context.Context, q db.Querier, source string, ruleID int64, merchant, cat string) error {
+	if source == SourceRule && ruleID != 0 {
+		_, err := q.ExecContext(ctx, `UPDATE category_rules SET rejections = rejections + 1 WHERE id=?`, ruleID)
+		return err
+	}
+	if merchant == "" {
+		return nil
+	}
+	var err error
+	switch source {
+	case SourceModel:
+		_, err = q.ExecContext(ctx, `INSERT INTO model_rejections (merchant_norm, category_norm) VALUES (?,?) ON CONFLICT DO NOTHING`, merchant, cat)
+	case SourceOverride:
+		_, err = q.ExecContext(ctx, `DELETE FROM merchant_category_overrides WHERE merchant_norm=? AND category_norm=?`, merchant, cat)
+	case SourceLLM:
+		_, err = q.ExecContext(ctx, `DELETE FROM llm_cache WHERE merchant_norm=? AND category_norm=?`, cacheKey(merchant), cat)
+	}
+	return err
+}
+
+// RejectedRule is an enabled rule whose suggestions have been rejected.
+type RejectedRule struct {
+	ID         int64
+	Contains   string
+	Category   string
+	Rejections int
+}
+
+// RejectedRules lists enabled rules with rejections, most rejected first.
+func RejectedRules(ctx context.Context, q db.Querier) ([]RejectedRule, error) {
+	rows, err := q.QueryContext(ctx, `SELECT id, match_contains, category_norm, rejections FROM category_rules
+		WHERE enabled=1 AND rejections > 0 ORDER BY rejections DESC, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []RejectedRule
+	for rows.Next() {
+		var r RejectedRule
+		if err := rows.Scan(&r.ID, &r.Contains, &r.Category, &r.Rejections); err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+// DisableRule stops a rule from suggesting anything.
+func DisableRule(ctx context.Context, q db.Querier, id int64) error {
+	_, err := q.ExecContext(ctx, `UPDATE category_rules SET enabled=0 WHERE id=?`, id)
+	return err
+}
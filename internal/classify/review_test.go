@@ -0,0 +1,161 @@
+package classify
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+)
+
+func TestReview(t *testing.T) {
+	ctx := context.Background()
+	d := openDB(t)
+	exec := func(q string, args ...any) int64 {
+		t.Helper()
+		res, err := d.Exec(q, args...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		id, _ := res.LastInsertId()
+		return id
+	}
+	tx := func(merchant string) int64 {
+		return exec(`INSERT INTO transactions (txn_date, amount_cents, merchant_norm, category_raw, row_hash) VALUES ('2026-03-01', -1000, ?, 'Misc', ?)`, merchant, merchant)
+	}
+	suggest := func(txID int64, source string, ruleID any, cat string) int64 {
+		return exec(`INSERT INTO classification_suggestions (tx_id, source, rule_id, category_norm, reason, confidence) VALUES (?,?,?,?,'because',0.8)`, txID, source, ruleID, cat)
+	}
+
+	ruleID := exec(`INSERT INTO category_rules (match_contains, category_norm) VALUES ('UBER','Transport')`)
+	exec(`INSERT INTO merchant_category_overrides (merchant_norm, category_norm) VALUES ('KMART','Household')`)
+	exec(`INSERT INTO llm_cache (merchant_norm, prompt_version, category_norm, confidence, provider) VALUES ('SPOTIFY',?,'Music','high','fake')`, PromptVersion)
+
+	uber, coles, netflix, kmart, spotify, bp := tx("UBER"), tx("COLES"), tx("NETFLIX"), tx("KMART"), tx("SPOTIFY"), tx("BP")
+	acceptRule := suggest(uber, SourceRule, ruleID, "Transport")
+	superseded := suggest(uber, SourceModel, nil, "Travel")
+	edit := suggest(coles, SourceModel, nil, "Dining")
+	rejectModel := suggest(netflix, SourceModel, nil, "Groceries")
+	rejectOverride := suggest(kmart, SourceOverride, nil, "Household")
+	rejectLLM := suggest(spotify, SourceLLM, nil, "Music")
+	rejectRule := suggest(bp, SourceRule, ruleID, "Transport")
+
+	res, err := Review(ctx, d, []Decision{
+		{ID: acceptRule, Accept: true},
+		{ID: edit, Accept: true, Category: "Groceries"},
+		{ID: rejectModel},
+		{ID: rejectOverride},
+		{ID: rejectLLM},
+		{ID: rejectRule},
+		{ID: superseded, Accept: true}, // no longer pending
+		{ID: 9999},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *res != (ReviewResult{Accepted: 1, Edited: 1, Rejected: 4, Skipped: 2}) {
+		t.Errorf("result %+v", *res)
+	}
+
+	// transactions
+	tests := []struct {
+		txID      int64
+		cat       string
+		source    string
+		ruleID    int64
+		confirmed bool
+	}{
+		{uber, "Transport", SourceRule, ruleID, true},
+		{coles, "Groceries", SourceManual, 0, true},
+		{netflix, "", "", 0, false},
+		{bp, "", "", 0, false},
+	}
+	for _, tt := range tests {
+		var cat, source string
+		var rule sql.NullInt64
+		var confirmed bool
+		if err := d.QueryRow(`SELECT COALESCE(category_norm,''), COALESCE(category_source,''), category_rule_id, category_confirmed FROM transactions WHERE id=?`, tt.txID).
+			Scan(&cat, &source, &rule, &confirmed); err != nil {
+			t.Fatal(err)
+		}
+		if cat != tt.cat || source != tt.source || rule.Int64 != tt.ruleID || confirmed != tt.confirmed {
+			t.Errorf("tx %d: %q %q rule %d confirmed %v, want %q %q rule %d confirmed %v", tt.txID, cat, source, rule.Int64, confirmed, tt.cat, tt.source, tt.ruleID, tt.confirmed)
+		}
+	}
+
+	// suggestion statuses
+	for id, want := range map[int64]string{
+		acceptRule: StatusAccepted, superseded: StatusSuperseded, edit: StatusAccepted,
+		rejectModel: StatusRejected, rejectOverride: StatusRejected, rejectLLM: StatusRejected, rejectRule: StatusRejected,
+	} {
+		var status string
+		if err := d.QueryRow(`SELECT status FROM classification_suggestions WHERE id=?`, id).Scan(&status); err != nil {
+			t.Fatal(err)
+		}
+		if status != want {
+			t.Errorf("suggestion %d is %s, want %s", id, status, want)
+		}
+	}
+
+	// what accepting taught and rejecting forgot
+	count := func(q string, args ...any) int {
+		t.Helper()
+		var n int
+		if err := d.QueryRow(q, args...).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	checks := []struct {
+		name string
+		got  int
+		want int
+	}{
+		{"examples learned", count(`SELECT COUNT(*) FROM model_examples`), 2},
+		{"override for the accepted merchant", count(`SELECT COUNT(*) FROM merchant_category_overrides WHERE merchant_norm='UBER' AND category_norm='Transport'`), 1},
+		{"override for the edited merchant", count(`SELECT COUNT(*) FROM merchant_category_overrides WHERE merchant_norm='COLES' AND category_norm='Groceries'`), 1},
+		{"rejected override removed", count(`SELECT COUNT(*) FROM merchant_category_overrides WHERE merchant_norm='KMART'`), 0},
+		{"rejected LLM answer uncached", count(`SELECT COUNT(*) FROM llm_cache WHERE merchant_norm='SPOTIFY'`), 0},
+		{"model rejection kept", count(`SELECT COUNT(*) FROM model_rejections WHERE merchant_norm='NETFLIX' AND category_norm='Groceries'`), 1},
+		{"rule rejection counted", count(`SELECT rejections FROM category_rules WHERE id=?`, ruleID), 1},
+	}
+	for _, c := range checks {
+		if c.got != c.want {
+			t.Errorf("%s: %d, want %d", c.name, c.got, c.want)
+		}
+	}
+
+	rules, err := RejectedRules(ctx, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 1 || rules[0].ID != ruleID || rules[0].Rejections != 1 {
+		t.Errorf("RejectedRules = %+v", rules)
+	}
+}
+
+func TestQueueSuggestionsSkipsRejected(t *testing.T) {
+	ctx := context.Background()
+	d := openDB(t)
+	if _, err := d.Exec(`INSERT INTO category_rules (match_contains, category_norm) VALUES ('UBER','Transport')`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Exec(`INSERT INTO transactions (txn_date, amount_cents, merchant_norm, details, row_hash) VALUES ('2026-03-01', -1000, 'UBER', 'UBER TRIP', 'a')`); err != nil {
+		t.Fatal(err)
+	}
+	n, err := QueueSuggestions(ctx, d)
+	if err != nil || n != 1 {
+		t.Fatalf("queued %d (%v), want 1", n, err)
+	}
+	var id, ruleID int64
+	if err := d.QueryRow(`SELECT id, COALESCE(rule_id,0) FROM classification_suggestions`).Scan(&id, &ruleID); err != nil {
+		t.Fatal(err)
+	}
+	if ruleID == 0 {
+		t.Error("queued rule suggestion has no rule id")
+	}
+	if _, err := Review(ctx, d, []Decision{{ID: id}}); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := QueueSuggestions(ctx, d); err != nil || n != 0 {
+		t.Errorf("queued %d (%v) after rejecting, want 0", n, err)
+	}
+}
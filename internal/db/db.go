@@ -13,6 +13,53 @@ import (
 //go:embed schema.sql
 var schemaFS embed.FS
 
+// column is a column added to a table after it first shipped.
+type column struct {
+	table string
+	name  string
+	decl  string
+
+	backfill string // optional UPDATE run once, right after the column is added
+}
+
+// addedColumns are applied to existing databases with ALTER TABLE.
+// Fresh databases get them from the CREATE TABLE in schema.sql.
+var addedColumns = []column{
+	{"transactions", "category_source", "TEXT", ""},
+	{"transactions", "category_rule_id", "INTEGER", ""},
+	{"transactions", "category_reason", "TEXT", ""},
+	{"transactions", "category_override_id", "INTEGER", ""},
+	{"transactions", "category_confidence", "REAL", ""},
+	{"transactions", "category_set_at", "TEXT", ""},
+	{"transactions", "category_confirmed", "INTEGER NOT NULL DEFAULT 0", ""},
+	{"transactions", "merchant_manual", "INTEGER NOT NULL DEFAULT 0",
+		`UPDATE transactions SET merchant_manual=1 WHERE COALESCE(merchant_norm,'') != '' AND merchant_norm != TRIM(COALESCE(merchant_raw,''))`},
+	{"classification_suggestions", "rule_id", "INTEGER", ""},
+	{"category_rules", "rejections", "INTEGER NOT NULL DEFAULT 0", ""},
+	{"imports", "rows_classified", "INTEGER NOT NULL DEFAULT 0", ""},
+	{"imports", "rows_by_override", "INTEGER NOT NULL DEFAULT 0", ""},
+	{"imports", "rows_by_rule", "INTEGER NOT NULL DEFAULT 0", ""},
+	{"imports", "rows_queued", "INTEGER NOT NULL DEFAULT 0", ""},
+	{"merchant_aliases", "exact", "INTEGER NOT NULL DEFAULT 0", ""},
+}
+
+// CategorySQL is a transaction's category as the spend metrics see it: the
+// normalised category, else the bank's, else Uncategorised. A non-empty
+// alias qualifies the columns, for queries that join other tables.
+func CategorySQL(alias string) string {
+	if alias != "" {
+		alias += "."
+	}
+	return "COALESCE(NULLIF(" + alias + "category_norm,''), COALESCE(NULLIF(" + alias + "category_raw,''),'Uncategorised'))"
+}
+
+// Querier is satisfied by *sql.DB and *sql.Tx.
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
 func Open(path string) (*sql.DB, error) {
 	if err := ensureDir(path); err != nil {
 		return nil, err
@@ -34,10 +81,68 @@ func Migrate(ctx context.Context, db *sql.DB) error {
 	if err != nil {
 		return err
 	}
-	_, err = db.ExecContext(ctx, string(b))
+	// columns first, so indexes in schema.sql can reference them
+	if err := addColumns(ctx, db); err != nil {
+		return err
+	}
+	// the search index is filled from existing transactions when first created
+	_, indexed, err := hasColumn(ctx, db, "transactions_fts", "details")
+	if err != nil {
+		return err
+	}
+	if _, err := db.ExecContext(ctx, string(b)); err != nil {
+		return err
+	}
+	if !indexed {
+		_, err = db.ExecContext(ctx, `INSERT INTO transactions_fts(transactions_fts) VALUES('rebuild')`)
+	}
 	return err
 }
 
+func addColumns(ctx context.Context, db *sql.DB) error {
+	for _, c := range addedColumns {
+		have, exists, err := hasColumn(ctx, db, c.table, c.name)
+		if err != nil {
+			return err
+		}
+		if !exists || have {
+			// table missing: schema.sql creates it with the column
+			continue
+		}
+		if _, err := db.ExecContext(ctx, fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, c.table, c.name, c.decl)); err != nil {
+			return fmt.Errorf("add column %s.%s: %w", c.table, c.name, err)
+		}
+		if c.backfill != "" {
+			if _, err := db.ExecContext(ctx, c.backfill); err != nil {
+				return fmt.Errorf("backfill %s.%s: %w", c.table, c.name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// hasColumn reports whether table has the column, and whether table exists at all.
+func hasColumn(ctx context.Context, db *sql.DB, table, name string) (have bool, exists bool, err error) {
+	rows, err := db.QueryContext(ctx, fmt.Sprintf(`PRAGMA table_info(%s)`, table))
+	if err != nil {
+		return false, false, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		exists = true
+		var cid, notNull, pk int
+		var colName, colType string
+		var dflt sql.NullString
+		if err := rows.Scan(&cid, &colName, &colType, &notNull, &dflt, &pk); err != nil {
+			return false, false, err
+		}
+		if colName == name {
+			have = true
+		}
+	}
+	return have, exists, rows.Err()
+}
+
 func ensureDir(dbPath string) error {
 	dir := filepath.Dir(dbPath)
 	return mkdirAll(dir)
@@ -9,6 +9,10 @@ CREATE TABLE IF NOT EXISTS imports (
   rows_total INTEGER NOT NULL,
   rows_inserted INTEGER NOT NULL,
   rows_skipped INTEGER NOT NULL,
+  rows_classified INTEGER NOT NULL DEFAULT 0,
+  rows_by_override INTEGER NOT NULL DEFAULT 0,
+  rows_by_rule INTEGER NOT NULL DEFAULT 0,
+  rows_queued INTEGER NOT NULL DEFAULT 0, -- suggestions sent to review
   notes TEXT
 );
 
@@ -29,9 +33,19 @@ CREATE TABLE IF NOT EXISTS transactions (
   merchant_raw TEXT,
 
   merchant_norm TEXT,
+  merchant_manual INTEGER NOT NULL DEFAULT 0, -- merchant_norm edited by hand
   category_norm TEXT,
   notes TEXT,
 
+  -- provenance of category_norm: bank|override|rule|llm|manual (NULL = bank)
+  category_source TEXT,
+  category_rule_id INTEGER,
+  category_override_id INTEGER,
+  category_reason TEXT,
+  category_confidence REAL,
+  category_set_at TEXT,
+  category_confirmed INTEGER NOT NULL DEFAULT 0,
+
   row_hash TEXT NOT NULL,
   created_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now')),
 
@@ -44,7 +58,8 @@ CREATE TABLE IF NOT EXISTS category_rules (
   enabled INTEGER NOT NULL DEFAULT 1,
 
   match_contains TEXT NOT NULL,
-  category_norm TEXT NOT NULL
+  category_norm TEXT NOT NULL,
+  rejections INTEGER NOT NULL DEFAULT 0 -- suggestions from this rule rejected in review
 );
 
 CREATE TABLE IF NOT EXISTS merchant_category_overrides (
@@ -56,6 +71,348 @@ CREATE TABLE IF NOT EXISTS merchant_category_overrides (
   UNIQUE(merchant_norm)
 );
 
+-- user-maintained merchant aliases, matched against the cleaned merchant name
+CREATE TABLE IF NOT EXISTS merchant_aliases (
+  id INTEGER PRIMARY KEY,
+  created_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now')),
+
+  alias TEXT NOT NULL,
+  merchant_norm TEXT NOT NULL,
+  exact INTEGER NOT NULL DEFAULT 0, -- matches the whole cleaned name only, not as a prefix
+  UNIQUE(alias)
+);
+
+-- local classifier (naive Bayes) trained on confirmed categories;
+-- features are what each transaction contributed, newline-separated
+CREATE TABLE IF NOT EXISTS model_examples (
+  tx_id INTEGER PRIMARY KEY REFERENCES transactions(id) ON DELETE CASCADE,
+  category_norm TEXT NOT NULL,
+  features TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS model_token_counts (
+  category_norm TEXT NOT NULL,
+  token TEXT NOT NULL,
+  count INTEGER NOT NULL,
+  PRIMARY KEY(category_norm, token)
+);
+
+-- categories the local model may not suggest for a merchant, after a model
+-- suggestion was rejected; confirming the category lifts it
+CREATE TABLE IF NOT EXISTS model_rejections (
+  merchant_norm TEXT NOT NULL,
+  category_norm TEXT NOT NULL,
+  created_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now')),
+  PRIMARY KEY(merchant_norm, category_norm)
+);
+
+-- background jobs, for progress in the portal
+CREATE TABLE IF NOT EXISTS jobs (
+  id INTEGER PRIMARY KEY,
+  created_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now')),
+  finished_at TEXT,
+  kind TEXT NOT NULL,
+  status TEXT NOT NULL, -- running|done|failed
+  total INTEGER NOT NULL DEFAULT 0,
+  done INTEGER NOT NULL DEFAULT 0,
+  message TEXT
+);
+
+-- review queue: category suggestions awaiting a decision
+CREATE TABLE IF NOT EXISTS classification_suggestions (
+  id INTEGER PRIMARY KEY,
+  created_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now')),
+  tx_id INTEGER NOT NULL REFERENCES transactions(id) ON DELETE CASCADE,
+  job_id INTEGER REFERENCES jobs(id) ON DELETE SET NULL,
+
+  source TEXT NOT NULL, -- override|rule|model|llm
+  rule_id INTEGER, -- when source is rule
+  category_norm TEXT NOT NULL,
+  reason TEXT,
+  confidence REAL,
+
+  status TEXT NOT NULL DEFAULT 'pending', -- pending|accepted|rejected|superseded
+  decided_at TEXT
+);
+
+-- per-source thresholds above which suggestions are applied without review;
+-- sources without a row use classify.DefaultPolicy
+CREATE TABLE IF NOT EXISTS auto_apply_policy (
+  source TEXT PRIMARY KEY,
+  auto INTEGER NOT NULL,
+  min_confidence REAL NOT NULL,
+  updated_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now'))
+);
+
+-- every automatic category decision, with what it replaced, so it can be reverted
+CREATE TABLE IF NOT EXISTS auto_applied (
+  id INTEGER PRIMARY KEY,
+  created_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now')),
+  tx_id INTEGER NOT NULL REFERENCES transactions(id) ON DELETE CASCADE,
+  job_id INTEGER REFERENCES jobs(id) ON DELETE SET NULL,
+
+  source TEXT NOT NULL,
+  category_norm TEXT NOT NULL,
+  reason TEXT,
+  confidence REAL,
+
+  prev_category_norm TEXT,
+  prev_source TEXT,
+  prev_rule_id INTEGER,
+  prev_override_id INTEGER,
+  prev_reason TEXT,
+  prev_confidence REAL,
+  prev_set_at TEXT,
+
+  reverted_at TEXT
+);
+
+-- alerts inbox: unusual transactions and category spend spikes
+CREATE TABLE IF NOT EXISTS anomalies (
+  id INTEGER PRIMARY KEY,
+  created_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now')),
+  kind TEXT NOT NULL, -- large_amount|new_merchant|foreign|duplicate|category_spike
+  key TEXT NOT NULL UNIQUE, -- identifies the finding across scans
+  tx_id INTEGER REFERENCES transactions(id) ON DELETE CASCADE,
+  category TEXT,
+  month TEXT, -- YYYY-MM, category_spike only
+  amount_cents INTEGER,
+  title TEXT NOT NULL,
+  detail TEXT,
+
+  status TEXT NOT NULL DEFAULT 'open', -- open|dismissed
+  decided_at TEXT
+);
+
+-- spending budgets per category; a category's budget is the row with the
+-- latest start_month on or before the month in question
+CREATE TABLE IF NOT EXISTS budgets (
+  id INTEGER PRIMARY KEY,
+  category_norm TEXT NOT NULL,
+  period TEXT NOT NULL DEFAULT 'monthly', -- monthly|quarterly|annual
+  amount_cents INTEGER NOT NULL,
+  start_month TEXT NOT NULL, -- YYYY-MM
+  created_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now')),
+  updated_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now')),
+  UNIQUE(category_norm, start_month)
+);
+
+-- envelope budgeting ledger: money assigned to, or moved between, category
+-- envelopes; a category's assigned amount for a month is the sum of its rows
+CREATE TABLE IF NOT EXISTS envelope_entries (
+  id INTEGER PRIMARY KEY,
+  created_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now')),
+  month TEXT NOT NULL, -- YYYY-MM
+  category_norm TEXT NOT NULL,
+  amount_cents INTEGER NOT NULL,
+  kind TEXT NOT NULL, -- assign|move
+  counterpart TEXT, -- move only: the other envelope, '' for ready to assign
+  note TEXT
+);
+
+-- savings goals, funded by the transactions on an account or tagged #tag in
+-- their notes, counted from start_date
+CREATE TABLE IF NOT EXISTS goals (
+  id INTEGER PRIMARY KEY,
+  created_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now')),
+  name TEXT NOT NULL UNIQUE,
+  target_cents INTEGER NOT NULL,
+  target_date TEXT NOT NULL, -- YYYY-MM-DD
+  account TEXT,
+  tag TEXT,
+  opening_cents INTEGER NOT NULL DEFAULT 0, -- saved before start_date
+  start_date TEXT NOT NULL -- YYYY-MM-DD
+);
+
+-- known account balances for the cash flow forecast, with the floor each
+-- account shouldn't be projected to fall below
+CREATE TABLE IF NOT EXISTS account_balances (
+  account TEXT PRIMARY KEY,
+  balance_cents INTEGER NOT NULL,
+  as_of TEXT NOT NULL, -- YYYY-MM-DD, balance at the end of the day
+  floor_cents INTEGER NOT NULL DEFAULT 0,
+  updated_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now'))
+);
+
+-- scheduled transactions (rent, insurance, rates, rego) and their recurrence
+CREATE TABLE IF NOT EXISTS bills (
+  id INTEGER PRIMARY KEY,
+  created_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now')),
+  name TEXT NOT NULL,
+  amount_cents INTEGER NOT NULL, -- signed: bills negative, income positive
+  account TEXT,
+  category_norm TEXT,
+  match_text TEXT NOT NULL, -- found in the paying transaction's merchant or details
+  rule TEXT NOT NULL, -- monthly|weekly|yearly|last_business_day
+  interval INTEGER NOT NULL DEFAULT 1,
+  day INTEGER NOT NULL DEFAULT 0, -- monthly only
+  start_date TEXT NOT NULL, -- YYYY-MM-DD, also the anchor for weekly and yearly
+  end_date TEXT,
+  tolerance_days INTEGER NOT NULL DEFAULT 3,
+  tolerance_pct INTEGER NOT NULL DEFAULT 10
+);
+
+-- which occurrences of a bill are paid: by a matched transaction, or marked
+-- paid by hand (tx_id NULL)
+CREATE TABLE IF NOT EXISTS bill_payments (
+  bill_id INTEGER NOT NULL REFERENCES bills(id) ON DELETE CASCADE,
+  due_date TEXT NOT NULL, -- YYYY-MM-DD
+  tx_id INTEGER REFERENCES transactions(id) ON DELETE CASCADE,
+  created_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now')),
+  PRIMARY KEY(bill_id, due_date)
+);
+
+-- manually valued assets and liabilities for net worth
+CREATE TABLE IF NOT EXISTS assets (
+  id INTEGER PRIMARY KEY,
+  created_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now')),
+  name TEXT NOT NULL UNIQUE,
+  kind TEXT NOT NULL, -- property|vehicle|super|investment|cash|other, or loan|mortgage|credit|other
+  liability INTEGER NOT NULL DEFAULT 0
+);
+
+-- an asset's value (or what a liability owes, positive) from valued_on on
+CREATE TABLE IF NOT EXISTS asset_valuations (
+  asset_id INTEGER NOT NULL REFERENCES assets(id) ON DELETE CASCADE,
+  valued_on TEXT NOT NULL, -- YYYY-MM-DD
+  value_cents INTEGER NOT NULL,
+  note TEXT,
+  PRIMARY KEY(asset_id, valued_on)
+);
+
+-- loans and mortgages; principal_cents is owed on start_date, with
+-- term_months to go from then
+CREATE TABLE IF NOT EXISTS loans (
+  id INTEGER PRIMARY KEY,
+  created_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now')),
+  name TEXT NOT NULL UNIQUE,
+  principal_cents INTEGER NOT NULL,
+  start_date TEXT NOT NULL, -- YYYY-MM-DD
+  term_months INTEGER NOT NULL,
+  frequency TEXT NOT NULL, -- weekly|fortnightly|monthly
+  repayment_cents INTEGER NOT NULL DEFAULT 0, -- 0 pays the minimum
+  match_text TEXT NOT NULL, -- found in repayments' merchant or details
+  account TEXT, -- repayments come from, if set
+  offset_account TEXT
+);
+
+-- a loan's interest rate history
+CREATE TABLE IF NOT EXISTS loan_rates (
+  loan_id INTEGER NOT NULL REFERENCES loans(id) ON DELETE CASCADE,
+  effective_from TEXT NOT NULL, -- YYYY-MM-DD
+  rate_pct REAL NOT NULL, -- annual
+  PRIMARY KEY(loan_id, effective_from)
+);
+
+-- debts for the payoff planner, with balances as on a statement
+CREATE TABLE IF NOT EXISTS debts (
+  id INTEGER PRIMARY KEY,
+  created_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now')),
+  name TEXT NOT NULL UNIQUE,
+  balance_cents INTEGER NOT NULL,
+  as_of TEXT NOT NULL, -- YYYY-MM-DD
+  rate_pct REAL NOT NULL, -- annual
+  minimum_cents INTEGER NOT NULL DEFAULT 0, -- monthly
+  priority INTEGER NOT NULL DEFAULT 0, -- custom order, lowest first
+  account TEXT, -- the debt's own account: money in is a payment
+  match_text TEXT -- or payments going out with this in merchant or details
+);
+
+-- the payoff plan being followed; one row
+CREATE TABLE IF NOT EXISTS debt_plan (
+  id INTEGER PRIMARY KEY CHECK (id = 1),
+  budget_cents INTEGER NOT NULL, -- a month across every debt
+  strategy TEXT NOT NULL, -- snowball|avalanche|custom
+  start_month TEXT NOT NULL, -- YYYY-MM
+  updated_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now'))
+);
+
+-- spending categories whose transactions are tax relevant (tax.Categories)
+CREATE TABLE IF NOT EXISTS tax_category_map (
+  category_norm TEXT PRIMARY KEY,
+  tax_category TEXT NOT NULL
+);
+
+-- a transaction's own tax details, overriding its category's mapping;
+-- tax_category 'none' leaves it out of the tax report
+CREATE TABLE IF NOT EXISTS tax_flags (
+  tx_id INTEGER PRIMARY KEY REFERENCES transactions(id) ON DELETE CASCADE,
+  tax_category TEXT NOT NULL,
+  gst_cents INTEGER, -- NULL when not known
+  receipt TEXT,
+  note TEXT,
+  updated_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now'))
+);
+
+-- files attached to a transaction (receipts, warranties); the file itself is
+-- kept in the attachment store under its sha256
+CREATE TABLE IF NOT EXISTS attachments (
+  id INTEGER PRIMARY KEY,
+  tx_id INTEGER NOT NULL REFERENCES transactions(id) ON DELETE CASCADE,
+  sha256 TEXT NOT NULL,
+  filename TEXT NOT NULL,
+  content_type TEXT NOT NULL,
+  size_bytes INTEGER NOT NULL,
+  created_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now'))
+);
+CREATE INDEX IF NOT EXISTS idx_attachments_tx ON attachments(tx_id);
+
+-- extra terms (household names etc.) masked before anything is sent to an LLM
+CREATE TABLE IF NOT EXISTS redaction_terms (
+  id INTEGER PRIMARY KEY,
+  created_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now')),
+  term TEXT NOT NULL UNIQUE
+);
+
+-- LLM answers per normalised merchant, so repeats never re-query
+CREATE TABLE IF NOT EXISTS llm_cache (
+  merchant_norm TEXT NOT NULL,
+  prompt_version TEXT NOT NULL,
+  category_norm TEXT NOT NULL,
+  reason TEXT,
+  confidence TEXT NOT NULL, -- low|med|high
+  provider TEXT NOT NULL,
+  created_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now')),
+  PRIMARY KEY(merchant_norm, prompt_version)
+);
+
+-- exactly what was sent to an LLM, and what came back
+CREATE TABLE IF NOT EXISTS llm_audit (
+  id INTEGER PRIMARY KEY,
+  created_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now')),
+  provider TEXT NOT NULL,
+  prompt TEXT NOT NULL,
+  reply TEXT,
+  error TEXT
+);
+
 CREATE INDEX IF NOT EXISTS idx_transactions_date ON transactions(txn_date);
 CREATE INDEX IF NOT EXISTS idx_transactions_category ON transactions(category_norm);
 CREATE INDEX IF NOT EXISTS idx_transactions_merchant ON transactions(merchant_norm);
+CREATE INDEX IF NOT EXISTS idx_transactions_category_source ON transactions(category_source, category_set_at);
+CREATE INDEX IF NOT EXISTS idx_suggestions_status ON classification_suggestions(status, tx_id);
+CREATE INDEX IF NOT EXISTS idx_auto_applied_tx ON auto_applied(tx_id);
+CREATE INDEX IF NOT EXISTS idx_anomalies_status ON anomalies(status, kind);
+CREATE INDEX IF NOT EXISTS idx_envelope_entries_month ON envelope_entries(month, category_norm);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_bill_payments_tx ON bill_payments(tx_id) WHERE tx_id IS NOT NULL;
+
+-- full-text search over transactions (/transactions?q=); the index only holds
+-- tokens, the text stays in transactions, and the triggers keep it in step
+CREATE VIRTUAL TABLE IF NOT EXISTS transactions_fts USING fts5(
+  details, merchant_raw, merchant_norm, notes, category_norm, category_raw,
+  content='transactions', content_rowid='id', tokenize='unicode61 remove_diacritics 2'
+);
+CREATE TRIGGER IF NOT EXISTS transactions_fts_insert AFTER INSERT ON transactions BEGIN
+  INSERT INTO transactions_fts(rowid, details, merchant_raw, merchant_norm, notes, category_norm, category_raw)
+  VALUES (new.id, new.details, new.merchant_raw, new.merchant_norm, new.notes, new.category_norm, new.category_raw);
+END;
+CREATE TRIGGER IF NOT EXISTS transactions_fts_delete AFTER DELETE ON transactions BEGIN
+  INSERT INTO transactions_fts(transactions_fts, rowid, details, merchant_raw, merchant_norm, notes, category_norm, category_raw)
+  VALUES ('delete', old.id, old.details, old.merchant_raw, old.merchant_norm, old.notes, old.category_norm, old.category_raw);
+END;
+CREATE TRIGGER IF NOT EXISTS transactions_fts_update AFTER UPDATE OF details, merchant_raw, merchant_norm, notes, category_norm, category_raw ON transactions BEGIN
+  INSERT INTO transactions_fts(transactions_fts, rowid, details, merchant_raw, merchant_norm, notes, category_norm, category_raw)
+  VALUES ('delete', old.id, old.details, old.merchant_raw, old.merchant_norm, old.notes, old.category_norm, old.category_raw);
+  INSERT INTO transactions_fts(rowid, details, merchant_raw, merchant_norm, notes, category_norm, category_raw)
+  VALUES (new.id, new.details, new.merchant_raw, new.merchant_norm, new.notes, new.category_norm, new.category_raw);
+END;
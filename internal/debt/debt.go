@@ -0,0 +1,291 @@
+// Package debt plans paying off several debts (credit cards, loans) from one
+// monthly budget, and tracks the payments actually made against the plan.
+package debt
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/anthurium-ai/personal-finance/internal/db"
+)
+
+// Strategies decide which debt the money left after minimums goes to.
+const (
+	StrategySnowball  = "snowball"  // smallest balance first
+	StrategyAvalanche = "avalanche" // highest rate first
+	StrategyCustom    = "custom"    // by priority
+)
+
+var Strategies = []string{StrategySnowball, StrategyAvalanche, StrategyCustom}
+
+// Debt is a balance owed, as on a statement.
+type Debt struct {
+	ID           int64
+	Name         string
+	BalanceCents int64
+	AsOf         time.Time
+	RatePct      float64 // annual
+	MinimumCents int64   // monthly
+	Priority     int     // custom order, lowest first
+
+	// payments are money into Account (the card or loan account itself, if
+	// imported), or money going out with MatchText in its merchant or details
+	Account   string
+	MatchText string
+}
+
+// Validate checks d before it is stored.
+func (d *Debt) Validate() error {
+	d.Name = strings.TrimSpace(d.Name)
+	d.Account = strings.TrimSpace(d.Account)
+	d.MatchText = strings.TrimSpace(d.MatchText)
+	switch {
+	case d.Name == "":
+		return fmt.Errorf("name is required")
+	case d.BalanceCents < 0:
+		return fmt.Errorf("balance can't be negative")
+	case d.AsOf.IsZero():
+		return fmt.Errorf("balance date is required")
+	case d.RatePct < 0 || d.RatePct > 100:
+		return fmt.Errorf("rate must be 0 to 100%%")
+	case d.MinimumCents < 0:
+		return fmt.Errorf("minimum payment can't be negative")
+	}
+	return nil
+}
+
+// Create stores d and returns its id.
+func Create(ctx context.Context, q db.Querier, d Debt) (int64, error) {
+	if err := d.Validate(); err != nil {
+		return 0, err
+	}
+	res, err := q.ExecContext(ctx, `INSERT INTO debts (name, balance_cents, as_of, rate_pct, minimum_cents, priority, account, match_text) VALUES (?,?,?,?,?,?,?,?)`,
+		d.Name, d.BalanceCents, d.AsOf.Format("2006-01-02"), d.RatePct, d.MinimumCents, d.Priority, d.Account, d.MatchText)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// Update sets a debt's balance from a new statement, and its priority.
+func Update(ctx context.Context, q db.Querier, id, balanceCents int64, asOf time.Time, priority int) error {
+	if balanceCents < 0 {
+		return fmt.Errorf("balance can't be negative")
+	}
+	_, err := q.ExecContext(ctx, `UPDATE debts SET balance_cents=?, as_of=?, priority=? WHERE id=?`, balanceCents, asOf.Format("2006-01-02"), priority, id)
+	return err
+}
+
+func Delete(ctx context.Context, q db.Querier, id int64) error {
+	_, err := q.ExecContext(ctx, `DELETE FROM debts WHERE id=?`, id)
+	return err
+}
+
+// List returns every debt in priority order.
+func List(ctx context.Context, q db.Querier) ([]Debt, error) {
+	rows, err := q.QueryContext(ctx, `SELECT id, name, balance_cents, as_of, rate_pct, minimum_cents, priority, COALESCE(account,''), COALESCE(match_text,'')
+		FROM debts ORDER BY priority, name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Debt
+	for rows.Next() {
+		var d Debt
+		var asOf string
+		if err := rows.Scan(&d.ID, &d.Name, &d.BalanceCents, &asOf, &d.RatePct, &d.MinimumCents, &d.Priority, &d.Account, &d.MatchText); err != nil {
+			return nil, err
+		}
+		d.AsOf, _ = time.Parse("2006-01-02", asOf)
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+// Plan is the chosen budget and strategy, followed from Start.
+type Plan struct {
+	BudgetCents int64 // a month, across every debt
+	Strategy    string
+	Start       time.Time // first of the month
+}
+
+// LoadPlan returns the saved plan; nil if there isn't one.
+func LoadPlan(ctx context.Context, q db.Querier) (*Plan, error) {
+	var p Plan
+	var start string
+	err := q.QueryRowContext(ctx, `SELECT budget_cents, strategy, start_month FROM debt_plan WHERE id=1`).Scan(&p.BudgetCents, &p.Strategy, &start)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	p.Start, _ = time.Parse("2006-01", start)
+	return &p, nil
+}
+
+// SavePlan replaces the plan.
+func SavePlan(ctx context.Context, q db.Querier, p Plan) error {
+	if p.BudgetCents <= 0 {
+		return fmt.Errorf("budget must be more than zero")
+	}
+	known := false
+	for _, s := range Strategies {
+		known = known || s == p.Strategy
+	}
+	if !known {
+		return fmt.Errorf("unknown strategy %q", p.Strategy)
+	}
+	_, err := q.ExecContext(ctx, `INSERT INTO debt_plan (id, budget_cents, strategy, start_month) VALUES (1,?,?,?)
+		ON CONFLICT(id) DO UPDATE SET budget_cents=excluded.budget_cents, strategy=excluded.strategy, start_month=excluded.start_month,
+			updated_at=strftime('%Y-%m-%dT%H:%M:%fZ','now')`,
+		p.BudgetCents, p.Strategy, p.Start.Format("2006-01"))
+	return err
+}
+
+// Order sorts debts the way strategy pays them off; ties go to the
+// smaller balance, then the name.
+func Order(debts []Debt, strategy string) []Debt {
+	out := append([]Debt(nil), debts...)
+	sort.SliceStable(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		switch {
+		case strategy == StrategyAvalanche && a.RatePct != b.RatePct:
+			return a.RatePct > b.RatePct
+		case strategy == StrategyCustom && a.Priority != b.Priority:
+			return a.Priority < b.Priority
+		case a.BalanceCents != b.BalanceCents:
+			return a.BalanceCents < b.BalanceCents
+		}
+		return a.Name < b.Name
+	})
+	return out
+}
+
+// Line is one debt in one month of a schedule.
+type Line struct {
+	InterestCents int64
+	PaymentCents  int64
+	BalanceCents  int64 // at month end
+}
+
+// Month is one month of a schedule; Lines are in the schedule's order.
+type Month struct {
+	Month time.Time
+	Lines []Line
+}
+
+// Schedule is a month-by-month payoff plan.
+type Schedule struct {
+	Strategy      string
+	Debts         []Debt // in payoff order
+	Months        []Month
+	PaidOff       []time.Time // the month each debt is cleared; zero if never
+	InterestCents int64
+	PaidCents     int64
+	DebtFree      time.Time // zero if the budget never clears everything
+}
+
+// maxMonths stops a plan that never pays off.
+const maxMonths = 600
+
+// Simulate pays debts from budget each month from start: interest is added
+// monthly, every debt gets its minimum, and what's left goes to the debts
+// in the strategy's order. A paid-off debt's minimum rolls on to the next.
+func Simulate(debts []Debt, budgetCents int64, strategy string, start time.Time) (*Schedule, error) {
+	var minimums int64
+	for _, d := range debts {
+		if d.BalanceCents > 0 {
+			minimums += d.MinimumCents
+		}
+	}
+	if budgetCents < minimums {
+		return nil, fmt.Errorf("the budget doesn't cover the minimum payments")
+	}
+	s := &Schedule{Strategy: strategy, Debts: Order(debts, strategy)}
+	s.PaidOff = make([]time.Time, len(s.Debts))
+	balances := make([]int64, len(s.Debts))
+	owed := int64(0)
+	for i, d := range s.Debts {
+		balances[i] = d.BalanceCents
+		owed += d.BalanceCents
+	}
+	month := time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, time.UTC)
+	for n := 0; owed > 0 && n < maxMonths; n, month = n+1, month.AddDate(0, 1, 0) {
+		m := Month{Month: month, Lines: make([]Line, len(s.Debts))}
+		left := budgetCents
+		for i, d := range s.Debts {
+			if balances[i] == 0 {
+				continue
+			}
+			l := &m.Lines[i]
+			l.InterestCents = int64(math.Round(float64(balances[i]) * d.RatePct / 100 / 12))
+			balances[i] += l.InterestCents
+			l.PaymentCents = min(d.MinimumCents, balances[i])
+			left -= l.PaymentCents
+		}
+		for i := range s.Debts {
+			extra := min(left, balances[i]-m.Lines[i].PaymentCents)
+			m.Lines[i].PaymentCents += extra
+			left -= extra
+		}
+		owed = 0
+		for i := range s.Debts {
+			l := &m.Lines[i]
+			if balances[i] > 0 && balances[i] == l.PaymentCents {
+				s.PaidOff[i] = month
+			}
+			balances[i] -= l.PaymentCents
+			l.BalanceCents = balances[i]
+			owed += balances[i]
+			s.InterestCents += l.InterestCents
+			s.PaidCents += l.PaymentCents
+		}
+		s.Months = append(s.Months, m)
+	}
+	if owed == 0 && len(s.Months) > 0 {
+		s.DebtFree = s.Months[len(s.Months)-1].Month
+	}
+	return s, nil
+}
+
+// Payments sums what was paid to each debt per month (YYYY-MM) from from
+// up to to, for checking progress against a plan.
+func Payments(ctx context.Context, q db.Querier, debts []Debt, from, to time.Time) (map[int64]map[string]int64, error) {
+	out := map[int64]map[string]int64{}
+	for _, d := range debts {
+		if d.Account == "" && d.MatchText == "" {
+			continue
+		}
+		rows, err := q.QueryContext(ctx, `
+			SELECT substr(txn_date,1,7), SUM(ABS(amount_cents)) FROM transactions
+			WHERE txn_date >= ? AND txn_date < ?
+			  AND ((? != '' AND account = ? AND amount_cents > 0)
+			    OR (? != '' AND amount_cents < 0 AND (UPPER(COALESCE(merchant_norm,'')) LIKE '%' || UPPER(?) || '%' OR UPPER(COALESCE(details,'')) LIKE '%' || UPPER(?) || '%')))
+			GROUP BY 1`,
+			from.Format("2006-01-02"), to.Format("2006-01-02"), d.Account, d.Account, d.MatchText, d.MatchText, d.MatchText)
+		if err != nil {
+			return nil, err
+		}
+		out[d.ID] = map[string]int64{}
+		for rows.Next() {
+			var m string
+			var cents int64
+			if err := rows.Scan(&m, &cents); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			out[d.ID][m] = cents
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,123 @@
+package debt
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSimulate(t *testing.T) {
+	start := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+	month := func(n int) time.Time { return time.Date(2026, time.Month(3+n), 1, 0, 0, 0, 0, time.UTC) }
+	card := Debt{Name: "card", BalanceCents: 300_00, MinimumCents: 50_00, Priority: 2}
+	car := Debt{Name: "car", BalanceCents: 1000_00, MinimumCents: 50_00, Priority: 1}
+	store := Debt{Name: "store", BalanceCents: 2000_00, RatePct: 24, MinimumCents: 60_00}
+	bank := Debt{Name: "bank", BalanceCents: 1000_00, RatePct: 6, MinimumCents: 30_00}
+
+	tests := []struct {
+		name     string
+		debts    []Debt
+		budget   int64
+		strategy string
+		order    []string // payoff order
+		paidOff  []int    // months after start each is cleared, -1 if never; nil to skip
+		debtFree int      // months after start, -1 if never
+		interest int64    // -1 to skip
+	}{
+		{
+			name: "snowball without interest", debts: []Debt{car, card}, budget: 200_00, strategy: StrategySnowball,
+			// card gets the $100 spare on top of its minimum and is gone in
+			// the second month; its minimum then rolls on to the car
+			order: []string{"card", "car"}, paidOff: []int{1, 6}, debtFree: 6, interest: 0,
+		},
+		{
+			name: "custom order", debts: []Debt{card, car}, budget: 200_00, strategy: StrategyCustom,
+			order: []string{"car", "card"}, paidOff: []int{6, 5}, debtFree: 6, interest: 0,
+		},
+		{
+			name: "avalanche pays the highest rate first", debts: []Debt{bank, store}, budget: 500_00, strategy: StrategyAvalanche,
+			order: []string{"store", "bank"}, interest: -1,
+		},
+		{
+			name: "minimums that never catch the interest", debts: []Debt{{Name: "loan", BalanceCents: 10_000_00, RatePct: 24, MinimumCents: 100_00}},
+			budget: 100_00, strategy: StrategyAvalanche,
+			order: []string{"loan"}, paidOff: []int{-1}, debtFree: -1, interest: -1,
+		},
+	}
+	for _, tt := range tests {
+		s, err := Simulate(tt.debts, tt.budget, tt.strategy, start)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		for i, d := range s.Debts {
+			if d.Name != tt.order[i] {
+				t.Errorf("%s: debt %d is %s, want %s", tt.name, i, d.Name, tt.order[i])
+			}
+			if tt.paidOff == nil {
+				continue
+			}
+			switch want := tt.paidOff[i]; {
+			case want == -1 && !s.PaidOff[i].IsZero():
+				t.Errorf("%s: %s paid off %s, want never", tt.name, d.Name, s.PaidOff[i].Format("2006-01"))
+			case want >= 0 && !s.PaidOff[i].Equal(month(want)):
+				t.Errorf("%s: %s paid off %s, want %s", tt.name, d.Name, s.PaidOff[i].Format("2006-01"), month(want).Format("2006-01"))
+			}
+		}
+		switch {
+		case tt.paidOff == nil:
+		case tt.debtFree == -1 && (!s.DebtFree.IsZero() || len(s.Months) != maxMonths):
+			t.Errorf("%s: debt free %s after %d months, want never", tt.name, s.DebtFree.Format("2006-01"), len(s.Months))
+		case tt.debtFree >= 0 && !s.DebtFree.Equal(month(tt.debtFree)):
+			t.Errorf("%s: debt free %s, want %s", tt.name, s.DebtFree.Format("2006-01"), month(tt.debtFree).Format("2006-01"))
+		}
+		if tt.interest >= 0 && s.InterestCents != tt.interest {
+			t.Errorf("%s: interest %d, want %d", tt.name, s.InterestCents, tt.interest)
+		}
+		var owed int64
+		for _, d := range tt.debts {
+			owed += d.BalanceCents
+		}
+		last := s.Months[len(s.Months)-1]
+		var left int64
+		for _, l := range last.Lines {
+			left += l.BalanceCents
+		}
+		if s.PaidCents != owed+s.InterestCents-left {
+			t.Errorf("%s: paid %d, want what was owed plus interest less what's left (%d)", tt.name, s.PaidCents, owed+s.InterestCents-left)
+		}
+	}
+}
+
+func TestSimulateStrategies(t *testing.T) {
+	start := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	debts := []Debt{
+		{Name: "small", BalanceCents: 1000_00, RatePct: 6, MinimumCents: 30_00},
+		{Name: "dear", BalanceCents: 4000_00, RatePct: 24, MinimumCents: 100_00},
+	}
+	snowball, err := Simulate(debts, 500_00, StrategySnowball, start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	avalanche, err := Simulate(debts, 500_00, StrategyAvalanche, start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if avalanche.InterestCents >= snowball.InterestCents {
+		t.Errorf("avalanche interest %d, want less than snowball's %d", avalanche.InterestCents, snowball.InterestCents)
+	}
+	if snowball.PaidOff[0].After(avalanche.PaidOff[1]) {
+		t.Errorf("snowball clears the small debt %s, want no later than avalanche's %s", snowball.PaidOff[0].Format("2006-01"), avalanche.PaidOff[1].Format("2006-01"))
+	}
+}
+
+func TestSimulateBudgetBelowMinimums(t *testing.T) {
+	debts := []Debt{{Name: "a", BalanceCents: 100_00, MinimumCents: 50_00}, {Name: "b", BalanceCents: 100_00, MinimumCents: 50_00}}
+	if _, err := Simulate(debts, 99_99, StrategySnowball, time.Now()); err == nil {
+		t.Error("want an error when the budget doesn't cover the minimums")
+	}
+	// a cleared debt's minimum doesn't count
+	debts[1].BalanceCents = 0
+	if _, err := Simulate(debts, 50_00, StrategySnowball, time.Now()); err != nil {
+		t.Errorf("cleared debt's minimum counted: %v", err)
+	}
+}
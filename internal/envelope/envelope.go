@@ -0,0 +1,244 @@
+// Package envelope is zero-based budgeting over transactions: income goes
+// into "ready to assign", is assigned to category envelopes month by month,
+// and spending in a category draws its envelope down.
+package envelope
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/anthurium-ai/personal-finance/internal/db"
+)
+
+// Kinds stored in envelope_entries.kind.
+const (
+	KindAssign = "assign"
+	KindMove   = "move"
+)
+
+// ReadyToAssign names the unassigned pool in moves.
+const ReadyToAssign = ""
+
+// Envelope is one category in one month.
+type Envelope struct {
+	Category       string
+	CarriedCents   int64 // available left over from last month
+	AssignedCents  int64
+	ActivityCents  int64 // net of the month's transactions; spend is negative
+	AvailableCents int64
+}
+
+func (e *Envelope) Overspent() bool { return e.AvailableCents < 0 }
+
+// Ledger is the envelope budget for one month.
+type Ledger struct {
+	Month string // YYYY-MM
+	Start string // first month of the ledger, YYYY-MM; empty if nothing is assigned yet
+
+	// InflowCents is the net of the month's transactions outside any
+	// envelope: income, and spending that no envelope covers.
+	InflowCents int64
+	// UncoveredCents is last month's overspending, taken from this month's
+	// ready to assign since it was never covered.
+	UncoveredCents     int64
+	AssignedCents      int64
+	ReadyToAssignCents int64
+
+	Envelopes []Envelope
+}
+
+// Month builds the ledger for month (YYYY-MM), replaying every month from
+// the first entry. Envelopes keep what is left at month end; an overspent
+// envelope starts the next month at zero and the overspend comes out of
+// ready to assign instead.
+func Month(ctx context.Context, q db.Querier, month string) (*Ledger, error) {
+	if _, err := time.Parse("2006-01", month); err != nil {
+		return nil, fmt.Errorf("month must be YYYY-MM")
+	}
+	l := &Ledger{Month: month}
+
+	var start sql.NullString
+	if err := q.QueryRowContext(ctx, `SELECT MIN(month) FROM envelope_entries WHERE month <= ?`, month).Scan(&start); err != nil {
+		return nil, err
+	}
+	if !start.Valid {
+		return l, nil
+	}
+	l.Start = start.String
+
+	// month -> category -> cents
+	assigned, err := sums(ctx, q, `SELECT month, category_norm, SUM(amount_cents) FROM envelope_entries
+		WHERE month <= ? GROUP BY 1, 2`, month)
+	if err != nil {
+		return nil, err
+	}
+	first, _ := time.Parse("2006-01", l.Start)
+	last, _ := time.Parse("2006-01", month)
+	activity, err := sums(ctx, q, `SELECT substr(txn_date,1,7), `+db.CategorySQL("")+`, SUM(amount_cents) FROM transactions
+		WHERE txn_date >= ? AND txn_date < ? GROUP BY 1, 2`, first.Format("2006-01-02"), last.AddDate(0, 1, 0).Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+
+	envelopes := map[string]bool{}
+	for _, cats := range assigned {
+		for c := range cats {
+			envelopes[c] = true
+		}
+	}
+	cats := make([]string, 0, len(envelopes))
+	for c := range envelopes {
+		cats = append(cats, c)
+	}
+	sort.Strings(cats)
+
+	available := map[string]int64{}
+	var ready, uncovered int64
+	for m := first; !m.After(last); m = m.AddDate(0, 1, 0) {
+		key := m.Format("2006-01")
+		var inflow, assignedTotal int64
+		for c, v := range activity[key] {
+			if !envelopes[c] {
+				inflow += v
+			}
+		}
+		env := make([]Envelope, 0, len(cats))
+		for _, c := range cats {
+			e := Envelope{Category: c, CarriedCents: max(available[c], 0), AssignedCents: assigned[key][c], ActivityCents: activity[key][c]}
+			e.AvailableCents = e.CarriedCents + e.AssignedCents + e.ActivityCents
+			available[c] = e.AvailableCents
+			assignedTotal += e.AssignedCents
+			env = append(env, e)
+		}
+		ready += inflow - assignedTotal - uncovered
+		if key == month {
+			l.InflowCents = inflow
+			l.UncoveredCents = uncovered
+			l.AssignedCents = assignedTotal
+			l.ReadyToAssignCents = ready
+			l.Envelopes = env
+			break
+		}
+		uncovered = 0
+		for _, e := range env {
+			if e.AvailableCents < 0 {
+				uncovered -= e.AvailableCents
+			}
+		}
+	}
+	return l, nil
+}
+
+// sums reads (month, category, cents) rows into a nested map.
+func sums(ctx context.Context, q db.Querier, query string, args ...any) (map[string]map[string]int64, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := map[string]map[string]int64{}
+	for rows.Next() {
+		var m, c string
+		var v int64
+		if err := rows.Scan(&m, &c, &v); err != nil {
+			return nil, err
+		}
+		if out[m] == nil {
+			out[m] = map[string]int64{}
+		}
+		out[m][c] += v
+	}
+	return out, rows.Err()
+}
+
+// Assign sets the amount assigned to category in month, recording the
+// difference from what was assigned before. It returns the difference.
+func Assign(ctx context.Context, q db.Querier, month, category string, cents int64) (int64, error) {
+	category = strings.TrimSpace(category)
+	if category == "" {
+		return 0, fmt.Errorf("category is required")
+	}
+	if _, err := time.Parse("2006-01", month); err != nil {
+		return 0, fmt.Errorf("month must be YYYY-MM")
+	}
+	var cur int64
+	if err := q.QueryRowContext(ctx, `SELECT COALESCE(SUM(amount_cents),0) FROM envelope_entries WHERE month=? AND category_norm=?`,
+		month, category).Scan(&cur); err != nil {
+		return 0, err
+	}
+	delta := cents - cur
+	if delta == 0 {
+		return 0, nil
+	}
+	_, err := q.ExecContext(ctx, `INSERT INTO envelope_entries (month, category_norm, amount_cents, kind) VALUES (?,?,?,?)`,
+		month, category, delta, KindAssign)
+	return delta, err
+}
+
+// Move takes cents from one envelope and gives it to another in month,
+// typically to cover overspending. Either side may be ReadyToAssign.
+func Move(ctx context.Context, d *sql.DB, month, from, to string, cents int64, note string) error {
+	from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+	if from == to {
+		return fmt.Errorf("pick two different envelopes")
+	}
+	if cents <= 0 {
+		return fmt.Errorf("amount must be more than zero")
+	}
+	if _, err := time.Parse("2006-01", month); err != nil {
+		return fmt.Errorf("month must be YYYY-MM")
+	}
+	tx, err := d.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, e := range []struct {
+		cat, other string
+		cents      int64
+	}{{from, to, -cents}, {to, from, cents}} {
+		if e.cat == ReadyToAssign {
+			continue // the pool is whatever isn't assigned
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO envelope_entries (month, category_norm, amount_cents, kind, counterpart, note) VALUES (?,?,?,?,?,?)`,
+			month, e.cat, e.cents, KindMove, e.other, note); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Entry is one line of the ledger.
+type Entry struct {
+	ID          int64
+	CreatedAt   string
+	Category    string
+	AmountCents int64
+	Kind        string
+	Counterpart string // the other side of a move; empty for ready to assign
+	Note        string
+}
+
+// Entries lists month's assignments and moves, newest first.
+func Entries(ctx context.Context, q db.Querier, month string) ([]Entry, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT id, created_at, category_norm, amount_cents, kind, COALESCE(counterpart,''), COALESCE(note,'')
+		FROM envelope_entries WHERE month=? ORDER BY id DESC`, month)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Entry
+	for rows.Next() {
+		var e Entry
+		if err := rows.Scan(&e.ID, &e.CreatedAt, &e.Category, &e.AmountCents, &e.Kind, &e.Counterpart, &e.Note); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
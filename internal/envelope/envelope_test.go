@@ -0,0 +1,119 @@
+package envelope
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/anthurium-ai/personal-finance/internal/db"
+)
+
+func TestMonth(t *testing.T) {
+	ctx := context.Background()
+	d, err := db.Open(filepath.Join(t.TempDir(), "pf.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if err := db.Migrate(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+	for _, tx := range []struct {
+		date, cat string
+		cents     int64
+	}{
+		{"2026-01-01", "Salary", 300000},
+		{"2026-01-05", "Groceries", -40000},
+		{"2026-01-06", "Dining", -30000}, // 10000 over
+		{"2026-01-07", "Fuel", -5000},    // no envelope
+		{"2026-02-01", "Salary", 300000},
+		{"2026-02-05", "Groceries", -55000},
+	} {
+		if _, err := d.Exec(`INSERT INTO transactions (txn_date, amount_cents, category_norm, row_hash) VALUES (?,?,?,hex(randomblob(8)))`,
+			tx.date, tx.cents, tx.cat); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	assign := func(month, cat string, cents, wantDelta int64) {
+		t.Helper()
+		delta, err := Assign(ctx, d, month, cat, cents)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if delta != wantDelta {
+			t.Errorf("Assign(%s, %s, %d) = %d, want %d", month, cat, cents, delta, wantDelta)
+		}
+	}
+	assign("2026-01", "Groceries", 50000, 50000)
+	assign("2026-01", "Dining", 20000, 20000)
+	assign("2026-02", "Groceries", 60000, 60000)
+	assign("2026-02", "Groceries", 50000, -10000)
+	assign("2026-02", "Groceries", 50000, 0)
+	if err := Move(ctx, d, "2026-02", ReadyToAssign, "Groceries", 5000, "top up"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Move(ctx, d, "2026-02", "Groceries", "Dining", 2000, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	jan, err := Month(ctx, d, "2026-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantJan := &Ledger{Month: "2026-01", Start: "2026-01", InflowCents: 295000, AssignedCents: 70000, ReadyToAssignCents: 225000,
+		Envelopes: []Envelope{
+			{Category: "Dining", AssignedCents: 20000, ActivityCents: -30000, AvailableCents: -10000},
+			{Category: "Groceries", AssignedCents: 50000, ActivityCents: -40000, AvailableCents: 10000},
+		}}
+	if !reflect.DeepEqual(jan, wantJan) {
+		t.Errorf("January = %+v\nwant %+v", jan, wantJan)
+	}
+	if !jan.Envelopes[0].Overspent() || jan.Envelopes[1].Overspent() {
+		t.Errorf("January overspent: Dining %v Groceries %v", jan.Envelopes[0].Overspent(), jan.Envelopes[1].Overspent())
+	}
+
+	// Dining's overspend comes out of February's ready to assign, and the
+	// envelope starts again at zero
+	feb, err := Month(ctx, d, "2026-02")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantFeb := &Ledger{Month: "2026-02", Start: "2026-01", InflowCents: 300000, UncoveredCents: 10000, AssignedCents: 55000, ReadyToAssignCents: 460000,
+		Envelopes: []Envelope{
+			{Category: "Dining", AssignedCents: 2000, AvailableCents: 2000},
+			{Category: "Groceries", CarriedCents: 10000, AssignedCents: 53000, ActivityCents: -55000, AvailableCents: 8000},
+		}}
+	if !reflect.DeepEqual(feb, wantFeb) {
+		t.Errorf("February = %+v\nwant %+v", feb, wantFeb)
+	}
+
+	entries, err := Entries(ctx, d, "2026-02")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 5 || entries[0].Category != "Dining" || entries[0].Counterpart != "Groceries" || entries[0].Kind != KindMove {
+		t.Errorf("February entries = %+v", entries)
+	}
+
+	before, err := Month(ctx, d, "2025-12")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if before.Start != "" || len(before.Envelopes) != 0 {
+		t.Errorf("month before any entries = %+v", before)
+	}
+
+	for _, err := range []error{
+		Move(ctx, d, "2026-02", "Dining", "Dining", 100, ""),
+		Move(ctx, d, "2026-02", "Dining", "Groceries", 0, ""),
+		Move(ctx, d, "Feb", "Dining", "Groceries", 100, ""),
+		func() error { _, err := Assign(ctx, d, "2026-02", " ", 100); return err }(),
+		func() error { _, err := Month(ctx, d, "2026-2"); return err }(),
+	} {
+		if err == nil {
+			t.Error("want an error")
+		}
+	}
+}
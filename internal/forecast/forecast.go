@@ -0,0 +1,380 @@
+// Package forecast projects account balances forward from what is known to
+// be coming: scheduled bills, recurring income and expenses, and what is
+// left to spend in budgets.
+package forecast
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/anthurium-ai/personal-finance/internal/bills"
+	"github.com/anthurium-ai/personal-finance/internal/budget"
+	"github.com/anthurium-ai/personal-finance/internal/db"
+	"github.com/anthurium-ai/personal-finance/internal/recurring"
+)
+
+// Sources of forecast events.
+const (
+	SourceBill      = "bill"
+	SourceRecurring = "recurring"
+	SourceBudget    = "budget"
+)
+
+// Event is one expected movement of money.
+type Event struct {
+	Date        time.Time
+	Account     string
+	AmountCents int64 // signed: income positive
+	Source      string
+	Label       string
+	Category    string
+}
+
+// Account is a forecast account: a known balance on a date, and the floor it
+// shouldn't go below.
+type Account struct {
+	Account      string
+	BalanceCents int64 // at the end of AsOf
+	AsOf         time.Time
+	FloorCents   int64
+}
+
+// ListAccounts returns the accounts with a balance set.
+func ListAccounts(ctx context.Context, q db.Querier) ([]Account, error) {
+	rows, err := q.QueryContext(ctx, `SELECT account, balance_cents, as_of, floor_cents FROM account_balances ORDER BY account`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Account
+	for rows.Next() {
+		var a Account
+		var asOf string
+		if err := rows.Scan(&a.Account, &a.BalanceCents, &asOf, &a.FloorCents); err != nil {
+			return nil, err
+		}
+		a.AsOf, _ = time.Parse("2006-01-02", asOf)
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+// SetAccount stores an account's balance and floor.
+func SetAccount(ctx context.Context, q db.Querier, a Account) error {
+	a.Account = strings.TrimSpace(a.Account)
+	if a.Account == "" {
+		return fmt.Errorf("account is required")
+	}
+	if a.AsOf.IsZero() {
+		return fmt.Errorf("balance date is required")
+	}
+	_, err := q.ExecContext(ctx, `INSERT INTO account_balances (account, balance_cents, as_of, floor_cents) VALUES (?,?,?,?)
+		ON CONFLICT(account) DO UPDATE SET balance_cents=excluded.balance_cents, as_of=excluded.as_of, floor_cents=excluded.floor_cents,
+			updated_at=strftime('%Y-%m-%dT%H:%M:%fZ','now')`, a.Account, a.BalanceCents, a.AsOf.Format("2006-01-02"), a.FloorCents)
+	return err
+}
+
+func DeleteAccount(ctx context.Context, q db.Querier, account string) error {
+	_, err := q.ExecContext(ctx, `DELETE FROM account_balances WHERE account=?`, account)
+	return err
+}
+
+// source produces the events expected in [from, to). It sees the events
+// found by the sources before it, so budgets can leave out what recurring
+// items already cover.
+type source func(ctx context.Context, q db.Querier, from, to time.Time, before []Event) ([]Event, error)
+
+var sources = []source{
+	billEvents,
+	recurringEvents,
+	budgetEvents,
+}
+
+// Day is one day of the forecast.
+type Day struct {
+	Date     time.Time
+	Balances []int64 // in Forecast.Accounts order
+	Events   []Event
+}
+
+// Warning is the first day an account is projected below its floor.
+type Warning struct {
+	Account      string
+	Date         time.Time
+	BalanceCents int64
+	FloorCents   int64
+}
+
+type Forecast struct {
+	From     time.Time
+	Accounts []Account // balances brought forward to today, so far
+	Days     []Day
+	Warnings []Warning
+	// Unplaced are events for accounts without a balance set.
+	Unplaced []Event
+}
+
+// Lowest returns the lowest projected balance of account i and its date.
+func (f *Forecast) Lowest(i int) (int64, time.Time) {
+	low, at := f.Accounts[i].BalanceCents, f.From
+	for _, d := range f.Days {
+		if d.Balances[i] < low {
+			low, at = d.Balances[i], d.Date
+		}
+	}
+	return low, at
+}
+
+// Build forecasts balances for days days from today (inclusive).
+func Build(ctx context.Context, q db.Querier, today time.Time, days int) (*Forecast, error) {
+	from := time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, days)
+	f := &Forecast{From: from}
+
+	accounts, err := ListAccounts(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	index := map[string]int{}
+	for _, a := range accounts {
+		// bring the balance forward with the transactions since, up to and
+		// including today's; the forecast is what's still to come
+		var moved int64
+		if err := q.QueryRowContext(ctx, `SELECT COALESCE(SUM(amount_cents),0) FROM transactions WHERE account=? AND txn_date > ? AND txn_date <= ?`,
+			a.Account, a.AsOf.Format("2006-01-02"), from.Format("2006-01-02")).Scan(&moved); err != nil {
+			return nil, err
+		}
+		a.BalanceCents += moved
+		index[a.Account] = len(f.Accounts)
+		f.Accounts = append(f.Accounts, a)
+	}
+
+	var events []Event
+	for _, src := range sources {
+		evs, err := src(ctx, q, from, to, events)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, evs...)
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Date.Before(events[j].Date) })
+
+	byDay := map[time.Time][]Event{}
+	for _, e := range events {
+		if _, ok := index[e.Account]; !ok {
+			f.Unplaced = append(f.Unplaced, e)
+			continue
+		}
+		byDay[e.Date] = append(byDay[e.Date], e)
+	}
+
+	bal := make([]int64, len(f.Accounts))
+	for i, a := range f.Accounts {
+		bal[i] = a.BalanceCents
+	}
+	warned := make([]bool, len(f.Accounts))
+	for d := from; d.Before(to); d = d.AddDate(0, 0, 1) {
+		day := Day{Date: d, Events: byDay[d]}
+		for _, e := range day.Events {
+			bal[index[e.Account]] += e.AmountCents
+		}
+		day.Balances = append([]int64(nil), bal...)
+		for i, a := range f.Accounts {
+			if !warned[i] && bal[i] < a.FloorCents {
+				warned[i] = true
+				f.Warnings = append(f.Warnings, Warning{Account: a.Account, Date: d, BalanceCents: bal[i], FloorCents: a.FloorCents})
+			}
+		}
+		f.Days = append(f.Days, day)
+	}
+	return f, nil
+}
+
+// billLookback is how long an unpaid bill is still expected to be paid.
+const billLookback = 30
+
+// billEvents places each unpaid occurrence of a scheduled bill on its due
+// date, or today if it is already due. A bill without an account is paid
+// from the busiest account.
+func billEvents(ctx context.Context, q db.Querier, from, to time.Time, _ []Event) ([]Event, error) {
+	occ, err := bills.Calendar(ctx, q, from.AddDate(0, 0, -billLookback), to, from)
+	if err != nil {
+		return nil, err
+	}
+	accounts, err := categoryAccounts(ctx, q, from)
+	if err != nil {
+		return nil, err
+	}
+	var out []Event
+	for _, o := range occ {
+		if o.Status == bills.StatusPaid {
+			continue
+		}
+		at := o.Due
+		if at.Before(from) {
+			at = from
+		}
+		acct := o.Bill.Account
+		if acct == "" {
+			acct = accounts[""]
+		}
+		out = append(out, Event{Date: at, Account: acct, AmountCents: o.Bill.AmountCents, Source: SourceBill,
+			Label: o.Bill.Name, Category: o.Bill.Category})
+	}
+	return out, nil
+}
+
+// recurringEvents repeats each live recurring series at its cadence. A
+// charge that is due but not yet seen (still within its grace period) is
+// expected today. Series that pay a scheduled bill are left to billEvents.
+func recurringEvents(ctx context.Context, q db.Querier, from, to time.Time, _ []Event) ([]Event, error) {
+	spend, err := recurring.Detect(ctx, q, from)
+	if err != nil {
+		return nil, err
+	}
+	income, err := recurring.DetectIncome(ctx, q, from)
+	if err != nil {
+		return nil, err
+	}
+	billed, err := bills.MatchedTxIDs(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	var out []Event
+series:
+	for _, s := range append(spend, income...) {
+		if s.Ended || s.Missed {
+			continue
+		}
+		for _, c := range s.Charges {
+			if billed[c.TxID] {
+				continue series
+			}
+		}
+		amount := -s.TypicalCents
+		if s.Income {
+			amount = s.TypicalCents
+		}
+		for d := s.NextExpected; d.Before(to); d = s.Cadence.Next(d) {
+			at := d
+			if at.Before(from) {
+				at = from
+			}
+			out = append(out, Event{Date: at, Account: s.Account, AmountCents: amount, Source: SourceRecurring,
+				Label: s.Merchant + " (" + s.Cadence.Name + ")", Category: s.Category})
+		}
+	}
+	return out, nil
+}
+
+// budgetEvents spreads what is left of each budget evenly over the rest of
+// its period, and whole budgets over the periods after, less whatever
+// earlier sources already expect in the category. The money goes out of
+// the account the category was mostly paid from over the last 90 days.
+func budgetEvents(ctx context.Context, q db.Querier, from, to time.Time, before []Event) ([]Event, error) {
+	accounts, err := categoryAccounts(ctx, q, from)
+	if err != nil {
+		return nil, err
+	}
+	var out []Event
+	covered := map[string]time.Time{} // category -> end of the last period spread
+	for m := from; m.Before(to); m = time.Date(m.Year(), m.Month()+1, 1, 0, 0, 0, 0, time.UTC) {
+		status, err := budget.Track(ctx, q, m)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range status {
+			if !s.To.After(covered[s.Category]) {
+				continue
+			}
+			covered[s.Category] = s.To
+			start, end := s.From, s.To
+			if start.Before(from) {
+				start = from
+			}
+			left := s.RemainingCents
+			if end.After(to) {
+				// only the part of the period inside the forecast
+				left = left * int64(to.Sub(start).Hours()/24) / int64(end.Sub(start).Hours()/24)
+				end = to
+			}
+			for _, e := range before {
+				if e.Category == s.Category && e.AmountCents < 0 && !e.Date.Before(start) && e.Date.Before(end) {
+					left += e.AmountCents
+				}
+			}
+			if left <= 0 {
+				continue
+			}
+			acct := accounts[s.Category]
+			if acct == "" {
+				acct = accounts[""]
+			}
+			n := int64(end.Sub(start).Hours() / 24)
+			for i := int64(0); i < n; i++ {
+				share := left / n
+				if i == n-1 {
+					share = left - share*(n-1)
+				}
+				out = append(out, Event{Date: start.AddDate(0, 0, int(i)), Account: acct, AmountCents: -share, Source: SourceBudget,
+					Label: s.Category + " budget", Category: s.Category})
+			}
+		}
+	}
+	return out, nil
+}
+
+// categoryAccounts maps each category to the account most of its spend came
+// from in the 90 days before asOf; "" maps to the busiest account overall.
+func categoryAccounts(ctx context.Context, q db.Querier, asOf time.Time) (map[string]string, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT `+db.CategorySQL("")+`, account, SUM(-amount_cents) AS spend
+		FROM transactions
+		WHERE amount_cents < 0 AND COALESCE(account,'') != '' AND txn_date >= ?
+		GROUP BY 1, 2
+		ORDER BY spend DESC`, asOf.AddDate(0, 0, -90).Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := map[string]string{}
+	total := map[string]int64{}
+	for rows.Next() {
+		var cat, acct string
+		var spend int64
+		if err := rows.Scan(&cat, &acct, &spend); err != nil {
+			return nil, err
+		}
+		if _, ok := out[cat]; !ok {
+			out[cat] = acct
+		}
+		total[acct] += spend
+	}
+	for acct, spend := range total {
+		if cur, ok := out[""]; !ok || spend > total[cur] || spend == total[cur] && acct < cur {
+			out[""] = acct
+		}
+	}
+	return out, rows.Err()
+}
+
+// KnownAccounts lists the accounts seen in transactions.
+func KnownAccounts(ctx context.Context, q db.Querier) ([]string, error) {
+	rows, err := q.QueryContext(ctx, `SELECT DISTINCT account FROM transactions WHERE COALESCE(account,'') != '' ORDER BY account`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var a string
+		if err := rows.Scan(&a); err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,143 @@
+package forecast
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/anthurium-ai/personal-finance/internal/bills"
+	"github.com/anthurium-ai/personal-finance/internal/budget"
+	"github.com/anthurium-ai/personal-finance/internal/db"
+)
+
+func date(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestBuild(t *testing.T) {
+	ctx := context.Background()
+	d, err := db.Open(filepath.Join(t.TempDir(), "pf.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if err := db.Migrate(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+	today := date("2026-04-10")
+
+	for _, tx := range []struct {
+		date, account, merchant, cat string
+		cents                        int64
+	}{
+		{"2026-01-15", "everyday", "EMPLOYER", "Income", 300000},
+		{"2026-02-15", "everyday", "EMPLOYER", "Income", 300000},
+		{"2026-03-15", "everyday", "EMPLOYER", "Income", 300000},
+		{"2026-01-20", "everyday", "NETFLIX", "Streaming", -2000},
+		{"2026-02-20", "everyday", "NETFLIX", "Streaming", -2000},
+		{"2026-03-20", "everyday", "NETFLIX", "Streaming", -2000},
+		{"2026-01-12", "everyday", "RENT", "Housing", -150000}, // paid the bill, so not recurring
+		{"2026-02-12", "everyday", "RENT", "Housing", -150000},
+		{"2026-03-12", "everyday", "RENT", "Housing", -150000},
+		{"2026-04-08", "everyday", "COLES", "Groceries", -20000}, // after the balance date
+		{"2026-04-01", "credit", "CAFE 21", "Dining", -3000},
+	} {
+		if _, err := d.Exec(`INSERT INTO transactions (txn_date, account, merchant_norm, category_norm, amount_cents, row_hash) VALUES (?,?,?,?,?,hex(randomblob(8)))`,
+			tx.date, tx.account, tx.merchant, tx.cat, tx.cents); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, b := range []bills.Bill{
+		{Name: "Rent", AmountCents: -150000, Account: "everyday", Category: "Housing", Rule: bills.RuleMonthly, Start: date("2026-01-12")},
+		{Name: "Insurance", AmountCents: -8000, Category: "Insurance", Rule: bills.RuleMonthly, Start: date("2026-04-01")}, // overdue
+	} {
+		if _, err := bills.Create(ctx, d, b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n, err := bills.Match(ctx, d, today); err != nil || n != 3 {
+		t.Fatalf("matched %d rent payments (%v), want 3", n, err)
+	}
+	for _, b := range []budget.Budget{
+		{Category: "Groceries", Period: "monthly", AmountCents: 60000, StartMonth: "2026-04"},
+		{Category: "Streaming", Period: "monthly", AmountCents: 5000, StartMonth: "2026-04"},
+		{Category: "Dining", Period: "monthly", AmountCents: 10000, StartMonth: "2026-04"},
+	} {
+		if err := budget.Set(ctx, d, b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := SetAccount(ctx, d, Account{Account: "everyday", BalanceCents: 100000, AsOf: date("2026-04-05"), FloorCents: 20000}); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetAccount(ctx, d, Account{Account: " "}); err == nil {
+		t.Error("SetAccount without an account: want an error")
+	}
+
+	f, err := Build(ctx, d, today, 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Accounts) != 1 || f.Accounts[0].BalanceCents != 80000 {
+		t.Fatalf("accounts = %+v, want everyday brought forward to 80000", f.Accounts)
+	}
+	if len(f.Days) != 30 || !f.Days[0].Date.Equal(today) {
+		t.Fatalf("%d days from %v, want 30 from today", len(f.Days), f.Days[0].Date)
+	}
+
+	type key struct{ source, label string }
+	got := map[key]int64{}
+	var gotDates []string
+	for _, day := range f.Days {
+		for _, e := range day.Events {
+			got[key{e.Source, e.Label}] += e.AmountCents
+			if e.Source != SourceBudget {
+				gotDates = append(gotDates, e.Date.Format("01-02")+" "+e.Label)
+			}
+		}
+	}
+	for k, want := range map[key]int64{
+		{SourceBill, "Rent"}:                    -150000,
+		{SourceBill, "Insurance"}:               -16000, // April's, due today, and May's
+		{SourceRecurring, "EMPLOYER (monthly)"}: 300000,
+		{SourceRecurring, "NETFLIX (monthly)"}:  -2000,
+		{SourceBudget, "Groceries budget"}:      -40000 - 60000*9/31,
+		{SourceBudget, "Streaming budget"}:      -3000 - 5000*9/31, // less Netflix
+		{SourceRecurring, "RENT (monthly)"}:     0,
+	} {
+		if got[k] != want {
+			t.Errorf("%s %q = %d, want %d", k.source, k.label, got[k], want)
+		}
+	}
+	wantDates := []string{"04-10 Insurance", "04-12 Rent", "04-15 EMPLOYER (monthly)", "04-20 NETFLIX (monthly)", "05-01 Insurance"}
+	if !reflect.DeepEqual(gotDates, wantDates) {
+		t.Errorf("events = %v, want %v", gotDates, wantDates)
+	}
+
+	last := f.Days[len(f.Days)-1].Balances[0]
+	if want := int64(80000 + 300000 - 150000 - 16000 - 2000 - 40000 - 60000*9/31 - 3000 - 5000*9/31); last != want {
+		t.Errorf("closing balance = %d, want %d", last, want)
+	}
+	if len(f.Warnings) != 1 || f.Warnings[0].Account != "everyday" || !f.Warnings[0].Date.Equal(date("2026-04-12")) {
+		t.Errorf("warnings = %+v, want everyday below its floor on 04-12", f.Warnings)
+	}
+	if low, at := f.Lowest(0); low >= f.Warnings[0].BalanceCents || !at.Equal(date("2026-04-14")) {
+		t.Errorf("Lowest = %d on %v, want the day before pay", low, at)
+	}
+
+	// Dining is paid from the credit card, which has no balance set
+	if len(f.Unplaced) == 0 {
+		t.Error("want the Dining budget unplaced")
+	}
+	for _, e := range f.Unplaced {
+		if e.Account != "credit" || e.Category != "Dining" {
+			t.Errorf("unplaced %+v, want only Dining on credit", e)
+		}
+	}
+}
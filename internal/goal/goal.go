@@ -0,0 +1,186 @@
+// Package goal tracks savings goals funded by matching transactions.
+package goal
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/anthurium-ai/personal-finance/internal/db"
+)
+
+// Goal is a savings target. Contributions are the transactions on Account
+// (deposits add, withdrawals take away), or those tagged #Tag in their
+// notes (money going out to the goal adds), from StartDate on.
+type Goal struct {
+	ID           int64
+	Name         string
+	TargetCents  int64
+	TargetDate   time.Time
+	Account      string
+	Tag          string // without the '#'
+	OpeningCents int64  // already saved before StartDate
+	StartDate    time.Time
+}
+
+// Validate checks g before it is stored, and tidies the tag.
+func (g *Goal) Validate() error {
+	g.Name = strings.TrimSpace(g.Name)
+	g.Account = strings.TrimSpace(g.Account)
+	g.Tag = strings.TrimPrefix(strings.TrimSpace(g.Tag), "#")
+	switch {
+	case g.Name == "":
+		return fmt.Errorf("name is required")
+	case g.TargetCents <= 0:
+		return fmt.Errorf("target must be more than zero")
+	case g.TargetDate.IsZero():
+		return fmt.Errorf("target date is required")
+	case (g.Account == "") == (g.Tag == ""):
+		return fmt.Errorf("link the goal to either an account or a tag")
+	case strings.ContainsAny(g.Tag, " \t#%_,"):
+		return fmt.Errorf("tag must be one word")
+	}
+	return nil
+}
+
+// Create stores g and returns its id.
+func Create(ctx context.Context, q db.Querier, g Goal) (int64, error) {
+	if err := g.Validate(); err != nil {
+		return 0, err
+	}
+	if g.StartDate.IsZero() {
+		g.StartDate = time.Now()
+	}
+	res, err := q.ExecContext(ctx, `INSERT INTO goals (name, target_cents, target_date, account, tag, opening_cents, start_date) VALUES (?,?,?,?,?,?,?)`,
+		g.Name, g.TargetCents, g.TargetDate.Format("2006-01-02"), g.Account, g.Tag, g.OpeningCents, g.StartDate.Format("2006-01-02"))
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+func Delete(ctx context.Context, q db.Querier, id int64) error {
+	_, err := q.ExecContext(ctx, `DELETE FROM goals WHERE id=?`, id)
+	return err
+}
+
+// List returns every goal, soonest target first.
+func List(ctx context.Context, q db.Querier) ([]Goal, error) {
+	rows, err := q.QueryContext(ctx, `SELECT id, name, target_cents, target_date, COALESCE(account,''), COALESCE(tag,''), opening_cents, start_date
+		FROM goals ORDER BY target_date, name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Goal
+	for rows.Next() {
+		var g Goal
+		var target, start string
+		if err := rows.Scan(&g.ID, &g.Name, &g.TargetCents, &target, &g.Account, &g.Tag, &g.OpeningCents, &start); err != nil {
+			return nil, err
+		}
+		g.TargetDate, _ = time.Parse("2006-01-02", target)
+		g.StartDate, _ = time.Parse("2006-01-02", start)
+		out = append(out, g)
+	}
+	return out, rows.Err()
+}
+
+// paceDays is how far back the current pace looks.
+const paceDays = 90
+
+// daysPerMonth turns day counts into months.
+const daysPerMonth = 365.25 / 12
+
+// Progress is where a goal stands on a given day.
+type Progress struct {
+	Goal
+	SavedCents     int64
+	RemainingCents int64
+	Contributions  int // matching transactions
+
+	// RequiredMonthlyCents is what still has to go in each month to reach
+	// the target on the date; the whole remainder once the date has passed.
+	RequiredMonthlyCents int64
+	// PaceMonthlyCents is the average monthly contribution over the last
+	// 90 days (or since the start, if sooner).
+	PaceMonthlyCents int64
+	// Projected is when the target is reached at that pace; zero if never.
+	Projected time.Time
+}
+
+func (p *Progress) Ratio() float64 { return float64(p.SavedCents) / float64(p.TargetCents) }
+func (p *Progress) Done() bool     { return p.RemainingCents == 0 }
+
+// OnTrack reports whether the current pace reaches the target by its date.
+func (p *Progress) OnTrack() bool {
+	return p.Done() || !p.Projected.IsZero() && !p.Projected.After(p.TargetDate)
+}
+
+// match is the SQL condition and argument selecting g's transactions, and
+// the sign that makes them count towards it: deposits on the account, or
+// money going out in transactions tagged as a whole word in the notes.
+func (g *Goal) match() (cond string, arg any, sign int64) {
+	if g.Account != "" {
+		return `account = ?`, g.Account, 1
+	}
+	return `(' ' || REPLACE(REPLACE(COALESCE(notes,''), char(10), ' '), ',', ' ') || ' ') LIKE ?`, "% #" + g.Tag + " %", -1
+}
+
+// Track works out every goal's progress as of asOf.
+func Track(ctx context.Context, q db.Querier, asOf time.Time) ([]Progress, error) {
+	goals, err := List(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	day := time.Date(asOf.Year(), asOf.Month(), asOf.Day(), 0, 0, 0, 0, time.UTC)
+	out := make([]Progress, 0, len(goals))
+	for _, g := range goals {
+		p := Progress{Goal: g}
+		cond, arg, sign := g.match()
+		sum := func(from time.Time) (int64, int, error) {
+			var cents int64
+			var n int
+			err := q.QueryRowContext(ctx, `SELECT COALESCE(SUM(amount_cents),0), COUNT(*) FROM transactions
+				WHERE txn_date >= ? AND txn_date <= ? AND `+cond, from.Format("2006-01-02"), day.Format("2006-01-02"), arg).Scan(&cents, &n)
+			return sign * cents, n, err
+		}
+		contributed, n, err := sum(g.StartDate)
+		if err != nil {
+			return nil, err
+		}
+		p.Contributions = n
+		p.SavedCents = g.OpeningCents + contributed
+		p.RemainingCents = max(g.TargetCents-p.SavedCents, 0)
+
+		monthsLeft := g.TargetDate.Sub(day).Hours() / 24 / daysPerMonth
+		if monthsLeft < 1 {
+			p.RequiredMonthlyCents = p.RemainingCents
+		} else {
+			p.RequiredMonthlyCents = int64(math.Ceil(float64(p.RemainingCents) / monthsLeft))
+		}
+
+		from := day.AddDate(0, 0, -paceDays)
+		if g.StartDate.After(from) {
+			from = g.StartDate
+		}
+		recent, _, err := sum(from)
+		if err != nil {
+			return nil, err
+		}
+		// a goal started last week hasn't shown a pace yet
+		days := math.Max(day.Sub(from).Hours()/24+1, daysPerMonth)
+		p.PaceMonthlyCents = int64(float64(recent) / days * daysPerMonth)
+		switch {
+		case p.Done():
+			p.Projected = day
+		case p.PaceMonthlyCents > 0:
+			months := float64(p.RemainingCents) / float64(p.PaceMonthlyCents)
+			p.Projected = day.AddDate(0, 0, int(math.Ceil(months*daysPerMonth)))
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
@@ -0,0 +1,129 @@
+package goal
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/anthurium-ai/personal-finance/internal/db"
+)
+
+func date(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		g       Goal
+		wantErr bool
+		wantTag string
+	}{
+		{Goal{Name: "Car", TargetCents: 100, TargetDate: date("2027-01-01"), Tag: " #car "}, false, "car"},
+		{Goal{Name: "Car", TargetCents: 100, TargetDate: date("2027-01-01"), Account: "savings"}, false, ""},
+		{Goal{Name: " ", TargetCents: 100, TargetDate: date("2027-01-01"), Tag: "car"}, true, ""},
+		{Goal{Name: "Car", TargetDate: date("2027-01-01"), Tag: "car"}, true, ""},
+		{Goal{Name: "Car", TargetCents: 100, Tag: "car"}, true, ""},
+		{Goal{Name: "Car", TargetCents: 100, TargetDate: date("2027-01-01")}, true, ""},
+		{Goal{Name: "Car", TargetCents: 100, TargetDate: date("2027-01-01"), Account: "savings", Tag: "car"}, true, ""},
+		{Goal{Name: "Car", TargetCents: 100, TargetDate: date("2027-01-01"), Tag: "new car"}, true, ""},
+		{Goal{Name: "Car", TargetCents: 100, TargetDate: date("2027-01-01"), Tag: "car%"}, true, ""},
+	}
+	for _, tt := range tests {
+		g := tt.g
+		err := g.Validate()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Validate(%+v) = %v, want error %v", tt.g, err, tt.wantErr)
+		}
+		if err == nil && g.Tag != tt.wantTag {
+			t.Errorf("Validate(%+v) tag = %q, want %q", tt.g, g.Tag, tt.wantTag)
+		}
+	}
+}
+
+func TestTrack(t *testing.T) {
+	ctx := context.Background()
+	d, err := db.Open(filepath.Join(t.TempDir(), "pf.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if err := db.Migrate(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+	for _, g := range []Goal{
+		{Name: "Holiday", TargetCents: 1000000, TargetDate: date("2027-01-01"), Account: "savings", OpeningCents: 100000, StartDate: date("2026-01-01")},
+		{Name: "Car", TargetCents: 50000, TargetDate: date("2026-05-01"), Tag: "car", StartDate: date("2026-04-01")},
+		{Name: "Emergency", TargetCents: 50000, TargetDate: date("2026-12-31"), Account: "offset", OpeningCents: 60000, StartDate: date("2026-04-01")},
+		{Name: "Laptop", TargetCents: 300000, TargetDate: date("2026-04-30"), Tag: "laptop", StartDate: date("2026-04-01")},
+	} {
+		if _, err := Create(ctx, d, g); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, tx := range []struct {
+		date, account, notes string
+		cents                int64
+	}{
+		{"2025-12-15", "savings", "", 50000}, // before the start
+		{"2026-01-15", "savings", "", 100000},
+		{"2026-02-15", "savings", "", 100000},
+		{"2026-03-15", "savings", "", 100000},
+		{"2026-04-01", "savings", "", -20000},
+		{"2026-04-20", "savings", "", 100000}, // after asOf
+		{"2026-04-02", "everyday", "Transfer #car", -30000},
+		{"2026-04-03", "everyday", "#carpet", -10000},
+		{"2026-04-05", "everyday", "#car, extra", -5000},
+		{"2026-04-06", "everyday", "savings\n#car", -1000},
+		{"2026-03-20", "everyday", "#car", -7000}, // before the start
+	} {
+		if _, err := d.Exec(`INSERT INTO transactions (txn_date, account, notes, amount_cents, row_hash) VALUES (?,?,?,?,hex(randomblob(8)))`,
+			tx.date, tx.account, tx.notes, tx.cents); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	asOf := time.Date(2026, 4, 10, 15, 30, 0, 0, time.UTC)
+	got, err := Track(ctx, d, asOf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		name                       string
+		saved, remaining, required int64
+		contributions              int
+		pace                       int64
+		projected                  string
+		onTrack, done              bool
+	}{
+		// soonest target first
+		{"Laptop", 0, 300000, 300000, 0, 0, "", false, false},
+		{"Car", 36000, 14000, 14000, 3, 36000, "2026-04-22", true, false},
+		{"Emergency", 60000, 0, 0, 0, 0, "2026-04-10", true, true},
+		{"Holiday", 380000, 620000, 70945, 4, 93653, "2026-10-29", true, false},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Track returned %d goals, want %d", len(got), len(want))
+	}
+	for i, w := range want {
+		p := got[i]
+		projected := ""
+		if !p.Projected.IsZero() {
+			projected = p.Projected.Format("2006-01-02")
+		}
+		if p.Name != w.name || p.SavedCents != w.saved || p.RemainingCents != w.remaining || p.RequiredMonthlyCents != w.required ||
+			p.Contributions != w.contributions || p.PaceMonthlyCents != w.pace || projected != w.projected ||
+			p.OnTrack() != w.onTrack || p.Done() != w.done {
+			t.Errorf("goal %d = %s saved %d remaining %d required %d from %d, pace %d projected %q on track %v done %v; want %+v",
+				i, p.Name, p.SavedCents, p.RemainingCents, p.RequiredMonthlyCents, p.Contributions,
+				p.PaceMonthlyCents, projected, p.OnTrack(), p.Done(), w)
+		}
+	}
+	if r := got[2].Ratio(); r != 1.2 {
+		t.Errorf("Emergency ratio = %v, want 1.2", r)
+	}
+}
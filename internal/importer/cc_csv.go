@@ -2,6 +2,7 @@ package importer
 
 import (
 	"bufio"
+	"context"
 	"crypto/sha256"
 	"database/sql"
 	"encoding/csv"
@@ -11,19 +12,39 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/anthurium-ai/personal-finance/internal/classify"
+	"github.com/anthurium-ai/personal-finance/internal/merchant"
 )
 
+// Result summarises one import.
+type Result struct {
+	ImportID int64
+	Total    int
+	Inserted int
+	Skipped  int
+
+	// import-time classification of inserted rows
+	Classified int // applied automatically under the policy
+	ByOverride int
+	ByRule     int
+	Queued     int // suggestions below the policy's threshold, sent to review
+}
+
 // ImportCCCSV imports the credit card CSV format you pasted.
 //
 // It dedupes using row_hash (sha256 over canonical fields), so you can re-import safely.
-func ImportCCCSV(db *sql.DB, r io.Reader, fileName string) (importID int64, total int, inserted int, skipped int, err error) {
+// Merchant names are normalised (see merchant.Normaliser), then new rows are classified
+// with merchant overrides, rules and the local model. Suggestions are applied or sent
+// to review according to the auto-apply policy; otherwise rows keep the bank category.
+func ImportCCCSV(ctx context.Context, db *sql.DB, r io.Reader, fileName string) (res Result, err error) {
 	br := bufio.NewReader(r)
 	cr := csv.NewReader(br)
 	cr.FieldsPerRecord = -1
 
 	header, err := cr.Read()
 	if err != nil {
-		return 0, 0, 0, 0, err
+		return res, err
 	}
 	idx := indexMap(header)
 
@@ -31,9 +52,9 @@ func ImportCCCSV(db *sql.DB, r io.Reader, fileName string) (importID int64, tota
 	// We can't rewind easily here, so just hash canonical rows while reading.
 	fileHash := sha256.New()
 
-	tx, err := db.Begin()
+	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
-		return 0, 0, 0, 0, err
+		return res, err
 	}
 	defer func() {
 		if err != nil {
@@ -41,18 +62,34 @@ func ImportCCCSV(db *sql.DB, r io.Reader, fileName string) (importID int64, tota
 		}
 	}()
 
-	res, err := tx.Exec(`INSERT INTO imports (source,file_name,sha256,rows_total,rows_inserted,rows_skipped) VALUES (?,?,?,?,?,?)`, "cc_csv", fileName, "", 0, 0, 0)
+	ins, err := tx.Exec(`INSERT INTO imports (source,file_name,sha256,rows_total,rows_inserted,rows_skipped) VALUES (?,?,?,?,?,?)`, "cc_csv", fileName, "", 0, 0, 0)
+	if err != nil {
+		return res, err
+	}
+	res.ImportID, _ = ins.LastInsertId()
+
+	cls, err := classify.Load(ctx, tx)
+	if err != nil {
+		return res, err
+	}
+	if err = cls.LoadModel(ctx, tx); err != nil {
+		return res, err
+	}
+	policy, err := classify.LoadPolicy(ctx, tx)
+	if err != nil {
+		return res, err
+	}
+	norm, err := merchant.Load(ctx, tx)
 	if err != nil {
-		return 0, 0, 0, 0, err
+		return res, err
 	}
-	importID, _ = res.LastInsertId()
 
 	insStmt, err := tx.Prepare(`INSERT INTO transactions (
 		import_id, txn_date, processed_on, amount_cents, account, txn_type, details, category_raw, merchant_raw,
-		merchant_norm, category_norm, row_hash
-	) VALUES (?,?,?,?,?,?,?,?,?,?,?,?)`)
+		merchant_norm, category_norm, category_source, category_confidence, category_set_at, row_hash
+	) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,strftime('%Y-%m-%dT%H:%M:%fZ','now'),?)`)
 	if err != nil {
-		return 0, 0, 0, 0, err
+		return res, err
 	}
 	defer insStmt.Close()
 
@@ -65,7 +102,7 @@ func ImportCCCSV(db *sql.DB, r io.Reader, fileName string) (importID int64, tota
 			err = err2
 			return
 		}
-		total++
+		res.Total++
 
 		get := func(name string) string {
 			i, ok := idx[strings.ToLower(name)]
@@ -81,41 +118,65 @@ func ImportCCCSV(db *sql.DB, r io.Reader, fileName string) (importID int64, tota
 		txnType := get("Transaction Type")
 		details := get("Transaction Details")
 		cat := get("Category")
-		merchant := get("Merchant Name")
+		merchantRaw := get("Merchant Name")
 		processedOn := get("Processed On")
 
 		txnDate, err2 := parseAUDate(dateStr)
 		if err2 != nil {
-			skipped++
+			res.Skipped++
 			continue
 		}
 		amountCents, err2 := parseAmountCents(amtStr)
 		if err2 != nil {
-			skipped++
+			res.Skipped++
 			continue
 		}
 
-		merchantNorm := strings.TrimSpace(merchant)
+		merchantNorm := norm.Normalise(merchantRaw)
 		catNorm := strings.TrimSpace(cat)
 
-		rowHash := hashRow(txnDate.Format("2006-01-02"), processedOn, amountCents, acct, txnType, details, cat, merchant)
+		rowHash := hashRow(txnDate.Format("2006-01-02"), processedOn, amountCents, acct, txnType, details, cat, merchantRaw)
 		fileHash.Write([]byte(rowHash))
 
-		_, err2 = insStmt.Exec(importID, txnDate.Format("2006-01-02"), processedOn, amountCents, acct, txnType, details, cat, merchant, merchantNorm, catNorm, rowHash)
+		inserted, err2 := insStmt.Exec(res.ImportID, txnDate.Format("2006-01-02"), processedOn, amountCents, acct, txnType, details, cat, merchantRaw, merchantNorm, catNorm, classify.SourceBank, classify.ConfidenceBank, rowHash)
 		if err2 != nil {
 			// unique constraint => already imported
 			if strings.Contains(err2.Error(), "UNIQUE") {
-				skipped++
+				res.Skipped++
 				continue
 			}
 			err = err2
 			return
 		}
-		inserted++
+		res.Inserted++
+
+		sug := cls.Suggest(classify.Input{Merchant: merchantNorm, Details: details, AmountCents: amountCents, Account: acct})
+		if sug == nil {
+			continue
+		}
+		txID, _ := inserted.LastInsertId()
+		out, err2 := classify.Route(ctx, tx, policy, txID, 0, sug)
+		if err2 != nil {
+			err = err2
+			return
+		}
+		switch out {
+		case classify.Applied:
+			res.Classified++
+			switch sug.Source {
+			case classify.SourceOverride:
+				res.ByOverride++
+			case classify.SourceRule:
+				res.ByRule++
+			}
+		case classify.Queued:
+			res.Queued++
+		}
 	}
 
 	sha := hex.EncodeToString(fileHash.Sum(nil))
-	_, err = tx.Exec(`UPDATE imports SET sha256=?, rows_total=?, rows_inserted=?, rows_skipped=? WHERE id=?`, sha, total, inserted, skipped, importID)
+	_, err = tx.Exec(`UPDATE imports SET sha256=?, rows_total=?, rows_inserted=?, rows_skipped=?, rows_classified=?, rows_by_override=?, rows_by_rule=?, rows_queued=? WHERE id=?`,
+		sha, res.Total, res.Inserted, res.Skipped, res.Classified, res.ByOverride, res.ByRule, res.Queued, res.ImportID)
 	if err != nil {
 		return
 	}
@@ -0,0 +1,99 @@
+package importer
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/anthurium-ai/personal-finance/internal/classify"
+	"github.com/anthurium-ai/personal-finance/internal/db"
+)
+
+const ccCSV = `Date,Amount,Account Number,Transaction Type,Transaction Details,Category,Merchant Name,Processed On
+07 Feb 26,-45.50,1234,PURCHASE,KMART 1234 SYDNEY,Shopping,KMART 1234 SYDNEY NSW,08 Feb 26
+08 Feb 26,-16.99,1234,PURCHASE,NETFLIX.COM,Entertainment,NETFLIX.COM,09 Feb 26
+09 Feb 26,-4.20,1234,PURCHASE,CAFE 21,Dining,SQ *CAFE 21,10 Feb 26
+31 Feb 26,-9.99,1234,PURCHASE,BAD DATE,Other,BAD DATE,01 Mar 26
+10 Feb 26,abc,1234,PURCHASE,BAD AMOUNT,Other,BAD AMOUNT,11 Feb 26
+`
+
+func TestImportCCCSV(t *testing.T) {
+	ctx := context.Background()
+	d, err := db.Open(filepath.Join(t.TempDir(), "pf.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if err := db.Migrate(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+	// KMART has an override, applied; NETFLIX a rule, held for review
+	for _, q := range []string{
+		`INSERT INTO merchant_category_overrides (merchant_norm, category_norm) VALUES ('KMART','Household')`,
+		`INSERT INTO category_rules (match_contains, category_norm) VALUES ('NETFLIX','Streaming')`,
+	} {
+		if _, err := d.Exec(q); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := classify.SavePolicy(ctx, d, classify.Threshold{Source: classify.SourceRule, Auto: false}); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := ImportCCCSV(ctx, d, strings.NewReader(ccCSV), "feb.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Result{ImportID: res.ImportID, Total: 5, Inserted: 3, Skipped: 2, Classified: 1, ByOverride: 1, Queued: 1}
+	if res != want {
+		t.Errorf("first import = %+v, want %+v", res, want)
+	}
+
+	// the summary is kept for /imports
+	var got Result
+	err = d.QueryRow(`SELECT rows_total, rows_inserted, rows_skipped, rows_classified, rows_by_override, rows_by_rule, rows_queued
+		FROM imports WHERE id=?`, res.ImportID).Scan(&got.Total, &got.Inserted, &got.Skipped, &got.Classified, &got.ByOverride, &got.ByRule, &got.Queued)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got.ImportID = res.ImportID
+	if got != want {
+		t.Errorf("imports row = %+v, want %+v", got, want)
+	}
+
+	for _, c := range []struct {
+		merchant, category, source string
+		pending                    bool
+	}{
+		{"KMART", "Household", classify.SourceOverride, false},
+		{"NETFLIX.COM", "Entertainment", classify.SourceBank, true},
+		{"CAFE 21", "Dining", classify.SourceBank, false},
+	} {
+		var cat, source string
+		var pending bool
+		err := d.QueryRow(`SELECT category_norm, category_source,
+			EXISTS(SELECT 1 FROM classification_suggestions s WHERE s.tx_id=transactions.id AND s.status='pending')
+			FROM transactions WHERE merchant_norm=?`, c.merchant).Scan(&cat, &source, &pending)
+		if err == sql.ErrNoRows {
+			t.Errorf("%s: not imported", c.merchant)
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cat != c.category || source != c.source || pending != c.pending {
+			t.Errorf("%s: %s from %s, pending %v; want %s from %s, pending %v", c.merchant, cat, source, pending, c.category, c.source, c.pending)
+		}
+	}
+
+	// importing the same file again adds nothing
+	res, err = ImportCCCSV(ctx, d, strings.NewReader(ccCSV), "feb.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Inserted != 0 || res.Skipped != 5 || res.Queued != 0 {
+		t.Errorf("second import = %+v, want every row skipped", res)
+	}
+}
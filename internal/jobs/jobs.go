@@ -0,0 +1,109 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+)
+
+// Statuses stored in jobs.status.
+const (
+	StatusRunning = "running"
+	StatusDone    = "done"
+	StatusFailed  = "failed"
+)
+
+type Job struct {
+	ID         int64
+	Kind       string
+	Status     string
+	Total      int
+	Done       int
+	Message    string
+	CreatedAt  string
+	FinishedAt string
+}
+
+// Percent is progress in 0..100.
+func (j Job) Percent() int {
+	if j.Total <= 0 {
+		if j.Status == StatusRunning {
+			return 0
+		}
+		return 100
+	}
+	return j.Done * 100 / j.Total
+}
+
+// Progress reports done/total and a short status line.
+type Progress func(done, total int, msg string)
+
+// ErrRunning is returned when a job of the same kind is already in progress.
+var ErrRunning = errors.New("a job of this kind is already running")
+
+// Start records a job and runs fn in the background with ctx, which should
+// outlive the request that started it. Progress and the final status (fn's
+// message, or its error) are written to the jobs table.
+func Start(ctx context.Context, d *sql.DB, kind string, fn func(ctx context.Context, jobID int64, progress Progress) (string, error)) (int64, error) {
+	// check and insert in one statement, so two requests can't both start one
+	res, err := d.ExecContext(ctx, `INSERT INTO jobs (kind, status) SELECT ?, ?
+		WHERE NOT EXISTS (SELECT 1 FROM jobs WHERE kind=? AND status=?)`, kind, StatusRunning, kind, StatusRunning)
+	if err != nil {
+		return 0, err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return 0, err
+	} else if n == 0 {
+		return 0, ErrRunning
+	}
+	id, _ := res.LastInsertId()
+
+	go func() {
+		progress := func(done, total int, msg string) {
+			_, _ = d.ExecContext(context.Background(), `UPDATE jobs SET done=?, total=?, message=? WHERE id=?`, done, total, msg, id)
+		}
+		msg, err := fn(ctx, id, progress)
+		status := StatusDone
+		if err != nil {
+			status = StatusFailed
+			msg = err.Error()
+			log.Printf("job %d (%s) failed: %v", id, kind, err)
+		}
+		_, _ = d.ExecContext(context.Background(), `UPDATE jobs SET status=?, message=?, finished_at=strftime('%Y-%m-%dT%H:%M:%fZ','now') WHERE id=?`, status, msg, id)
+	}()
+	return id, nil
+}
+
+// Abandon marks jobs still "running" from a previous process as failed.
+func Abandon(ctx context.Context, d *sql.DB) error {
+	_, err := d.ExecContext(ctx, `UPDATE jobs SET status=?, message='interrupted by restart', finished_at=strftime('%Y-%m-%dT%H:%M:%fZ','now') WHERE status=?`, StatusFailed, StatusRunning)
+	return err
+}
+
+// Running reports whether a job of kind is in progress.
+func Running(ctx context.Context, d *sql.DB, kind string) (bool, error) {
+	var n int
+	err := d.QueryRowContext(ctx, `SELECT COUNT(*) FROM jobs WHERE kind=? AND status=?`, kind, StatusRunning).Scan(&n)
+	return n > 0, err
+}
+
+// Recent lists the latest jobs, newest first.
+func Recent(ctx context.Context, d *sql.DB, limit int) ([]Job, error) {
+	rows, err := d.QueryContext(ctx, `
+		SELECT id, kind, status, total, done, COALESCE(message,''), created_at, COALESCE(finished_at,'')
+		FROM jobs ORDER BY id DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Job
+	for rows.Next() {
+		var j Job
+		if err := rows.Scan(&j.ID, &j.Kind, &j.Status, &j.Total, &j.Done, &j.Message, &j.CreatedAt, &j.FinishedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, j)
+	}
+	return out, rows.Err()
+}
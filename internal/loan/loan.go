@@ -0,0 +1,476 @@
+// Package loan models loans and mortgages: an amortisation schedule from the
+// rate history, imported repayments split into interest and principal, and
+// payoff projections with an offset account or extra repayments.
+package loan
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/anthurium-ai/personal-finance/internal/db"
+	"github.com/anthurium-ai/personal-finance/internal/networth"
+)
+
+// Frequency is how often repayments are made.
+type Frequency struct {
+	Name    string
+	PerYear int
+}
+
+var Frequencies = []Frequency{{"weekly", 52}, {"fortnightly", 26}, {"monthly", 12}}
+
+func FrequencyByName(name string) (Frequency, bool) {
+	for _, f := range Frequencies {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return Frequency{}, false
+}
+
+// Loan is a loan or mortgage. Principal is what was owed on Start, and Term
+// the months left from then; for a loan taken out before its transactions
+// were imported, use a statement's balance and date.
+type Loan struct {
+	ID             int64
+	Name           string
+	PrincipalCents int64
+	Start          time.Time
+	TermMonths     int
+	Frequency      string
+	RepaymentCents int64 // what is paid each time; 0 pays the minimum
+
+	// repayments are the transactions going out with MatchText in their
+	// merchant or details, on Account if set
+	MatchText     string
+	Account       string
+	OffsetAccount string // its balance is taken off the balance interest is charged on
+}
+
+func (l *Loan) frequency() Frequency {
+	f, _ := FrequencyByName(l.Frequency)
+	return f
+}
+
+// repayments is how many repayments the term takes.
+func (l *Loan) repayments() int {
+	return int(math.Round(float64(l.TermMonths) * float64(l.frequency().PerYear) / 12))
+}
+
+// Due is the date of the nth repayment (the first is n=1).
+func (l *Loan) Due(n int) time.Time {
+	switch l.Frequency {
+	case "weekly":
+		return l.Start.AddDate(0, 0, 7*n)
+	case "fortnightly":
+		return l.Start.AddDate(0, 0, 14*n)
+	}
+	// monthly on the start's day, or the month's last day if it is shorter
+	first := time.Date(l.Start.Year(), l.Start.Month()+time.Month(n), 1, 0, 0, 0, 0, time.UTC)
+	return first.AddDate(0, 0, min(l.Start.Day(), first.AddDate(0, 1, -1).Day())-1)
+}
+
+// Validate checks l before it is stored, and tidies the match text.
+func (l *Loan) Validate() error {
+	l.Name = strings.TrimSpace(l.Name)
+	l.MatchText = strings.TrimSpace(l.MatchText)
+	if l.MatchText == "" {
+		l.MatchText = l.Name
+	}
+	l.Account = strings.TrimSpace(l.Account)
+	l.OffsetAccount = strings.TrimSpace(l.OffsetAccount)
+	switch {
+	case l.Name == "":
+		return fmt.Errorf("name is required")
+	case l.PrincipalCents <= 0:
+		return fmt.Errorf("principal must be more than zero")
+	case l.Start.IsZero():
+		return fmt.Errorf("start date is required")
+	case l.TermMonths < 1 || l.TermMonths > 600:
+		return fmt.Errorf("term must be 1 to 600 months")
+	case l.RepaymentCents < 0:
+		return fmt.Errorf("repayment can't be negative")
+	}
+	if _, ok := FrequencyByName(l.Frequency); !ok {
+		return fmt.Errorf("unknown frequency %q", l.Frequency)
+	}
+	return nil
+}
+
+// Create stores l with its rate from the start and returns its id.
+func Create(ctx context.Context, d *sql.DB, l Loan, ratePct float64) (int64, error) {
+	if err := l.Validate(); err != nil {
+		return 0, err
+	}
+	tx, err := d.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	res, err := tx.ExecContext(ctx, `INSERT INTO loans (name, principal_cents, start_date, term_months, frequency, repayment_cents, match_text, account, offset_account)
+		VALUES (?,?,?,?,?,?,?,?,?)`,
+		l.Name, l.PrincipalCents, l.Start.Format("2006-01-02"), l.TermMonths, l.Frequency, l.RepaymentCents, l.MatchText, l.Account, l.OffsetAccount)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	if err := SetRate(ctx, tx, id, l.Start, ratePct); err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+func Delete(ctx context.Context, q db.Querier, id int64) error {
+	_, err := q.ExecContext(ctx, `DELETE FROM loans WHERE id=?`, id)
+	return err
+}
+
+const loanColumns = `id, name, principal_cents, start_date, term_months, frequency, repayment_cents, match_text, COALESCE(account,''), COALESCE(offset_account,'')`
+
+func scanLoan(row interface{ Scan(...any) error }) (Loan, error) {
+	var l Loan
+	var start string
+	err := row.Scan(&l.ID, &l.Name, &l.PrincipalCents, &start, &l.TermMonths, &l.Frequency, &l.RepaymentCents, &l.MatchText, &l.Account, &l.OffsetAccount)
+	l.Start, _ = time.Parse("2006-01-02", start)
+	return l, err
+}
+
+// List returns every loan by name.
+func List(ctx context.Context, q db.Querier) ([]Loan, error) {
+	rows, err := q.QueryContext(ctx, `SELECT `+loanColumns+` FROM loans ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Loan
+	for rows.Next() {
+		l, err := scanLoan(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, l)
+	}
+	return out, rows.Err()
+}
+
+// Get returns one loan, or sql.ErrNoRows.
+func Get(ctx context.Context, q db.Querier, id int64) (Loan, error) {
+	return scanLoan(q.QueryRowContext(ctx, `SELECT `+loanColumns+` FROM loans WHERE id=?`, id))
+}
+
+// Rate is the annual interest rate from a date until the next change.
+type Rate struct {
+	From    time.Time
+	Percent float64
+}
+
+// SetRate records a rate change, replacing any on the same day.
+func SetRate(ctx context.Context, q db.Querier, loanID int64, from time.Time, pct float64) error {
+	if pct < 0 || pct > 50 {
+		return fmt.Errorf("rate must be 0 to 50%%")
+	}
+	_, err := q.ExecContext(ctx, `INSERT INTO loan_rates (loan_id, effective_from, rate_pct) VALUES (?,?,?)
+		ON CONFLICT(loan_id, effective_from) DO UPDATE SET rate_pct=excluded.rate_pct`,
+		loanID, from.Format("2006-01-02"), pct)
+	return err
+}
+
+func DeleteRate(ctx context.Context, q db.Querier, loanID int64, from time.Time) error {
+	_, err := q.ExecContext(ctx, `DELETE FROM loan_rates WHERE loan_id=? AND effective_from=?`, loanID, from.Format("2006-01-02"))
+	return err
+}
+
+// Rates lists a loan's rate history, oldest first.
+func Rates(ctx context.Context, q db.Querier, loanID int64) ([]Rate, error) {
+	rows, err := q.QueryContext(ctx, `SELECT effective_from, rate_pct FROM loan_rates WHERE loan_id=? ORDER BY effective_from`, loanID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Rate
+	for rows.Next() {
+		var r Rate
+		var from string
+		if err := rows.Scan(&from, &r.Percent); err != nil {
+			return nil, err
+		}
+		r.From, _ = time.Parse("2006-01-02", from)
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+// rateOn is the rate in effect on d; the first rate if d is before it.
+func rateOn(rates []Rate, d time.Time) float64 {
+	var pct float64
+	for i, r := range rates {
+		if i == 0 || !r.From.After(d) {
+			pct = r.Percent
+		}
+	}
+	return pct
+}
+
+// Minimum is the repayment that pays balance off in n equal repayments at
+// pct a year, perYear repayments a year.
+func Minimum(balance int64, pct float64, perYear, n int) int64 {
+	if n < 1 {
+		return balance
+	}
+	r := pct / 100 / float64(perYear)
+	if r == 0 {
+		return int64(math.Ceil(float64(balance) / float64(n)))
+	}
+	return int64(math.Ceil(float64(balance) * r / (1 - math.Pow(1+r, -float64(n)))))
+}
+
+// Period is one repayment in a schedule.
+type Period struct {
+	N              int
+	Date           time.Time
+	RatePct        float64
+	RepaymentCents int64
+	InterestCents  int64
+	PrincipalCents int64
+	BalanceCents   int64 // after the repayment
+}
+
+// Scenario changes a schedule from minimum repayments.
+type Scenario struct {
+	RepaymentCents int64 // instead of the minimum, if more
+	ExtraCents     int64 // on top of each repayment
+	OffsetCents    int64 // held in the offset account throughout
+}
+
+// overrun is how many years past its term a schedule runs before giving up
+// on a loan its repayments don't cover.
+const overrun = 30
+
+// Schedule amortises balance over the repayments due after from. Interest
+// is charged each repayment on the balance less the offset. The minimum is
+// worked out again whenever the rate changes, over the repayments left in
+// the term, from the balance minimum repayments would have left: paying
+// extra shortens the loan rather than lowering the repayment.
+func (l *Loan) Schedule(rates []Rate, balance int64, from time.Time, s Scenario) []Period {
+	perYear := l.frequency().PerYear
+	total := l.repayments()
+	n := 1
+	for !l.Due(n).After(from) {
+		n++
+	}
+	var out []Period
+	base, minimum, lastPct := balance, int64(0), math.NaN()
+	for ; balance > 0 && n <= total+overrun*perYear; n++ {
+		due := l.Due(n)
+		pct := rateOn(rates, due)
+		if pct != lastPct && base > 0 {
+			minimum, lastPct = Minimum(base, pct, perYear, total-n+1), pct
+		}
+		base -= minimum - int64(math.Round(float64(base)*pct/100/float64(perYear)))
+
+		p := Period{N: n, Date: due, RatePct: pct}
+		p.InterestCents = int64(math.Round(float64(max(balance-s.OffsetCents, 0)) * pct / 100 / float64(perYear)))
+		p.RepaymentCents = min(max(minimum, s.RepaymentCents)+s.ExtraCents, balance+p.InterestCents)
+		p.PrincipalCents = p.RepaymentCents - p.InterestCents
+		balance -= p.PrincipalCents
+		p.BalanceCents = balance
+		out = append(out, p)
+	}
+	return out
+}
+
+// Projection sums up a schedule.
+type Projection struct {
+	Periods       []Period
+	PaidOff       bool // false if the repayments never clear the loan
+	Payoff        time.Time
+	InterestCents int64
+	TotalCents    int64
+}
+
+func project(periods []Period) Projection {
+	p := Projection{Periods: periods}
+	for _, pd := range periods {
+		p.InterestCents += pd.InterestCents
+		p.TotalCents += pd.RepaymentCents
+	}
+	if n := len(periods); n > 0 {
+		p.Payoff = periods[n-1].Date
+		p.PaidOff = periods[n-1].BalanceCents <= 0
+	}
+	return p
+}
+
+// Split is an imported repayment divided into interest and principal.
+type Split struct {
+	TxID           int64
+	Date           time.Time
+	AmountCents    int64 // paid, positive
+	OffsetCents    int64 // the offset balance on the day
+	InterestCents  int64 // accrued since the last repayment
+	PrincipalCents int64
+	BalanceCents   int64 // after the repayment
+}
+
+// daysPerYear divides annual rates into daily interest, as Australian
+// lenders do.
+const daysPerYear = 365
+
+// Repayments finds l's repayments among the transactions and splits each
+// into interest and principal. Interest accrues daily on the balance less
+// the offset, taking the offset account's balance on the repayment date as
+// its balance since the last one.
+func Repayments(ctx context.Context, q db.Querier, l Loan, rates []Rate) ([]Split, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT id, txn_date, -amount_cents FROM transactions
+		WHERE txn_date > ? AND amount_cents < 0
+		  AND (? = '' OR account = ?)
+		  AND (UPPER(COALESCE(merchant_norm,'')) LIKE '%' || UPPER(?) || '%' OR UPPER(COALESCE(details,'')) LIKE '%' || UPPER(?) || '%')
+		ORDER BY txn_date, id`,
+		l.Start.Format("2006-01-02"), l.Account, l.Account, l.MatchText, l.MatchText)
+	if err != nil {
+		return nil, err
+	}
+	var out []Split
+	for rows.Next() {
+		var s Split
+		var d string
+		if err := rows.Scan(&s.TxID, &d, &s.AmountCents); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		s.Date, _ = time.Parse("2006-01-02", d)
+		out = append(out, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	balance, prev := l.PrincipalCents, l.Start
+	for i := range out {
+		s := &out[i]
+		if l.OffsetAccount != "" {
+			if s.OffsetCents, err = networth.Balance(ctx, q, l.OffsetAccount, s.Date); err != nil {
+				return nil, err
+			}
+			s.OffsetCents = max(s.OffsetCents, 0)
+		}
+		s.InterestCents = accrued(rates, max(balance-s.OffsetCents, 0), prev, s.Date)
+		s.PrincipalCents = s.AmountCents - s.InterestCents
+		balance -= s.PrincipalCents
+		s.BalanceCents = balance
+		prev = s.Date
+	}
+	return out, nil
+}
+
+// accrued is the daily interest on cents for the days after from up to and
+// including to.
+func accrued(rates []Rate, cents int64, from, to time.Time) int64 {
+	var interest float64
+	for d := from.AddDate(0, 0, 1); !d.After(to); d = d.AddDate(0, 0, 1) {
+		interest += float64(cents) * rateOn(rates, d) / 100 / daysPerYear
+	}
+	return int64(math.Round(interest))
+}
+
+// Status is where a loan stands on a day and where it is heading.
+type Status struct {
+	Loan
+	Rates   []Rate
+	RatePct float64
+
+	Repayments         []Split
+	BalanceCents       int64
+	AsOf               time.Time // the balance is after the last repayment on or before this
+	Estimated          bool      // no repayments found: the balance is the scheduled one
+	InterestPaidCents  int64
+	PrincipalPaidCents int64
+	OffsetCents        int64
+	MinimumCents       int64
+	// RepaymentCents is what is being paid: the loan's repayment if set,
+	// else the last imported one
+	RepaymentCents int64
+
+	// Projections from the balance: minimum repayments and no offset; the
+	// loan's repayment with today's offset balance; and that plus extra.
+	Minimum Projection
+	Current Projection
+	WhatIf  Projection
+}
+
+// SavedCents is the interest saved by the offset and by paying more
+// than the minimum, over the rest of the loan.
+func (s *Status) SavedCents() int64 { return s.Minimum.InterestCents - s.Current.InterestCents }
+
+// WhatIfSavedCents is the interest the extra repayments would save.
+func (s *Status) WhatIfSavedCents() int64 { return s.Current.InterestCents - s.WhatIf.InterestCents }
+
+// Track works out l's status on today, with extra paid on top of each
+// repayment in the what-if projection.
+func Track(ctx context.Context, q db.Querier, l Loan, today time.Time, extraCents int64) (*Status, error) {
+	today = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC)
+	s := &Status{Loan: l}
+	var err error
+	if s.Rates, err = Rates(ctx, q, l.ID); err != nil {
+		return nil, err
+	}
+	if len(s.Rates) == 0 {
+		return nil, fmt.Errorf("%s has no interest rate", l.Name)
+	}
+	s.RatePct = rateOn(s.Rates, today)
+	if s.Repayments, err = Repayments(ctx, q, l, s.Rates); err != nil {
+		return nil, err
+	}
+
+	s.BalanceCents, s.AsOf = l.PrincipalCents, l.Start
+	for _, r := range s.Repayments {
+		if r.Date.After(today) {
+			break
+		}
+		s.BalanceCents, s.AsOf = r.BalanceCents, r.Date
+		s.InterestPaidCents += r.InterestCents
+		s.PrincipalPaidCents += r.PrincipalCents
+	}
+	if len(s.Repayments) == 0 {
+		// nothing imported yet: assume the loan has been repaid as scheduled
+		s.Estimated = true
+		for _, p := range l.Schedule(s.Rates, l.PrincipalCents, l.Start, Scenario{RepaymentCents: l.RepaymentCents}) {
+			if p.Date.After(today) {
+				break
+			}
+			s.BalanceCents, s.AsOf = p.BalanceCents, p.Date
+			s.InterestPaidCents += p.InterestCents
+			s.PrincipalPaidCents += p.PrincipalCents
+		}
+	}
+	if l.OffsetAccount != "" {
+		if s.OffsetCents, err = networth.Balance(ctx, q, l.OffsetAccount, today); err != nil {
+			return nil, err
+		}
+		s.OffsetCents = max(s.OffsetCents, 0)
+	}
+
+	s.Minimum = project(l.Schedule(s.Rates, s.BalanceCents, s.AsOf, Scenario{}))
+	s.RepaymentCents = l.RepaymentCents
+	if s.RepaymentCents == 0 && !s.Estimated {
+		s.RepaymentCents = s.Repayments[len(s.Repayments)-1].AmountCents
+	}
+	current := Scenario{RepaymentCents: s.RepaymentCents, OffsetCents: s.OffsetCents}
+	s.Current = project(l.Schedule(s.Rates, s.BalanceCents, s.AsOf, current))
+	current.ExtraCents = extraCents
+	s.WhatIf = project(l.Schedule(s.Rates, s.BalanceCents, s.AsOf, current))
+	if len(s.Minimum.Periods) > 0 {
+		s.MinimumCents = s.Minimum.Periods[0].RepaymentCents
+	}
+	s.RepaymentCents = max(s.RepaymentCents, s.MinimumCents)
+	return s, nil
+}
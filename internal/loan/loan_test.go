@@ -0,0 +1,118 @@
+package loan
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMinimum(t *testing.T) {
+	tests := []struct {
+		name    string
+		balance int64
+		pct     float64
+		perYear int
+		n       int
+		want    int64
+	}{
+		{"30 year mortgage", 300_000_00, 6, 12, 360, 1798_66},
+		{"one year at 12%", 12_000_00, 12, 12, 12, 1066_19},
+		{"no interest rounds up", 1000_00, 0, 12, 3, 333_34},
+		{"fortnightly", 26_000_00, 0, 26, 26, 1000_00},
+		{"no repayments left", 500_00, 5, 12, 0, 500_00},
+	}
+	for _, tt := range tests {
+		if got := Minimum(tt.balance, tt.pct, tt.perYear, tt.n); got != tt.want {
+			t.Errorf("%s: Minimum = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	start := time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC)
+	l := &Loan{PrincipalCents: 120_000_00, Start: start, TermMonths: 120, Frequency: "monthly"}
+	flat := []Rate{{From: start, Percent: 6}}
+	minimum := project(l.Schedule(flat, l.PrincipalCents, start, Scenario{}))
+
+	tests := []struct {
+		name    string
+		loan    *Loan
+		rates   []Rate
+		s       Scenario
+		periods int // 0 to skip the check
+		check   func(p Projection) bool
+		want    string
+	}{
+		{
+			name: "no interest", loan: &Loan{PrincipalCents: 1200_00, Start: start, TermMonths: 12, Frequency: "monthly"},
+			rates: []Rate{{From: start, Percent: 0}}, periods: 12,
+			check: func(p Projection) bool {
+				return p.InterestCents == 0 && p.TotalCents == 1200_00 && p.Periods[0].RepaymentCents == 100_00
+			},
+			want: "twelve repayments of $100 and no interest",
+		},
+		{
+			name: "minimum repayments", loan: l, rates: flat, periods: 120,
+			check: func(p Projection) bool {
+				return p.Payoff.Equal(l.Due(120)) && p.TotalCents == l.PrincipalCents+p.InterestCents
+			},
+			want: "paid off on the last due date, principal plus interest",
+		},
+		{
+			name: "extra repayments", loan: l, rates: flat, s: Scenario{ExtraCents: 500_00},
+			check: func(p Projection) bool {
+				return len(p.Periods) < 120 && p.InterestCents < minimum.InterestCents
+			},
+			want: "shorter and cheaper than the minimum",
+		},
+		{
+			name: "fixed repayment above the minimum", loan: l, rates: flat, s: Scenario{RepaymentCents: 2000_00},
+			check: func(p Projection) bool {
+				return p.Periods[0].RepaymentCents == 2000_00 && len(p.Periods) < 120
+			},
+			want: "$2000 paid each time, finishing early",
+		},
+		{
+			name: "offset", loan: l, rates: flat, s: Scenario{OffsetCents: 20_000_00},
+			check: func(p Projection) bool {
+				return p.Periods[0].InterestCents == 500_00 && p.InterestCents < minimum.InterestCents
+			},
+			want: "interest charged on the balance less the offset",
+		},
+		{
+			name: "rate rise", loan: l, rates: []Rate{{From: start, Percent: 6}, {From: l.Due(60), Percent: 8}}, periods: 120,
+			check: func(p Projection) bool {
+				return p.Periods[59].RatePct == 8 && p.Periods[59].RepaymentCents > p.Periods[0].RepaymentCents
+			},
+			want: "the minimum worked out again over the rest of the term",
+		},
+	}
+	for _, tt := range tests {
+		p := project(tt.loan.Schedule(tt.rates, tt.loan.PrincipalCents, start, tt.s))
+		if tt.periods != 0 && len(p.Periods) != tt.periods {
+			t.Errorf("%s: %d periods, want %d", tt.name, len(p.Periods), tt.periods)
+		}
+		if !p.PaidOff {
+			t.Errorf("%s: not paid off", tt.name)
+		}
+		if !tt.check(p) {
+			t.Errorf("%s: want %s; got interest %d, total %d over %d periods", tt.name, tt.want, p.InterestCents, p.TotalCents, len(p.Periods))
+		}
+	}
+}
+
+func TestScheduleFromPartway(t *testing.T) {
+	start := time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC)
+	l := &Loan{PrincipalCents: 12_000_00, Start: start, TermMonths: 12, Frequency: "monthly"}
+	rates := []Rate{{From: start, Percent: 12}}
+	periods := l.Schedule(rates, 6_000_00, time.Date(2026, 7, 10, 0, 0, 0, 0, time.UTC), Scenario{})
+	if len(periods) == 0 {
+		t.Fatal("no periods")
+	}
+	// the next repayment after 10 July, on the start's day or the month's last
+	if first := periods[0]; first.N != 6 || first.Date.Format("2006-01-02") != "2026-07-31" {
+		t.Errorf("first period %d on %s, want 6 on 2026-07-31", first.N, first.Date.Format("2006-01-02"))
+	}
+	if last := periods[len(periods)-1]; last.N != 12 || last.BalanceCents != 0 {
+		t.Errorf("last period %d leaves %d, want 12 leaving 0", last.N, last.BalanceCents)
+	}
+}
@@ -0,0 +1,115 @@
+package merchant
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/anthurium-ai/personal-finance/internal/db"
+)
+
+// processorPrefixes are payment processors/aggregators that prefix the real merchant.
+var processorPrefixes = []string{
+	"SQ *", "SQ*", "PAYPAL *", "PAYPAL*", "PP*", "ZLR*", "SP *", "SP*", "LS *", "LS*",
+	"TST*", "SMP*", "IZ *", "IZ*", "EZI*", "GOOGLE *",
+}
+
+// locations are trailing tokens dropped from merchant names.
+var locations = map[string]bool{
+	"AU": true, "AUS": true, "AUSTRALIA": true,
+	"NSW": true, "VIC": true, "QLD": true, "SA": true, "WA": true, "TAS": true, "NT": true, "ACT": true,
+	"SYDNEY": true, "MELBOURNE": true, "BRISBANE": true, "PERTH": true, "ADELAIDE": true,
+	"HOBART": true, "DARWIN": true, "CANBERRA": true,
+}
+
+var (
+	// card suffixes: "CARD 1234", "XX1234", "X1234", "CARD XX1234"
+	cardSuffixRe = regexp.MustCompile(`\b(CARD\s*)?X{1,4}\d{2,4}\b|\bCARD\s+\d{4}\b`)
+	// store numbers: "#12", "0456"; shorter bare numbers are often part of
+	// the name ("CAFE 21", "STUDIO 54")
+	storeNumberRe = regexp.MustCompile(`^(#\d+|\d{3,})$`)
+	spaceRe       = regexp.MustCompile(`\s+`)
+)
+
+// Clean applies the built-in rules: processor prefixes, card suffixes,
+// store numbers and trailing locations. It does not apply aliases.
+func Clean(raw string) string {
+	s := strings.ToUpper(strings.TrimSpace(raw))
+	if s == "" {
+		return ""
+	}
+	for _, p := range processorPrefixes {
+		if strings.HasPrefix(s, p) && len(s) > len(p) {
+			s = strings.TrimSpace(s[len(p):])
+			break
+		}
+	}
+	s = cardSuffixRe.ReplaceAllString(s, " ")
+	s = spaceRe.ReplaceAllString(strings.TrimSpace(s), " ")
+
+	words := strings.Fields(s)
+	// drop a trailing store number, before or after the locations, keeping
+	// at least one word
+	words = trimLocations(words)
+	if len(words) > 1 && storeNumberRe.MatchString(words[len(words)-1]) {
+		words = trimLocations(words[:len(words)-1])
+	}
+	out := strings.Join(words, " ")
+	if out == "" {
+		return strings.ToUpper(strings.TrimSpace(raw))
+	}
+	return out
+}
+
+func trimLocations(words []string) []string {
+	for len(words) > 1 && locations[words[len(words)-1]] {
+		words = words[:len(words)-1]
+	}
+	return words
+}
+
+type alias struct {
+	alias    string
+	merchant string
+	exact    bool // the whole cleaned name, never a prefix
+}
+
+// Normaliser cleans merchant names and maps them through the user's aliases.
+type Normaliser struct {
+	aliases []alias
+}
+
+// Load reads merchant_aliases.
+func Load(ctx context.Context, q db.Querier) (*Normaliser, error) {
+	rows, err := q.QueryContext(ctx, `SELECT alias, merchant_norm, exact FROM merchant_aliases ORDER BY length(alias) DESC, id ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	n := &Normaliser{}
+	for rows.Next() {
+		var a alias
+		if err := rows.Scan(&a.alias, &a.merchant, &a.exact); err != nil {
+			return nil, err
+		}
+		a.alias = Clean(a.alias)
+		a.merchant = strings.TrimSpace(a.merchant)
+		if a.alias == "" || a.merchant == "" {
+			continue
+		}
+		n.aliases = append(n.aliases, a)
+	}
+	return n, rows.Err()
+}
+
+// Normalise cleans raw, then applies the longest alias that equals the
+// cleaned name or, unless the alias is exact, is a whole-word prefix of it.
+func (n *Normaliser) Normalise(raw string) string {
+	s := Clean(raw)
+	for _, a := range n.aliases {
+		if s == a.alias || !a.exact && strings.HasPrefix(s, a.alias+" ") {
+			return a.merchant
+		}
+	}
+	return s
+}
@@ -0,0 +1,33 @@
+package merchant
+
+import "testing"
+
+func TestClean(t *testing.T) {
+	tests := []struct {
+		raw, want string
+	}{
+		{"", ""},
+		{"  netflix.com  ", "NETFLIX.COM"},
+		{"SQ *BLUE BOTTLE #12 MELBOURNE", "BLUE BOTTLE"},
+		{"PAYPAL *SPOTIFY", "SPOTIFY"},
+		{"WOOLWORTHS 1234 SYDNEY NSW", "WOOLWORTHS"},
+		{"COLES SYDNEY 0456", "COLES"},
+		{"UBER *TRIP CARD XX1234", "UBER *TRIP"},
+		{"BP CONNECT X4321 AU", "BP CONNECT"},
+		{"KMART CARD 9876", "KMART"},
+		// short numbers and numbers mid-name are part of the name
+		{"CAFE 21", "CAFE 21"},
+		{"CAFE 88 SYDNEY NSW", "CAFE 88"},
+		{"STUDIO 54 NIGHTCLUB SYDNEY", "STUDIO 54 NIGHTCLUB"},
+		{"7-ELEVEN 2045 MELBOURNE", "7-ELEVEN"},
+		// never cleaned away to nothing
+		{"SYDNEY", "SYDNEY"},
+		{"1234", "1234"},
+		{"SQ *", "SQ *"},
+	}
+	for _, tt := range tests {
+		if got := Clean(tt.raw); got != tt.want {
+			t.Errorf("Clean(%q) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
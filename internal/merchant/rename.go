@@ -0,0 +1,120 @@
+package merchant
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+type RenameResult struct {
+	Transactions   int
+	AliasesAdded   int
+	OverrideMoved  bool
+	OverrideMerged bool // target already had an override; the source's was dropped
+}
+
+// Rename moves every transaction from merchant `from` to `to`; renaming onto an
+// existing merchant merges them. The raw names behind `from` become exact
+// aliases of `to` so re-normalisation keeps the result without capturing
+// longer names ("UBER" must not take "UBER EATS"), aliases pointing at `from`
+// are repointed, and from's category override moves to `to` unless `to` has
+// one. The classifier's state keyed by merchant moves the same way: from's
+// cached LLM answers unless `to` has its own, and its model rejections.
+func Rename(ctx context.Context, d *sql.DB, from, to string) (*RenameResult, error) {
+	from = strings.TrimSpace(from)
+	to = strings.TrimSpace(to)
+	if from == "" || to == "" {
+		return nil, fmt.Errorf("merchant names must not be empty")
+	}
+	if from == to {
+		return &RenameResult{}, nil
+	}
+
+	tx, err := d.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	res := &RenameResult{}
+
+	rows, err := tx.QueryContext(ctx, `SELECT DISTINCT COALESCE(merchant_raw,'') FROM transactions WHERE merchant_norm=? AND merchant_manual=0`, from)
+	if err != nil {
+		return nil, err
+	}
+	var cleaned []string
+	seen := map[string]bool{}
+	for rows.Next() {
+		var raw string
+		if err := rows.Scan(&raw); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		// several raw names can clean to one alias
+		if c := Clean(raw); c != "" && c != to && !seen[c] {
+			seen[c] = true
+			cleaned = append(cleaned, c)
+		}
+	}
+	rows.Close()
+	for _, c := range cleaned {
+		// an alias the user added keeps matching as a prefix
+		r, err := tx.ExecContext(ctx, `INSERT INTO merchant_aliases (alias, merchant_norm, exact) VALUES (?,?,1)
+			ON CONFLICT(alias) DO UPDATE SET merchant_norm=excluded.merchant_norm`, c, to)
+		if err != nil {
+			return nil, err
+		}
+		if n, _ := r.RowsAffected(); n > 0 {
+			res.AliasesAdded++
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE merchant_aliases SET merchant_norm=? WHERE merchant_norm=?`, to, from); err != nil {
+		return nil, err
+	}
+
+	r, err := tx.ExecContext(ctx, `UPDATE transactions SET merchant_norm=? WHERE merchant_norm=?`, to, from)
+	if err != nil {
+		return nil, err
+	}
+	n, _ := r.RowsAffected()
+	res.Transactions = int(n)
+
+	var hasTarget bool
+	if err := tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM merchant_category_overrides WHERE merchant_norm=?)`, to).Scan(&hasTarget); err != nil {
+		return nil, err
+	}
+	if hasTarget {
+		r, err = tx.ExecContext(ctx, `DELETE FROM merchant_category_overrides WHERE merchant_norm=?`, from)
+		if err != nil {
+			return nil, err
+		}
+		n, _ = r.RowsAffected()
+		res.OverrideMerged = n > 0
+	} else {
+		r, err = tx.ExecContext(ctx, `UPDATE merchant_category_overrides SET merchant_norm=? WHERE merchant_norm=?`, to, from)
+		if err != nil {
+			return nil, err
+		}
+		n, _ = r.RowsAffected()
+		res.OverrideMoved = n > 0
+	}
+
+	// llm_cache is keyed the way classify looks it up: trimmed, upper case
+	fromKey, toKey := strings.ToUpper(from), strings.ToUpper(to)
+	if fromKey != toKey {
+		if _, err := tx.ExecContext(ctx, `UPDATE OR IGNORE llm_cache SET merchant_norm=? WHERE merchant_norm=?`, toKey, fromKey); err != nil {
+			return nil, err
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM llm_cache WHERE merchant_norm=?`, fromKey); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE OR IGNORE model_rejections SET merchant_norm=? WHERE merchant_norm=?`, to, from); err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM model_rejections WHERE merchant_norm=?`, from); err != nil {
+		return nil, err
+	}
+
+	return res, tx.Commit()
+}
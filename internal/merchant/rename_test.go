@@ -0,0 +1,141 @@
+package merchant
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/anthurium-ai/personal-finance/internal/db"
+)
+
+func openDB(t *testing.T) *sql.DB {
+	t.Helper()
+	d, err := db.Open(filepath.Join(t.TempDir(), "pf.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Close() })
+	if err := db.Migrate(context.Background(), d); err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestRename(t *testing.T) {
+	ctx := context.Background()
+	d := openDB(t)
+	for _, q := range []string{
+		`INSERT INTO transactions (txn_date, amount_cents, merchant_raw, merchant_norm, row_hash) VALUES
+			('2025-03-01', -2300, 'UBER', 'UBER', 'a'),
+			('2025-03-02', -1800, 'UBER SYDNEY', 'UBER', 'b'),
+			('2025-03-03', -3100, 'UBER EATS', 'UBER EATS', 'c'),
+			('2025-03-04', -4000, 'WW ONLINE 1234', 'WW ONLINE', 'd'),
+			('2025-03-05', -9000, 'WOOLWORTHS', 'WOOLWORTHS', 'e')`,
+		`INSERT INTO merchant_aliases (alias, merchant_norm) VALUES ('UBER *TRIP', 'UBER')`,
+		`INSERT INTO merchant_category_overrides (merchant_norm, category_norm) VALUES
+			('UBER', 'Transport'), ('WW ONLINE', 'Shopping'), ('WOOLWORTHS', 'Groceries')`,
+		`INSERT INTO llm_cache (merchant_norm, prompt_version, category_norm, confidence, provider) VALUES
+			('UBER', 'v1', 'Transport', 'high', 'fake'),
+			('WW ONLINE', 'v1', 'Shopping', 'med', 'fake'),
+			('WOOLWORTHS', 'v1', 'Groceries', 'high', 'fake')`,
+		`INSERT INTO model_rejections (merchant_norm, category_norm) VALUES ('UBER', 'Dining'), ('WW ONLINE', 'Dining'), ('WOOLWORTHS', 'Dining')`,
+	} {
+		if _, err := d.Exec(q); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a rename
+	res, err := Rename(ctx, d, " UBER ", "UBER RIDES")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (RenameResult{Transactions: 2, AliasesAdded: 1, OverrideMoved: true}); *res != want {
+		t.Errorf("rename = %+v, want %+v", *res, want)
+	}
+
+	// a merge onto a merchant with its own override and cached answer
+	res, err = Rename(ctx, d, "WW ONLINE", "WOOLWORTHS")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (RenameResult{Transactions: 1, AliasesAdded: 1, OverrideMerged: true}); *res != want {
+		t.Errorf("merge = %+v, want %+v", *res, want)
+	}
+
+	n, err := Load(ctx, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for raw, want := range map[string]string{
+		"UBER":              "UBER RIDES",
+		"UBER SYDNEY NSW":   "UBER RIDES",
+		"UBER *TRIP 55":     "UBER RIDES", // the user's prefix alias, repointed
+		"UBER EATS":         "UBER EATS",  // not captured by the exact alias
+		"UBER EATS SYDNEY":  "UBER EATS",
+		"WW ONLINE 9876":    "WOOLWORTHS",
+		"WW ONLINE DELIVER": "WW ONLINE DELIVER",
+	} {
+		if got := n.Normalise(raw); got != want {
+			t.Errorf("Normalise(%q) = %q, want %q", raw, got, want)
+		}
+	}
+
+	// re-normalising keeps the renames
+	rn, err := Renormalise(ctx, d, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rn.Changed != 0 {
+		t.Errorf("re-normalise changed %d rows: %+v", rn.Changed, rn.Renames)
+	}
+
+	rows := func(q string) map[string]string {
+		t.Helper()
+		r, err := d.Query(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+		out := map[string]string{}
+		for r.Next() {
+			var k, v string
+			if err := r.Scan(&k, &v); err != nil {
+				t.Fatal(err)
+			}
+			out[k] = v
+		}
+		return out
+	}
+	for _, c := range []struct {
+		name, query string
+		want        map[string]string
+	}{
+		{"overrides", `SELECT merchant_norm, category_norm FROM merchant_category_overrides`,
+			map[string]string{"UBER RIDES": "Transport", "WOOLWORTHS": "Groceries"}},
+		{"llm_cache", `SELECT merchant_norm, category_norm FROM llm_cache`,
+			map[string]string{"UBER RIDES": "Transport", "WOOLWORTHS": "Groceries"}},
+		{"model_rejections", `SELECT merchant_norm, category_norm FROM model_rejections`,
+			map[string]string{"UBER RIDES": "Dining", "WOOLWORTHS": "Dining"}},
+	} {
+		got := rows(c.query)
+		if len(got) != len(c.want) {
+			t.Errorf("%s = %v, want %v", c.name, got, c.want)
+			continue
+		}
+		for k, v := range c.want {
+			if got[k] != v {
+				t.Errorf("%s = %v, want %v", c.name, got, c.want)
+				break
+			}
+		}
+	}
+
+	if _, err := Rename(ctx, d, "UBER RIDES", " "); err == nil {
+		t.Error("want an error for an empty name")
+	}
+	if res, err := Rename(ctx, d, "WOOLWORTHS", "WOOLWORTHS"); err != nil || *res != (RenameResult{}) {
+		t.Errorf("rename onto itself = %+v, %v, want nothing done", res, err)
+	}
+}
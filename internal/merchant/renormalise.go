@@ -0,0 +1,103 @@
+package merchant
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+)
+
+// NameChange is one old -> new merchant_norm mapping and how many rows it touched.
+type NameChange struct {
+	Old   string
+	New   string
+	Count int
+}
+
+type RenormaliseResult struct {
+	Scanned        int
+	Changed        int
+	SkippedManual  int
+	OverridesMoved int
+	Renames        []NameChange
+}
+
+// Renormalise re-derives merchant_norm from merchant_raw for every transaction
+// whose merchant wasn't edited by hand, and moves merchant category overrides
+// to the new names where that doesn't clash with an existing override.
+func Renormalise(ctx context.Context, d *sql.DB, dryRun bool) (*RenormaliseResult, error) {
+	tx, err := d.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	n, err := Load(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.QueryContext(ctx, `SELECT id, COALESCE(merchant_raw,''), COALESCE(merchant_norm,''), merchant_manual FROM transactions`)
+	if err != nil {
+		return nil, err
+	}
+	type change struct {
+		id  int64
+		new string
+	}
+	var changes []change
+	counts := map[[2]string]int{}
+	res := &RenormaliseResult{}
+	for rows.Next() {
+		var id int64
+		var raw, old string
+		var manual bool
+		if err := rows.Scan(&id, &raw, &old, &manual); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		res.Scanned++
+		if manual {
+			res.SkippedManual++
+			continue
+		}
+		nw := n.Normalise(raw)
+		if nw == old || nw == "" {
+			continue
+		}
+		changes = append(changes, change{id: id, new: nw})
+		counts[[2]string{old, nw}]++
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	res.Changed = len(changes)
+	for k, c := range counts {
+		res.Renames = append(res.Renames, NameChange{Old: k[0], New: k[1], Count: c})
+	}
+	sort.Slice(res.Renames, func(i, j int) bool {
+		if res.Renames[i].New != res.Renames[j].New {
+			return res.Renames[i].New < res.Renames[j].New
+		}
+		return res.Renames[i].Old < res.Renames[j].Old
+	})
+
+	if dryRun {
+		return res, nil
+	}
+	for _, c := range changes {
+		if _, err := tx.ExecContext(ctx, `UPDATE transactions SET merchant_norm=? WHERE id=?`, c.new, c.id); err != nil {
+			return nil, err
+		}
+	}
+	for _, rn := range res.Renames {
+		r, err := tx.ExecContext(ctx, `UPDATE merchant_category_overrides SET merchant_norm=?
+			WHERE merchant_norm=? AND NOT EXISTS (SELECT 1 FROM merchant_category_overrides WHERE merchant_norm=?)`, rn.New, rn.Old, rn.New)
+		if err != nil {
+			return nil, err
+		}
+		moved, _ := r.RowsAffected()
+		res.OverridesMoved += int(moved)
+	}
+	return res, tx.Commit()
+}
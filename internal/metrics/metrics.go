@@ -3,9 +3,18 @@ package metrics
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"log"
 	"time"
 
+	"github.com/anthurium-ai/personal-finance/internal/anomaly"
+	"github.com/anthurium-ai/personal-finance/internal/budget"
+	"github.com/anthurium-ai/personal-finance/internal/db"
+	"github.com/anthurium-ai/personal-finance/internal/goal"
+	"github.com/anthurium-ai/personal-finance/internal/loan"
+	"github.com/anthurium-ai/personal-finance/internal/networth"
+	"github.com/anthurium-ai/personal-finance/internal/tax"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -16,6 +25,11 @@ type Collector struct {
 	incomeMTD          prometheus.Gauge
 	expenseMTD         prometheus.Gauge
 
+	// Financial year to date (1 July on)
+	incomeFYTD  prometheus.Gauge
+	expenseFYTD prometheus.Gauge
+	taxFYTD     *prometheus.GaugeVec
+
 	// Multi-month series (last N months, inclusive of current)
 	spendByCategoryByMonth *prometheus.GaugeVec
 	expenseByMonth         *prometheus.GaugeVec
@@ -23,6 +37,21 @@ type Collector struct {
 
 	// Top merchants (MTD)
 	spendByMerchantMTD *prometheus.GaugeVec
+
+	// Budgets in effect this month
+	budget          *prometheus.GaugeVec
+	budgetRemaining *prometheus.GaugeVec
+
+	goalProgress *prometheus.GaugeVec
+
+	netWorth    prometheus.Gauge
+	assetValue  *prometheus.GaugeVec
+	loanBalance *prometheus.GaugeVec
+
+	anomaliesOpen *prometheus.GaugeVec
+
+	// 1 for each area whose last refresh failed
+	refreshFailed *prometheus.GaugeVec
 }
 
 func New(db *sql.DB) *Collector {
@@ -45,6 +74,22 @@ func New(db *sql.DB) *Collector {
 		Help:      "Month-to-date expenses in cents",
 	})
 
+	c.incomeFYTD = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "pf",
+		Name:      "income_fytd_cents",
+		Help:      "Financial-year-to-date income in cents (the year starts 1 July)",
+	})
+	c.expenseFYTD = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "pf",
+		Name:      "expense_fytd_cents",
+		Help:      "Financial-year-to-date expenses in cents (the year starts 1 July)",
+	})
+	c.taxFYTD = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "pf",
+		Name:      "tax_fytd_cents",
+		Help:      "Financial-year-to-date tax-relevant totals in cents by tax category (deductions negative)",
+	}, []string{"tax_category"})
+
 	c.spendByCategoryByMonth = prometheus.NewGaugeVec(prometheus.GaugeOpts{
 		Namespace: "pf",
 		Name:      "spend_by_category_month_cents",
@@ -69,6 +114,52 @@ func New(db *sql.DB) *Collector {
 		Help:      "Month-to-date spend by merchant in cents (top N only)",
 	}, []string{"merchant"})
 
+	c.budget = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "pf",
+		Name:      "budget_cents",
+		Help:      "Budget for the current period by category in cents",
+	}, []string{"category"})
+	c.budgetRemaining = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "pf",
+		Name:      "budget_remaining_cents",
+		Help:      "Budget left in the current period by category in cents (negative when over)",
+	}, []string{"category"})
+
+	c.goalProgress = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "pf",
+		Name:      "goal_progress_ratio",
+		Help:      "Savings goal progress: saved over target (1 = reached)",
+	}, []string{"goal"})
+
+	c.netWorth = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "pf",
+		Name:      "net_worth_cents",
+		Help:      "Account balances plus assets less liabilities, in cents",
+	})
+	c.assetValue = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "pf",
+		Name:      "asset_value_cents",
+		Help:      "Latest value of each manually valued asset in cents (liabilities negative)",
+	}, []string{"asset"})
+
+	c.loanBalance = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "pf",
+		Name:      "loan_balance_cents",
+		Help:      "Balance owed on each loan in cents, after its last repayment",
+	}, []string{"loan"})
+
+	c.anomaliesOpen = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "pf",
+		Name:      "anomalies_open",
+		Help:      "Open alerts in the anomalies inbox by kind",
+	}, []string{"kind"})
+
+	c.refreshFailed = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "pf",
+		Name:      "refresh_failed",
+		Help:      "1 when the last refresh of an area's gauges failed (its gauges keep their previous values or are empty)",
+	}, []string{"area"})
+
 	return c
 }
 
@@ -77,23 +168,61 @@ func (c *Collector) Register(reg prometheus.Registerer) {
 		c.spendByCategoryMTD,
 		c.incomeMTD,
 		c.expenseMTD,
+		c.incomeFYTD,
+		c.expenseFYTD,
+		c.taxFYTD,
 		c.spendByCategoryByMonth,
 		c.expenseByMonth,
 		c.incomeByMonth,
 		c.spendByMerchantMTD,
+		c.budget,
+		c.budgetRemaining,
+		c.goalProgress,
+		c.netWorth,
+		c.assetValue,
+		c.loanBalance,
+		c.anomaliesOpen,
+		c.refreshFailed,
 	)
 }
 
-// Refresh recomputes gauges (call on each scrape or periodically).
+// Refresh recomputes gauges (call on each scrape or periodically). Each
+// area is refreshed on its own: one that fails is logged and counted in
+// pf_refresh_failed, and the others still update.
 func (c *Collector) Refresh(ctx context.Context) error {
-	start := monthStart(time.Now())
+	now := time.Now()
+	var errs []error
+	for _, s := range []struct {
+		name    string
+		refresh func(context.Context, time.Time) error
+	}{
+		{"mtd", c.refreshMTD},
+		{"fytd", c.refreshFYTD},
+		{"budgets", c.refreshBudgets},
+		{"goals", c.refreshGoals},
+		{"networth", c.refreshNetWorth},
+		{"loans", c.refreshLoans},
+		{"months", c.refreshMonths},
+		{"alerts", c.refreshAlerts},
+	} {
+		if err := s.refresh(ctx, now); err != nil {
+			log.Printf("metrics: %s: %v", s.name, err)
+			c.refreshFailed.WithLabelValues(s.name).Set(1)
+			errs = append(errs, fmt.Errorf("%s: %w", s.name, err))
+			continue
+		}
+		c.refreshFailed.WithLabelValues(s.name).Set(0)
+	}
+	return errors.Join(errs...)
+}
 
-	// --- MTD ---
+func (c *Collector) refreshMTD(ctx context.Context, now time.Time) error {
+	start := monthStart(now)
 	c.spendByCategoryMTD.Reset()
 	c.spendByMerchantMTD.Reset()
 
 	rows, err := c.db.QueryContext(ctx, `
-		SELECT COALESCE(NULLIF(category_norm,''), COALESCE(NULLIF(category_raw,''),'Uncategorised')) as cat,
+		SELECT `+db.CategorySQL("")+` as cat,
 		       SUM(CASE WHEN amount_cents < 0 THEN -amount_cents ELSE 0 END) as spend
 		FROM transactions
 		WHERE txn_date >= ?
@@ -134,8 +263,8 @@ func (c *Collector) Refresh(ctx context.Context) error {
 	var income, expense int64
 	err = c.db.QueryRowContext(ctx, `
 		SELECT
-		  SUM(CASE WHEN amount_cents > 0 THEN amount_cents ELSE 0 END) as income,
-		  SUM(CASE WHEN amount_cents < 0 THEN -amount_cents ELSE 0 END) as expense
+		  COALESCE(SUM(CASE WHEN amount_cents > 0 THEN amount_cents ELSE 0 END),0) as income,
+		  COALESCE(SUM(CASE WHEN amount_cents < 0 THEN -amount_cents ELSE 0 END),0) as expense
 		FROM transactions
 		WHERE txn_date >= ?
 	`, start.Format("2006-01-02")).Scan(&income, &expense)
@@ -144,23 +273,101 @@ func (c *Collector) Refresh(ctx context.Context) error {
 	}
 	c.incomeMTD.Set(float64(income))
 	c.expenseMTD.Set(float64(expense))
+	return nil
+}
+
+// refreshFYTD covers the financial year to date.
+func (c *Collector) refreshFYTD(ctx context.Context, now time.Time) error {
+	c.taxFYTD.Reset()
+
+	report, err := tax.Build(ctx, c.db, tax.YearOf(now))
+	if err != nil {
+		return err
+	}
+	c.incomeFYTD.Set(float64(report.IncomeCents))
+	c.expenseFYTD.Set(float64(report.ExpenseCents))
+	for _, t := range report.Totals {
+		c.taxFYTD.WithLabelValues(t.Key).Set(float64(t.AmountCents))
+	}
+	return nil
+}
 
-	// --- Last N months ---
+func (c *Collector) refreshBudgets(ctx context.Context, now time.Time) error {
+	c.budget.Reset()
+	c.budgetRemaining.Reset()
+
+	budgets, err := budget.Track(ctx, c.db, now)
+	if err != nil {
+		return err
+	}
+	for _, b := range budgets {
+		c.budget.WithLabelValues(b.Category).Set(float64(b.AmountCents))
+		c.budgetRemaining.WithLabelValues(b.Category).Set(float64(b.RemainingCents))
+	}
+	return nil
+}
+
+func (c *Collector) refreshGoals(ctx context.Context, now time.Time) error {
+	c.goalProgress.Reset()
+
+	goals, err := goal.Track(ctx, c.db, now)
+	if err != nil {
+		return err
+	}
+	for _, g := range goals {
+		c.goalProgress.WithLabelValues(g.Name).Set(g.Ratio())
+	}
+	return nil
+}
+
+func (c *Collector) refreshNetWorth(ctx context.Context, now time.Time) error {
+	c.assetValue.Reset()
+
+	nw, err := networth.At(ctx, c.db, now)
+	if err != nil {
+		return err
+	}
+	c.netWorth.Set(float64(nw[0].NetCents()))
+	for _, l := range append(nw[0].Assets, nw[0].Liabilities...) {
+		c.assetValue.WithLabelValues(l.Name).Set(float64(l.ValueCents))
+	}
+	return nil
+}
+
+func (c *Collector) refreshLoans(ctx context.Context, now time.Time) error {
+	c.loanBalance.Reset()
+
+	loans, err := loan.List(ctx, c.db)
+	if err != nil {
+		return err
+	}
+	for _, l := range loans {
+		s, err := loan.Track(ctx, c.db, l, now, 0)
+		if err != nil {
+			return err
+		}
+		c.loanBalance.WithLabelValues(l.Name).Set(float64(s.BalanceCents))
+	}
+	return nil
+}
+
+// refreshMonths covers the last N months.
+func (c *Collector) refreshMonths(ctx context.Context, now time.Time) error {
 	c.spendByCategoryByMonth.Reset()
 	c.incomeByMonth.Reset()
 	c.expenseByMonth.Reset()
 
-	months := lastNMonths(time.Now(), 6)
+	months := lastNMonths(now, 6)
 	for _, m := range months {
 		from := time.Date(m.Year(), m.Month(), 1, 0, 0, 0, 0, m.Location())
 		to := from.AddDate(0, 1, 0)
 		label := from.Format("2006-01")
 
 		var inc, exp int64
-		err = c.db.QueryRowContext(ctx, `
+		err := c.db.QueryRowContext(ctx, `
 			SELECT
-			  SUM(CASE WHEN amount_cents > 0 THEN amount_cents ELSE 0 END) as income,
-			  SUM(CASE WHEN amount_cents < 0 THEN -amount_cents ELSE 0 END) as expense
+			  COALESCE(SUM(CASE WHEN amount_cents > 0 THEN amount_cents ELSE 0 END),0) as income,
+			  COALESCE(SUM(CASE WHEN amount_cents < 0 THEN -amount_cents ELSE 0 END),0) as expense
 			FROM transactions
 			WHERE txn_date >= ? AND txn_date < ?
 		`, from.Format("2006-01-02"), to.Format("2006-01-02")).Scan(&inc, &exp)
@@ -171,7 +378,7 @@ func (c *Collector) Refresh(ctx context.Context) error {
 		c.expenseByMonth.WithLabelValues(label).Set(float64(exp))
 
 		crows, err := c.db.QueryContext(ctx, `
-			SELECT COALESCE(NULLIF(category_norm,''), COALESCE(NULLIF(category_raw,''),'Uncategorised')) as cat,
+			SELECT `+db.CategorySQL("")+` as cat,
 			       SUM(CASE WHEN amount_cents < 0 THEN -amount_cents ELSE 0 END) as spend
 			FROM transactions
 			WHERE txn_date >= ? AND txn_date < ?
@@ -189,7 +396,19 @@ func (c *Collector) Refresh(ctx context.Context) error {
 		}
 		crows.Close()
 	}
+	return nil
+}
 
+func (c *Collector) refreshAlerts(ctx context.Context, _ time.Time) error {
+	c.anomaliesOpen.Reset()
+
+	open, err := anomaly.OpenByKind(ctx, c.db)
+	if err != nil {
+		return err
+	}
+	for kind, n := range open {
+		c.anomaliesOpen.WithLabelValues(kind).Set(float64(n))
+	}
 	return nil
 }
 
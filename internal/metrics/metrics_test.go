@@ -0,0 +1,106 @@
+package metrics
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anthurium-ai/personal-finance/internal/db"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// gauges reads every gauge in reg as name{label="value",...} -> value.
+func gauges(t *testing.T, reg *prometheus.Registry) map[string]float64 {
+	t.Helper()
+	fams, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := map[string]float64{}
+	for _, f := range fams {
+		for _, m := range f.GetMetric() {
+			var labels []string
+			for _, l := range m.GetLabel() {
+				labels = append(labels, l.GetName()+`="`+l.GetValue()+`"`)
+			}
+			key := f.GetName()
+			if len(labels) > 0 {
+				key += "{" + strings.Join(labels, ",") + "}"
+			}
+			out[key] = m.GetGauge().GetValue()
+		}
+	}
+	return out
+}
+
+func TestRefresh(t *testing.T) {
+	ctx := context.Background()
+	d, err := db.Open(filepath.Join(t.TempDir(), "pf.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if err := db.Migrate(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+	today := time.Now().Format("2006-01-02")
+	for _, tx := range []struct {
+		merchant, cat string
+		cents         int64
+	}{
+		{"EMPLOYER", "Income", 500000},
+		{"CAFE 21", "Dining", -1200},
+		{"COLES", "", -8000}, // no category
+	} {
+		if _, err := d.Exec(`INSERT INTO transactions (txn_date, amount_cents, merchant_norm, category_norm, row_hash) VALUES (?,?,?,?,hex(randomblob(8)))`,
+			today, tx.cents, tx.merchant, tx.cat); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c := New(d)
+	reg := prometheus.NewRegistry()
+	c.Register(reg)
+	if err := c.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	g := gauges(t, reg)
+	for k, want := range map[string]float64{
+		"pf_income_mtd_cents":                                      500000,
+		"pf_expense_mtd_cents":                                     9200,
+		`pf_spend_by_category_mtd_cents{category="Dining"}`:        1200,
+		`pf_spend_by_category_mtd_cents{category="Uncategorised"}`: 8000,
+		`pf_spend_by_merchant_mtd_cents{merchant="COLES"}`:         8000,
+		`pf_refresh_failed{area="budgets"}`:                        0,
+		`pf_refresh_failed{area="mtd"}`:                            0,
+	} {
+		if got, ok := g[k]; !ok || got != want {
+			t.Errorf("%s = %v (present %v), want %v", k, got, ok, want)
+		}
+	}
+
+	// one broken area doesn't stop the others
+	if _, err := d.Exec(`DROP TABLE budgets`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Exec(`INSERT INTO transactions (txn_date, amount_cents, merchant_norm, row_hash) VALUES (?, -800, 'CAFE 21', 'late')`, today); err != nil {
+		t.Fatal(err)
+	}
+	err = c.Refresh(ctx)
+	if err == nil || !strings.Contains(err.Error(), "budgets:") {
+		t.Errorf("Refresh = %v, want a budgets error", err)
+	}
+	g = gauges(t, reg)
+	for k, want := range map[string]float64{
+		`pf_refresh_failed{area="budgets"}`: 1,
+		`pf_refresh_failed{area="mtd"}`:     0,
+		`pf_refresh_failed{area="alerts"}`:  0,
+		"pf_expense_mtd_cents":              10000,
+	} {
+		if got := g[k]; got != want {
+			t.Errorf("after a failure, %s = %v, want %v", k, got, want)
+		}
+	}
+}
@@ -0,0 +1,287 @@
+// Package networth adds up account balances and manually valued assets and
+// liabilities.
+package networth
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/anthurium-ai/personal-finance/internal/db"
+)
+
+// Kinds of item, stored in assets.kind.
+var (
+	AssetKinds     = []string{"property", "vehicle", "super", "investment", "cash", "other"}
+	LiabilityKinds = []string{"loan", "mortgage", "credit", "other"}
+)
+
+// Item is a manually valued asset or liability.
+type Item struct {
+	ID        int64
+	Name      string
+	Kind      string
+	Liability bool
+}
+
+// Valuation is an item's value from Date until the next valuation. Values
+// are positive for liabilities too.
+type Valuation struct {
+	ItemID     int64
+	Date       time.Time
+	ValueCents int64
+	Note       string
+}
+
+// CreateItem stores it and returns its id.
+func CreateItem(ctx context.Context, q db.Querier, it Item) (int64, error) {
+	it.Name = strings.TrimSpace(it.Name)
+	if it.Name == "" {
+		return 0, fmt.Errorf("name is required")
+	}
+	kinds := AssetKinds
+	if it.Liability {
+		kinds = LiabilityKinds
+	}
+	known := false
+	for _, k := range kinds {
+		known = known || k == it.Kind
+	}
+	if !known {
+		return 0, fmt.Errorf("unknown kind %q", it.Kind)
+	}
+	res, err := q.ExecContext(ctx, `INSERT INTO assets (name, kind, liability) VALUES (?,?,?)`, it.Name, it.Kind, it.Liability)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+func DeleteItem(ctx context.Context, q db.Querier, id int64) error {
+	_, err := q.ExecContext(ctx, `DELETE FROM assets WHERE id=?`, id)
+	return err
+}
+
+// Items lists assets, then liabilities, by name.
+func Items(ctx context.Context, q db.Querier) ([]Item, error) {
+	rows, err := q.QueryContext(ctx, `SELECT id, name, kind, liability FROM assets ORDER BY liability, name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Item
+	for rows.Next() {
+		var it Item
+		if err := rows.Scan(&it.ID, &it.Name, &it.Kind, &it.Liability); err != nil {
+			return nil, err
+		}
+		out = append(out, it)
+	}
+	return out, rows.Err()
+}
+
+// Value records an item's value on a date, replacing any valuation that day.
+func Value(ctx context.Context, q db.Querier, v Valuation) error {
+	if v.Date.IsZero() {
+		return fmt.Errorf("date is required")
+	}
+	if v.ValueCents < 0 {
+		return fmt.Errorf("value can't be negative; liabilities are entered as what is owed")
+	}
+	_, err := q.ExecContext(ctx, `INSERT INTO asset_valuations (asset_id, valued_on, value_cents, note) VALUES (?,?,?,?)
+		ON CONFLICT(asset_id, valued_on) DO UPDATE SET value_cents=excluded.value_cents, note=excluded.note`,
+		v.ItemID, v.Date.Format("2006-01-02"), v.ValueCents, strings.TrimSpace(v.Note))
+	return err
+}
+
+func DeleteValuation(ctx context.Context, q db.Querier, itemID int64, date time.Time) error {
+	_, err := q.ExecContext(ctx, `DELETE FROM asset_valuations WHERE asset_id=? AND valued_on=?`, itemID, date.Format("2006-01-02"))
+	return err
+}
+
+// Valuations lists every valuation, newest first.
+func Valuations(ctx context.Context, q db.Querier) ([]Valuation, error) {
+	rows, err := q.QueryContext(ctx, `SELECT asset_id, valued_on, value_cents, COALESCE(note,'') FROM asset_valuations ORDER BY valued_on DESC, asset_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Valuation
+	for rows.Next() {
+		var v Valuation
+		var d string
+		if err := rows.Scan(&v.ItemID, &d, &v.ValueCents, &v.Note); err != nil {
+			return nil, err
+		}
+		v.Date, _ = time.Parse("2006-01-02", d)
+		out = append(out, v)
+	}
+	return out, rows.Err()
+}
+
+// Line is one part of net worth on a day.
+type Line struct {
+	Name       string
+	Kind       string // "account" for account balances
+	ValueCents int64  // signed: liabilities and overdrawn accounts negative
+	ItemID     int64  // 0 for accounts
+	ValuedOn   time.Time
+}
+
+// Snapshot is net worth on one day.
+type Snapshot struct {
+	Date        time.Time
+	Accounts    []Line
+	Assets      []Line
+	Liabilities []Line
+}
+
+func (s *Snapshot) total(lines []Line) int64 {
+	var t int64
+	for _, l := range lines {
+		t += l.ValueCents
+	}
+	return t
+}
+
+func (s *Snapshot) AccountsCents() int64    { return s.total(s.Accounts) }
+func (s *Snapshot) AssetsCents() int64      { return s.total(s.Assets) }
+func (s *Snapshot) LiabilitiesCents() int64 { return s.total(s.Liabilities) }
+func (s *Snapshot) NetCents() int64 {
+	return s.AccountsCents() + s.AssetsCents() + s.LiabilitiesCents()
+}
+
+// At works out net worth at the end of each day in days.
+//
+// An account's balance is its known balance (set on the forecast page)
+// moved by the transactions between then and the day; an account without
+// one is the sum of its transactions. An item is worth its latest valuation
+// on or before the day, and nothing before its first.
+func At(ctx context.Context, q db.Querier, days ...time.Time) ([]Snapshot, error) {
+	anchors := map[string]anchor{}
+	rows, err := q.QueryContext(ctx, `SELECT account, balance_cents, as_of FROM account_balances`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var acct string
+		var a anchor
+		if err := rows.Scan(&acct, &a.cents, &a.asOf); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		anchors[acct] = a
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var accounts []string
+	rows, err = q.QueryContext(ctx, `SELECT DISTINCT account FROM transactions WHERE COALESCE(account,'') != ''
+		UNION SELECT account FROM account_balances ORDER BY 1`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var acct string
+		if err := rows.Scan(&acct); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		accounts = append(accounts, acct)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	items, err := Items(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	vals, err := Valuations(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]Snapshot, 0, len(days))
+	for _, day := range days {
+		d := day.Format("2006-01-02")
+		s := Snapshot{Date: day}
+		for _, acct := range accounts {
+			a, ok := anchors[acct]
+			bal, err := balance(ctx, q, acct, a, ok, d)
+			if err != nil {
+				return nil, err
+			}
+			s.Accounts = append(s.Accounts, Line{Name: acct, Kind: "account", ValueCents: bal})
+		}
+		for _, it := range items {
+			// valuations are newest first
+			for _, v := range vals {
+				if v.ItemID != it.ID || v.Date.After(day) {
+					continue
+				}
+				l := Line{Name: it.Name, Kind: it.Kind, ValueCents: v.ValueCents, ItemID: it.ID, ValuedOn: v.Date}
+				if it.Liability {
+					l.ValueCents = -l.ValueCents
+					s.Liabilities = append(s.Liabilities, l)
+				} else {
+					s.Assets = append(s.Assets, l)
+				}
+				break
+			}
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+// anchor is an account's known balance at the end of a day.
+type anchor struct {
+	cents int64
+	asOf  string
+}
+
+// balance is acct's balance at the end of day d (YYYY-MM-DD), from its
+// anchor if it has one, otherwise from all its transactions.
+func balance(ctx context.Context, q db.Querier, acct string, a anchor, anchored bool, d string) (int64, error) {
+	var bal int64
+	var err error
+	switch {
+	case !anchored:
+		err = q.QueryRowContext(ctx, `SELECT COALESCE(SUM(amount_cents),0) FROM transactions WHERE account=? AND txn_date <= ?`, acct, d).Scan(&bal)
+	case d >= a.asOf:
+		err = q.QueryRowContext(ctx, `SELECT ? + COALESCE(SUM(amount_cents),0) FROM transactions WHERE account=? AND txn_date > ? AND txn_date <= ?`,
+			a.cents, acct, a.asOf, d).Scan(&bal)
+	default:
+		err = q.QueryRowContext(ctx, `SELECT ? - COALESCE(SUM(amount_cents),0) FROM transactions WHERE account=? AND txn_date > ? AND txn_date <= ?`,
+			a.cents, acct, d, a.asOf).Scan(&bal)
+	}
+	return bal, err
+}
+
+// Balance is one account's balance at the end of day, worked out as At does.
+func Balance(ctx context.Context, q db.Querier, account string, day time.Time) (int64, error) {
+	var a anchor
+	err := q.QueryRowContext(ctx, `SELECT balance_cents, as_of FROM account_balances WHERE account=?`, account).Scan(&a.cents, &a.asOf)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, err
+	}
+	return balance(ctx, q, account, a, err == nil, day.Format("2006-01-02"))
+}
+
+// MonthEnds returns the last day of each of the n months before today's,
+// oldest first, followed by today.
+func MonthEnds(today time.Time, n int) []time.Time {
+	today = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC)
+	first := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.UTC)
+	out := make([]time.Time, 0, n+1)
+	for i := n; i >= 1; i-- {
+		out = append(out, first.AddDate(0, -i+1, -1))
+	}
+	return append(out, today)
+}
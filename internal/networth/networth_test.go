@@ -0,0 +1,133 @@
+package networth
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/anthurium-ai/personal-finance/internal/db"
+)
+
+func date(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestMonthEnds(t *testing.T) {
+	got := MonthEnds(time.Date(2026, 3, 15, 9, 0, 0, 0, time.UTC), 3)
+	want := []time.Time{date("2025-12-31"), date("2026-01-31"), date("2026-02-28"), date("2026-03-15")}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("MonthEnds = %v, want %v", got, want)
+	}
+}
+
+func TestAt(t *testing.T) {
+	ctx := context.Background()
+	d, err := db.Open(filepath.Join(t.TempDir(), "pf.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if err := db.Migrate(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+	for _, tx := range []struct {
+		date, account string
+		cents         int64
+	}{
+		{"2026-03-15", "everyday", -5000}, // before the known balance
+		{"2026-04-05", "everyday", 20000},
+		{"2026-04-10", "everyday", -3000},
+		{"2026-03-01", "credit", -40000}, // no known balance
+		{"2026-04-02", "credit", 10000},
+	} {
+		if _, err := d.Exec(`INSERT INTO transactions (txn_date, account, amount_cents, row_hash) VALUES (?,?,?,hex(randomblob(8)))`,
+			tx.date, tx.account, tx.cents); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := d.Exec(`INSERT INTO account_balances (account, balance_cents, as_of) VALUES ('everyday', 100000, '2026-03-31')`); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, it := range []Item{
+		{Name: " ", Kind: "property"},
+		{Name: "House", Kind: "mortgage"}, // a liability kind
+	} {
+		if _, err := CreateItem(ctx, d, it); err == nil {
+			t.Errorf("CreateItem(%+v): want an error", it)
+		}
+	}
+	item := func(it Item) int64 {
+		t.Helper()
+		id, err := CreateItem(ctx, d, it)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	house := item(Item{Name: "House", Kind: "property"})
+	car := item(Item{Name: "Car", Kind: "vehicle"})
+	mortgage := item(Item{Name: "Mortgage", Kind: "mortgage", Liability: true})
+	for _, v := range []Valuation{
+		{ItemID: house, Date: date("2026-01-01"), ValueCents: 500000000},
+		{ItemID: house, Date: date("2026-04-01"), ValueCents: 510000000},
+		{ItemID: house, Date: date("2026-04-01"), ValueCents: 520000000, Note: "agent's appraisal"}, // replaces the one that day
+		{ItemID: mortgage, Date: date("2026-01-01"), ValueCents: 400000000},
+		{ItemID: car, Date: date("2026-04-05"), ValueCents: 2000000},
+	} {
+		if err := Value(ctx, d, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := Value(ctx, d, Valuation{ItemID: car, Date: date("2026-04-06"), ValueCents: -1}); err == nil {
+		t.Error("negative valuation: want an error")
+	}
+
+	snaps, err := At(ctx, d, MonthEnds(date("2026-04-30"), 2)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Snapshot{
+		{Date: date("2026-02-28"),
+			Accounts:    []Line{{Name: "credit", Kind: "account"}, {Name: "everyday", Kind: "account", ValueCents: 105000}},
+			Assets:      []Line{{Name: "House", Kind: "property", ValueCents: 500000000, ItemID: house, ValuedOn: date("2026-01-01")}},
+			Liabilities: []Line{{Name: "Mortgage", Kind: "mortgage", ValueCents: -400000000, ItemID: mortgage, ValuedOn: date("2026-01-01")}}},
+		{Date: date("2026-03-31"),
+			Accounts:    []Line{{Name: "credit", Kind: "account", ValueCents: -40000}, {Name: "everyday", Kind: "account", ValueCents: 100000}},
+			Assets:      []Line{{Name: "House", Kind: "property", ValueCents: 500000000, ItemID: house, ValuedOn: date("2026-01-01")}},
+			Liabilities: []Line{{Name: "Mortgage", Kind: "mortgage", ValueCents: -400000000, ItemID: mortgage, ValuedOn: date("2026-01-01")}}},
+		{Date: date("2026-04-30"),
+			Accounts: []Line{{Name: "credit", Kind: "account", ValueCents: -30000}, {Name: "everyday", Kind: "account", ValueCents: 117000}},
+			Assets: []Line{
+				{Name: "Car", Kind: "vehicle", ValueCents: 2000000, ItemID: car, ValuedOn: date("2026-04-05")},
+				{Name: "House", Kind: "property", ValueCents: 520000000, ItemID: house, ValuedOn: date("2026-04-01")},
+			},
+			Liabilities: []Line{{Name: "Mortgage", Kind: "mortgage", ValueCents: -400000000, ItemID: mortgage, ValuedOn: date("2026-01-01")}}},
+	}
+	if !reflect.DeepEqual(snaps, want) {
+		t.Errorf("At = %+v\nwant %+v", snaps, want)
+	}
+	if n := snaps[2].NetCents(); n != 117000-30000+522000000-400000000 {
+		t.Errorf("net worth on 04-30 = %d", n)
+	}
+
+	for _, c := range []struct {
+		account, day string
+		want         int64
+	}{
+		{"everyday", "2026-02-28", 105000},
+		{"everyday", "2026-04-30", 117000},
+		{"credit", "2026-04-30", -30000},
+		{"savings", "2026-04-30", 0},
+	} {
+		if got, err := Balance(ctx, d, c.account, date(c.day)); err != nil || got != c.want {
+			t.Errorf("Balance(%s, %s) = %d (%v), want %d", c.account, c.day, got, err, c.want)
+		}
+	}
+}
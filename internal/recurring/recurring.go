@@ -0,0 +1,270 @@
+// Package recurring finds subscriptions and other regular charges: the same
+// merchant at a steady cadence with a stable amount. Regular income is found
+// the same way.
+package recurring
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/anthurium-ai/personal-finance/internal/db"
+)
+
+// Cadence is how often a series repeats.
+type Cadence struct {
+	Name    string
+	MinGap  int // days between charges, inclusive
+	MaxGap  int
+	PerYear float64
+	Grace   int // days past the expected date before a charge counts as missed
+	Minimum int // charges needed to call it a series
+
+	days   int // fixed step, 0 for calendar months
+	months int
+}
+
+var Cadences = []Cadence{
+	{Name: "weekly", MinGap: 6, MaxGap: 8, PerYear: 52, Grace: 3, Minimum: 4, days: 7},
+	{Name: "fortnightly", MinGap: 13, MaxGap: 16, PerYear: 26, Grace: 4, Minimum: 4, days: 14},
+	{Name: "monthly", MinGap: 27, MaxGap: 33, PerYear: 12, Grace: 7, Minimum: 3, months: 1},
+	{Name: "quarterly", MinGap: 85, MaxGap: 97, PerYear: 4, Grace: 14, Minimum: 3, months: 3},
+	{Name: "annual", MinGap: 350, MaxGap: 380, PerYear: 1, Grace: 30, Minimum: 2, months: 12},
+}
+
+// Next is the date one cadence step after t. Calendar cadences keep the day
+// of month, clamped to short months.
+func (c Cadence) Next(t time.Time) time.Time {
+	if c.days > 0 {
+		return t.AddDate(0, 0, c.days)
+	}
+	y, m, d := t.Date()
+	first := time.Date(y, m+time.Month(c.months), 1, 0, 0, 0, 0, time.UTC)
+	if last := first.AddDate(0, 1, -1).Day(); d > last {
+		d = last
+	}
+	return first.AddDate(0, 0, d-1)
+}
+
+// Charge is one transaction in a series.
+type Charge struct {
+	TxID        int64
+	Date        time.Time
+	AmountCents int64 // spend (or income), positive
+	Account     string
+}
+
+// PriceChange is a lasting change in the charged amount.
+type PriceChange struct {
+	Date      time.Time
+	FromCents int64
+	ToCents   int64
+}
+
+type Series struct {
+	Merchant string
+	Category string // of the latest charge
+	Account  string // of the latest charge
+	Income   bool
+	Cadence  Cadence
+	Charges  []Charge // oldest first
+
+	TypicalCents int64 // the current price
+	NextExpected time.Time
+	PriceChanges []PriceChange
+
+	Missed  bool // the next charge is overdue
+	Changed bool // the latest charge moved to a new price
+	Ended   bool // overdue by more than two cycles: probably cancelled
+}
+
+func (s *Series) First() time.Time { return s.Charges[0].Date }
+func (s *Series) Last() time.Time  { return s.Charges[len(s.Charges)-1].Date }
+
+// AnnualCents is the typical amount at the series' cadence over a year.
+func (s *Series) AnnualCents() int64 {
+	return int64(float64(s.TypicalCents) * s.Cadence.PerYear)
+}
+
+// priceTolerance is how far apart two amounts can be and still be the same
+// price (rounding, currency conversion on overseas subscriptions).
+const priceTolerance = 0.02
+
+// Detect finds recurring series in spend transactions, as of asOf.
+// Ended series are included, flagged.
+func Detect(ctx context.Context, q db.Querier, asOf time.Time) ([]*Series, error) {
+	return detectAll(ctx, q, asOf, false)
+}
+
+// DetectIncome is Detect for money coming in: pay, rent received and the like.
+func DetectIncome(ctx context.Context, q db.Querier, asOf time.Time) ([]*Series, error) {
+	return detectAll(ctx, q, asOf, true)
+}
+
+func detectAll(ctx context.Context, q db.Querier, asOf time.Time, income bool) ([]*Series, error) {
+	sign := -1
+	if income {
+		sign = 1
+	}
+	rows, err := q.QueryContext(ctx, `
+		SELECT id, txn_date, ? * amount_cents, merchant_norm, `+db.CategorySQL("")+`, COALESCE(account,'')
+		FROM transactions
+		WHERE ? * amount_cents > 0 AND COALESCE(merchant_norm,'') != ''
+		ORDER BY merchant_norm, txn_date, id`, sign, sign)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byMerchant := map[string][]Charge{}
+	category := map[string]string{}
+	var merchants []string
+	for rows.Next() {
+		var c Charge
+		var date, mer, cat string
+		if err := rows.Scan(&c.TxID, &date, &c.AmountCents, &mer, &cat, &c.Account); err != nil {
+			return nil, err
+		}
+		if c.Date, err = time.Parse("2006-01-02", date); err != nil {
+			continue
+		}
+		if _, ok := byMerchant[mer]; !ok {
+			merchants = append(merchants, mer)
+		}
+		byMerchant[mer] = append(byMerchant[mer], c)
+		category[mer] = cat
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var out []*Series
+	for _, mer := range merchants {
+		charges := byMerchant[mer]
+		// the merchant as a whole first; failing that, one series per price
+		// band, e.g. two plans billed by the same company
+		if s := detect(mer, charges, asOf); s != nil {
+			s.Category, s.Income = category[mer], income
+			out = append(out, s)
+			continue
+		}
+		for _, band := range priceBands(charges) {
+			if s := detect(mer, band, asOf); s != nil {
+				s.Category, s.Income = category[mer], income
+				out = append(out, s)
+			}
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].AnnualCents() > out[j].AnnualCents() })
+	return out, nil
+}
+
+// detect returns the series charges form, or nil if they aren't one.
+func detect(merchant string, charges []Charge, asOf time.Time) *Series {
+	charges = dedupeDays(charges)
+	if len(charges) < 2 {
+		return nil
+	}
+	gaps := make([]int, 0, len(charges)-1)
+	for i := 1; i < len(charges); i++ {
+		gaps = append(gaps, int(charges[i].Date.Sub(charges[i-1].Date).Hours()/24))
+	}
+	med := median(gaps)
+
+	for _, c := range Cadences {
+		if med < c.MinGap || med > c.MaxGap || len(charges) < c.Minimum {
+			continue
+		}
+		// allow the odd late or skipped charge
+		fit := 0
+		for _, g := range gaps {
+			if g >= c.MinGap && g <= c.MaxGap {
+				fit++
+			}
+		}
+		if float64(fit) < 0.75*float64(len(gaps)) {
+			return nil
+		}
+		s := &Series{Merchant: merchant, Cadence: c, Charges: charges, Account: charges[len(charges)-1].Account}
+		s.priceHistory()
+		// groceries at the same store every week are not a subscription;
+		// a price rise now and then is fine
+		if len(s.PriceChanges) > max(1, (len(charges)-1)/3) {
+			return nil
+		}
+		s.NextExpected = c.Next(s.Last())
+		overdue := int(asOf.Sub(s.NextExpected).Hours() / 24)
+		s.Missed = overdue > c.Grace
+		s.Ended = asOf.After(c.Next(c.Next(s.NextExpected)).AddDate(0, 0, c.Grace))
+		return s
+	}
+	return nil
+}
+
+// priceHistory finds lasting price changes, ignoring one-off blips, and
+// sets the typical (current) amount.
+func (s *Series) priceHistory() {
+	level := s.Charges[0].AmountCents
+	for i := 1; i < len(s.Charges); i++ {
+		a := s.Charges[i].AmountCents
+		if samePrice(a, level) {
+			continue
+		}
+		if i == len(s.Charges)-1 || samePrice(s.Charges[i+1].AmountCents, a) {
+			s.PriceChanges = append(s.PriceChanges, PriceChange{Date: s.Charges[i].Date, FromCents: level, ToCents: a})
+			level = a
+		}
+	}
+	s.TypicalCents = level
+	if n := len(s.PriceChanges); n > 0 && s.PriceChanges[n-1].Date.Equal(s.Last()) {
+		s.Changed = true
+	}
+}
+
+func samePrice(a, b int64) bool {
+	d := a - b
+	if d < 0 {
+		d = -d
+	}
+	return float64(d) <= priceTolerance*float64(max(a, b))
+}
+
+// priceBands splits charges into groups of similar amounts (within 15%),
+// each kept in date order.
+func priceBands(charges []Charge) [][]Charge {
+	sorted := append([]Charge(nil), charges...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].AmountCents < sorted[j].AmountCents })
+	var bands [][]Charge
+	var lo int64
+	for _, c := range sorted {
+		if len(bands) == 0 || float64(c.AmountCents) > 1.15*float64(lo) {
+			bands = append(bands, nil)
+			lo = c.AmountCents
+		}
+		bands[len(bands)-1] = append(bands[len(bands)-1], c)
+	}
+	for _, b := range bands {
+		sort.SliceStable(b, func(i, j int) bool { return b[i].Date.Before(b[j].Date) })
+	}
+	return bands
+}
+
+// dedupeDays keeps one charge per day, so a refund-and-recharge doesn't
+// read as a zero-day cadence.
+func dedupeDays(charges []Charge) []Charge {
+	out := make([]Charge, 0, len(charges))
+	for _, c := range charges {
+		if n := len(out); n > 0 && out[n-1].Date.Equal(c.Date) {
+			out[n-1] = c
+			continue
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+func median(xs []int) int {
+	s := append([]int(nil), xs...)
+	sort.Ints(s)
+	return s[len(s)/2]
+}
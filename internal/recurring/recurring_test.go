@@ -0,0 +1,132 @@
+package recurring
+
+import (
+	"testing"
+	"time"
+)
+
+func day(s string) time.Time {
+	d, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// charges makes one charge per date, all of amount unless amounts has one
+// for that position.
+func charges(amount int64, dates []string, amounts ...int64) []Charge {
+	var out []Charge
+	for i, d := range dates {
+		a := amount
+		if i < len(amounts) && amounts[i] != 0 {
+			a = amounts[i]
+		}
+		out = append(out, Charge{TxID: int64(i + 1), Date: day(d), AmountCents: a, Account: "card"})
+	}
+	return out
+}
+
+func TestDetect(t *testing.T) {
+	monthly := []string{"2026-01-05", "2026-02-05", "2026-03-05", "2026-04-05", "2026-05-05"}
+	tests := []struct {
+		name    string
+		charges []Charge
+		asOf    string
+		cadence string // "" when it isn't a series
+		next    string
+		missed  bool
+		ended   bool
+	}{
+		{name: "monthly", charges: charges(1599, monthly), asOf: "2026-05-20", cadence: "monthly", next: "2026-06-05"},
+		{name: "monthly, overdue", charges: charges(1599, monthly), asOf: "2026-06-15", cadence: "monthly", next: "2026-06-05", missed: true},
+		{name: "monthly, cancelled", charges: charges(1599, monthly), asOf: "2026-08-20", cadence: "monthly", next: "2026-06-05", missed: true, ended: true},
+		{
+			name:    "weekly, one late",
+			charges: charges(2500, []string{"2026-04-01", "2026-04-08", "2026-04-15", "2026-04-23", "2026-04-29"}),
+			asOf:    "2026-05-01", cadence: "weekly", next: "2026-05-06",
+		},
+		{
+			name:    "annual",
+			charges: charges(9900, []string{"2024-03-01", "2025-03-01", "2026-03-01"}),
+			asOf:    "2026-04-01", cadence: "annual", next: "2027-03-01",
+		},
+		{
+			name:    "same-day refund and recharge",
+			charges: charges(1599, []string{"2026-01-05", "2026-02-05", "2026-02-05", "2026-03-05"}),
+			asOf:    "2026-03-10", cadence: "monthly", next: "2026-04-05",
+		},
+		{name: "too few", charges: charges(1599, monthly[:2]), asOf: "2026-02-10"},
+		{
+			name:    "irregular",
+			charges: charges(1599, []string{"2026-01-05", "2026-01-09", "2026-02-20", "2026-02-27", "2026-04-30"}),
+			asOf:    "2026-05-01",
+		},
+		{
+			name: "weekly shop that keeps changing",
+			charges: charges(0, []string{"2026-03-04", "2026-03-11", "2026-03-18", "2026-03-25", "2026-04-01", "2026-04-08", "2026-04-15", "2026-04-22"},
+				8000, 8000, 12000, 12000, 9000, 9000, 14000, 14000),
+			asOf: "2026-04-25",
+		},
+	}
+	for _, tt := range tests {
+		s := detect("NETFLIX", tt.charges, day(tt.asOf))
+		if tt.cadence == "" {
+			if s != nil {
+				t.Errorf("%s: detected %s series, want none", tt.name, s.Cadence.Name)
+			}
+			continue
+		}
+		if s == nil {
+			t.Errorf("%s: no series, want %s", tt.name, tt.cadence)
+			continue
+		}
+		if s.Cadence.Name != tt.cadence || s.NextExpected.Format("2006-01-02") != tt.next || s.Missed != tt.missed || s.Ended != tt.ended {
+			t.Errorf("%s: got %s next %s missed %v ended %v, want %s next %s missed %v ended %v", tt.name,
+				s.Cadence.Name, s.NextExpected.Format("2006-01-02"), s.Missed, s.Ended, tt.cadence, tt.next, tt.missed, tt.ended)
+		}
+	}
+}
+
+func TestPriceHistory(t *testing.T) {
+	dates := []string{"2026-01-05", "2026-02-05", "2026-03-05", "2026-04-05", "2026-05-05"}
+	tests := []struct {
+		name    string
+		amounts []int64
+		typical int64
+		changes []PriceChange
+		changed bool
+	}{
+		{name: "steady", amounts: []int64{1599, 1599, 1599, 1599, 1599}, typical: 1599},
+		{name: "within rounding", amounts: []int64{1000, 1010, 995, 1005, 1000}, typical: 1000},
+		{
+			name: "price rise", amounts: []int64{1599, 1599, 1899, 1899, 1899}, typical: 1899,
+			changes: []PriceChange{{Date: day("2026-03-05"), FromCents: 1599, ToCents: 1899}},
+		},
+		{name: "one-off blip", amounts: []int64{1599, 1599, 2999, 1599, 1599}, typical: 1599},
+		{
+			name: "latest charge is a new price", amounts: []int64{1599, 1599, 1599, 1599, 1899}, typical: 1899,
+			changes: []PriceChange{{Date: day("2026-05-05"), FromCents: 1599, ToCents: 1899}}, changed: true,
+		},
+		{
+			name: "up then down", amounts: []int64{1000, 1200, 1200, 1100, 1100}, typical: 1100,
+			changes: []PriceChange{
+				{Date: day("2026-02-05"), FromCents: 1000, ToCents: 1200},
+				{Date: day("2026-04-05"), FromCents: 1200, ToCents: 1100},
+			},
+		},
+	}
+	for _, tt := range tests {
+		s := &Series{Charges: charges(0, dates, tt.amounts...)}
+		s.priceHistory()
+		if s.TypicalCents != tt.typical || s.Changed != tt.changed || len(s.PriceChanges) != len(tt.changes) {
+			t.Errorf("%s: typical %d changed %v with %d changes, want %d %v %d", tt.name, s.TypicalCents, s.Changed, len(s.PriceChanges), tt.typical, tt.changed, len(tt.changes))
+			continue
+		}
+		for i, c := range s.PriceChanges {
+			if !c.Date.Equal(tt.changes[i].Date) || c.FromCents != tt.changes[i].FromCents || c.ToCents != tt.changes[i].ToCents {
+				t.Errorf("%s: change %d is %+v, want %+v", tt.name, i, c, tt.changes[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,256 @@
+// Package search parses the transaction search box into SQL over the
+// transactions table and its full-text index.
+//
+// A query is words, "quoted phrases" and field:value terms, all of which
+// must match:
+//
+//	uber                  any indexed text starting with uber
+//	merchant:uber         the merchant (raw or normalised)
+//	category:travel       the category
+//	details:, notes:      those columns
+//	account:1234          the account, exactly
+//	amount:>50            the amount, either way; also <, >=, <=, 50, 10..50
+//	date:2026-03          a day, month or year; also >, <, >=, <= and a..b
+package search
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Hits are wrapped in these by Highlight.
+const (
+	HitStart = "\x02"
+	HitEnd   = "\x03"
+)
+
+// text fields and the indexed columns they search
+var textFields = map[string][]string{
+	"merchant": {"merchant_raw", "merchant_norm"},
+	"category": {"category_norm", "category_raw"},
+	"details":  {"details"},
+	"notes":    {"notes"},
+}
+
+// columns is the order of the index's columns.
+var columns = []string{"details", "merchant_raw", "merchant_norm", "notes", "category_norm", "category_raw"}
+
+// Query is a parsed search.
+type Query struct {
+	Match string   // FTS5 expression; empty when there are no text terms
+	Where []string // conditions on transactions aliased t
+	Args  []any    // for Where
+}
+
+// From is the FROM clause for the query: transactions t, joined to the
+// index when there is text to match. Its argument comes before Args.
+func (q *Query) From() (string, []any) {
+	if q.Match == "" {
+		return "transactions t", nil
+	}
+	return "transactions t JOIN transactions_fts ON transactions_fts.rowid = t.id AND transactions_fts MATCH ?", []any{q.Match}
+}
+
+// Highlight selects column of the index with hits marked, or the plain
+// column when the query has no text. It is only valid with From.
+func (q *Query) Highlight(column string) string {
+	for i, c := range columns {
+		if c == column && q.Match != "" {
+			return fmt.Sprintf(`COALESCE(highlight(transactions_fts, %d, char(2), char(3)),'')`, i)
+		}
+	}
+	return "COALESCE(t." + column + ",'')"
+}
+
+// Parse reads a search box.
+func Parse(s string) (*Query, error) {
+	terms, err := split(s)
+	if err != nil {
+		return nil, err
+	}
+	q := &Query{}
+	var match []string
+	for _, t := range terms {
+		field, value, ok := strings.Cut(t.text, ":")
+		if !ok || t.quoted {
+			if m := phrase(t.text); m != "" {
+				match = append(match, m)
+			}
+			continue
+		}
+		field = strings.ToLower(field)
+		if value == "" {
+			return nil, fmt.Errorf("%s: needs a value", field)
+		}
+		switch field {
+		case "amount":
+			if err := q.amount(value); err != nil {
+				return nil, err
+			}
+		case "date":
+			if err := q.date(value); err != nil {
+				return nil, err
+			}
+		case "account":
+			q.Where = append(q.Where, "t.account = ?")
+			q.Args = append(q.Args, value)
+		default:
+			cols, ok := textFields[field]
+			if !ok {
+				return nil, fmt.Errorf("unknown field %q (try merchant, category, details, notes, account, amount or date)", field)
+			}
+			if m := phrase(value); m != "" {
+				match = append(match, "{"+strings.Join(cols, " ")+"} : "+m)
+			}
+		}
+	}
+	q.Match = strings.Join(match, " AND ")
+	return q, nil
+}
+
+type term struct {
+	text   string
+	quoted bool // a "phrase" on its own, never a field
+}
+
+// split breaks s on spaces, keeping "quoted phrases" and field:"quoted
+// values" together.
+func split(s string) ([]term, error) {
+	var out []term
+	var b strings.Builder
+	quoted, inQuote := false, false
+	flush := func() {
+		if b.Len() > 0 || quoted {
+			out = append(out, term{b.String(), quoted})
+		}
+		b.Reset()
+		quoted = false
+	}
+	for _, r := range s {
+		switch {
+		case r == '"':
+			if !inQuote && b.Len() == 0 {
+				quoted = true
+			}
+			inQuote = !inQuote
+		case unicode.IsSpace(r) && !inQuote:
+			flush()
+		default:
+			b.WriteRune(r)
+		}
+	}
+	if inQuote {
+		return nil, fmt.Errorf("unclosed quote")
+	}
+	flush()
+	return out, nil
+}
+
+// phrase is text as an FTS5 prefix phrase; empty when it has nothing the
+// index would hold.
+func phrase(text string) string {
+	if strings.IndexFunc(text, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsNumber(r) }) < 0 {
+		return ""
+	}
+	return `"` + strings.ReplaceAll(text, `"`, `""`) + `"*`
+}
+
+// cmp cuts a comparison off the front of v.
+func cmp(v string) (op, rest string) {
+	for _, o := range []string{">=", "<=", ">", "<", "="} {
+		if strings.HasPrefix(v, o) {
+			return o, v[len(o):]
+		}
+	}
+	return "", v
+}
+
+func (q *Query) amount(v string) error {
+	cents := func(s string) (int64, error) {
+		f, err := strconv.ParseFloat(strings.NewReplacer("$", "", ",", "").Replace(s), 64)
+		if err != nil || f < 0 {
+			return 0, fmt.Errorf("amount: %q isn't an amount", s)
+		}
+		return int64(math.Round(f * 100)), nil
+	}
+	if lo, hi, ok := strings.Cut(v, ".."); ok {
+		a, err := cents(lo)
+		if err != nil {
+			return err
+		}
+		b, err := cents(hi)
+		if err != nil {
+			return err
+		}
+		q.Where = append(q.Where, "ABS(t.amount_cents) BETWEEN ? AND ?")
+		q.Args = append(q.Args, a, b)
+		return nil
+	}
+	op, rest := cmp(v)
+	c, err := cents(rest)
+	if err != nil {
+		return err
+	}
+	if op == "" {
+		op = "="
+	}
+	q.Where = append(q.Where, "ABS(t.amount_cents) "+op+" ?")
+	q.Args = append(q.Args, c)
+	return nil
+}
+
+// period reads a day, month or year as [start, end).
+func period(s string) (string, string, error) {
+	for _, p := range []struct {
+		layout string
+		next   func(time.Time) time.Time
+	}{
+		{"2006-01-02", func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }},
+		{"2006-01", func(t time.Time) time.Time { return t.AddDate(0, 1, 0) }},
+		{"2006", func(t time.Time) time.Time { return t.AddDate(1, 0, 0) }},
+	} {
+		if t, err := time.Parse(p.layout, s); err == nil {
+			return t.Format("2006-01-02"), p.next(t).Format("2006-01-02"), nil
+		}
+	}
+	return "", "", fmt.Errorf("date: %q isn't a date, month or year like 2026-03-15, 2026-03 or 2026", s)
+}
+
+func (q *Query) date(v string) error {
+	if lo, hi, ok := strings.Cut(v, ".."); ok {
+		from, _, err := period(lo)
+		if err != nil {
+			return err
+		}
+		_, to, err := period(hi)
+		if err != nil {
+			return err
+		}
+		q.Where = append(q.Where, "t.txn_date >= ? AND t.txn_date < ?")
+		q.Args = append(q.Args, from, to)
+		return nil
+	}
+	op, rest := cmp(v)
+	start, end, err := period(rest)
+	if err != nil {
+		return err
+	}
+	// comparisons are against the whole period: >2026-03 is April onwards
+	switch op {
+	case ">":
+		q.Where, q.Args = append(q.Where, "t.txn_date >= ?"), append(q.Args, end)
+	case ">=":
+		q.Where, q.Args = append(q.Where, "t.txn_date >= ?"), append(q.Args, start)
+	case "<":
+		q.Where, q.Args = append(q.Where, "t.txn_date < ?"), append(q.Args, start)
+	case "<=":
+		q.Where, q.Args = append(q.Where, "t.txn_date < ?"), append(q.Args, end)
+	default:
+		q.Where, q.Args = append(q.Where, "t.txn_date >= ? AND t.txn_date < ?"), append(q.Args, start, end)
+	}
+	return nil
+}
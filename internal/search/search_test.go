@@ -0,0 +1,99 @@
+package search
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in    string
+		match string
+		where []string
+		args  []any
+	}{
+		{in: "", match: ""},
+		{in: "uber", match: `"uber"*`},
+		{in: "uber eats", match: `"uber"* AND "eats"*`},
+		{in: `"uber eats"`, match: `"uber eats"*`},
+		{in: `"merchant:uber"`, match: `"merchant:uber"*`},
+		{in: "merchant:uber", match: `{merchant_raw merchant_norm} : "uber"*`},
+		{in: `Category:"eating out"`, match: `{category_norm category_raw} : "eating out"*`},
+		{in: "notes:gift details:ref", match: `{notes} : "gift"* AND {details} : "ref"*`},
+		{in: `say "hi"`, match: `"say"* AND "hi"*`},
+		{in: "- *", match: ""},
+		{in: "account:1234", where: []string{"t.account = ?"}, args: []any{"1234"}},
+		{in: "amount:50", where: []string{"ABS(t.amount_cents) = ?"}, args: []any{int64(5000)}},
+		{in: "amount:>$1,200.50", where: []string{"ABS(t.amount_cents) > ?"}, args: []any{int64(120050)}},
+		{in: "amount:<=9.99", where: []string{"ABS(t.amount_cents) <= ?"}, args: []any{int64(999)}},
+		{in: "amount:10..50", where: []string{"ABS(t.amount_cents) BETWEEN ? AND ?"}, args: []any{int64(1000), int64(5000)}},
+		{in: "date:2026-03-15", where: []string{"t.txn_date >= ? AND t.txn_date < ?"}, args: []any{"2026-03-15", "2026-03-16"}},
+		{in: "date:2026-12", where: []string{"t.txn_date >= ? AND t.txn_date < ?"}, args: []any{"2026-12-01", "2027-01-01"}},
+		{in: "date:2026", where: []string{"t.txn_date >= ? AND t.txn_date < ?"}, args: []any{"2026-01-01", "2027-01-01"}},
+		{in: "date:>2026-03", where: []string{"t.txn_date >= ?"}, args: []any{"2026-04-01"}},
+		{in: "date:>=2026-03", where: []string{"t.txn_date >= ?"}, args: []any{"2026-03-01"}},
+		{in: "date:<2026-03", where: []string{"t.txn_date < ?"}, args: []any{"2026-03-01"}},
+		{in: "date:<=2026-03", where: []string{"t.txn_date < ?"}, args: []any{"2026-04-01"}},
+		{in: "date:2026-01..2026-03", where: []string{"t.txn_date >= ? AND t.txn_date < ?"}, args: []any{"2026-01-01", "2026-04-01"}},
+		{
+			in:    "uber amount:>20 date:2026",
+			match: `"uber"*`,
+			where: []string{"ABS(t.amount_cents) > ?", "t.txn_date >= ? AND t.txn_date < ?"},
+			args:  []any{int64(2000), "2026-01-01", "2027-01-01"},
+		},
+	}
+	for _, tt := range tests {
+		q, err := Parse(tt.in)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.in, err)
+			continue
+		}
+		if q.Match != tt.match {
+			t.Errorf("Parse(%q).Match = %s, want %s", tt.in, q.Match, tt.match)
+		}
+		if !reflect.DeepEqual(q.Where, tt.where) || !reflect.DeepEqual(q.Args, tt.args) {
+			t.Errorf("Parse(%q) = %q %v, want %q %v", tt.in, q.Where, q.Args, tt.where, tt.args)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		in, err string
+	}{
+		{`"uber`, "unclosed quote"},
+		{"merchant:", "needs a value"},
+		{"vendor:uber", "unknown field"},
+		{"amount:lots", "isn't an amount"},
+		{"amount:-5", "isn't an amount"},
+		{"amount:10..", "isn't an amount"},
+		{"date:yesterday", "isn't a date"},
+		{"date:2026-13", "isn't a date"},
+		{"date:2026..soon", "isn't a date"},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.in)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("Parse(%q) error = %v, want %q", tt.in, err, tt.err)
+		}
+	}
+}
+
+func TestFromAndHighlight(t *testing.T) {
+	q, _ := Parse("amount:5")
+	if from, args := q.From(); from != "transactions t" || args != nil {
+		t.Errorf("From() without text = %q %v", from, args)
+	}
+	if got := q.Highlight("details"); got != "COALESCE(t.details,'')" {
+		t.Errorf("Highlight without text = %q", got)
+	}
+
+	q, _ = Parse("uber")
+	if from, args := q.From(); !strings.Contains(from, "transactions_fts MATCH ?") || !reflect.DeepEqual(args, []any{`"uber"*`}) {
+		t.Errorf("From() with text = %q %v", from, args)
+	}
+	if got := q.Highlight("merchant_norm"); !strings.Contains(got, "highlight(transactions_fts, 2,") {
+		t.Errorf("Highlight(merchant_norm) = %q", got)
+	}
+}
@@ -0,0 +1,283 @@
+// Package tax reports on Australian financial years (1 July to 30 June):
+// the tax-relevant transactions and their totals, for an accountant.
+package tax
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anthurium-ai/personal-finance/internal/db"
+)
+
+// Year is a financial year, named by the calendar year it ends in:
+// Year(2026) runs from 1 July 2025 to 30 June 2026.
+type Year int
+
+// YearOf is the financial year t falls in.
+func YearOf(t time.Time) Year {
+	if t.Month() >= time.July {
+		return Year(t.Year() + 1)
+	}
+	return Year(t.Year())
+}
+
+// ParseYear reads "2025-26" or "2026".
+func ParseYear(s string) (Year, error) {
+	s = strings.TrimSpace(s)
+	if start, end, ok := strings.Cut(s, "-"); ok {
+		y, err := strconv.Atoi(start)
+		if err != nil || len(end) != 2 || end != fmt.Sprintf("%02d", (y+1)%100) {
+			return 0, fmt.Errorf("financial year must be like 2025-26")
+		}
+		return Year(y + 1), nil
+	}
+	y, err := strconv.Atoi(s)
+	if err != nil || y < 1900 {
+		return 0, fmt.Errorf("financial year must be like 2025-26")
+	}
+	return Year(y), nil
+}
+
+func (y Year) String() string { return fmt.Sprintf("%d-%02d", int(y)-1, int(y)%100) }
+
+// Start is 1 July; End is the 1 July after, exclusive.
+func (y Year) Start() time.Time { return time.Date(int(y)-1, time.July, 1, 0, 0, 0, 0, time.UTC) }
+func (y Year) End() time.Time   { return time.Date(int(y), time.July, 1, 0, 0, 0, 0, time.UTC) }
+
+// Category is a tax-relevant grouping, separate from spending categories.
+type Category struct {
+	Key   string
+	Label string
+}
+
+var Categories = []Category{
+	{"work", "Work-related expenses"},
+	{"donations", "Gifts and donations"},
+	{"income-protection", "Income protection insurance"},
+	{"rental", "Rental property"},
+	{"tax-affairs", "Cost of managing tax affairs"},
+	{"other", "Other deductions"},
+}
+
+// Label is key's label; key itself if it isn't known.
+func Label(key string) string {
+	for _, c := range Categories {
+		if c.Key == key {
+			return c.Label
+		}
+	}
+	return key
+}
+
+func known(key string) bool {
+	for _, c := range Categories {
+		if c.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// NotDeductible flags a transaction out of the report even though its
+// category is mapped.
+const NotDeductible = "none"
+
+// MapCategory makes every transaction in a spending category count towards
+// a tax category; an empty taxCategory removes the mapping.
+func MapCategory(ctx context.Context, q db.Querier, category, taxCategory string) error {
+	category = strings.TrimSpace(category)
+	if category == "" {
+		return fmt.Errorf("category is required")
+	}
+	if taxCategory == "" {
+		_, err := q.ExecContext(ctx, `DELETE FROM tax_category_map WHERE category_norm=?`, category)
+		return err
+	}
+	if !known(taxCategory) {
+		return fmt.Errorf("unknown tax category %q", taxCategory)
+	}
+	_, err := q.ExecContext(ctx, `INSERT INTO tax_category_map (category_norm, tax_category) VALUES (?,?)
+		ON CONFLICT(category_norm) DO UPDATE SET tax_category=excluded.tax_category`, category, taxCategory)
+	return err
+}
+
+// Mappings returns spending category -> tax category.
+func Mappings(ctx context.Context, q db.Querier) (map[string]string, error) {
+	rows, err := q.QueryContext(ctx, `SELECT category_norm, tax_category FROM tax_category_map`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := map[string]string{}
+	for rows.Next() {
+		var c, t string
+		if err := rows.Scan(&c, &t); err != nil {
+			return nil, err
+		}
+		out[c] = t
+	}
+	return out, rows.Err()
+}
+
+// Flag is a transaction's own tax details, overriding its category's
+// mapping.
+type Flag struct {
+	TxID        int64
+	TaxCategory string // a Categories key, or NotDeductible
+	GSTCents    int64
+	HasGST      bool // whether the GST component is known
+	Receipt     string
+	Note        string
+}
+
+// SetFlag stores f, replacing the transaction's previous flag.
+func SetFlag(ctx context.Context, q db.Querier, f Flag) error {
+	if f.TaxCategory != NotDeductible && !known(f.TaxCategory) {
+		return fmt.Errorf("unknown tax category %q", f.TaxCategory)
+	}
+	if f.GSTCents < 0 {
+		return fmt.Errorf("GST can't be negative")
+	}
+	var gst sql.NullInt64
+	if f.HasGST {
+		gst = sql.NullInt64{Int64: f.GSTCents, Valid: true}
+	}
+	_, err := q.ExecContext(ctx, `INSERT INTO tax_flags (tx_id, tax_category, gst_cents, receipt, note) VALUES (?,?,?,?,?)
+		ON CONFLICT(tx_id) DO UPDATE SET tax_category=excluded.tax_category, gst_cents=excluded.gst_cents, receipt=excluded.receipt,
+			note=excluded.note, updated_at=strftime('%Y-%m-%dT%H:%M:%fZ','now')`,
+		f.TxID, f.TaxCategory, gst, strings.TrimSpace(f.Receipt), strings.TrimSpace(f.Note))
+	return err
+}
+
+// ClearFlag puts a transaction back to following its category.
+func ClearFlag(ctx context.Context, q db.Querier, txID int64) error {
+	_, err := q.ExecContext(ctx, `DELETE FROM tax_flags WHERE tx_id=?`, txID)
+	return err
+}
+
+// GetFlag returns a transaction's flag; nil if it has none.
+func GetFlag(ctx context.Context, q db.Querier, txID int64) (*Flag, error) {
+	f := Flag{TxID: txID}
+	var gst sql.NullInt64
+	err := q.QueryRowContext(ctx, `SELECT tax_category, gst_cents, COALESCE(receipt,''), COALESCE(note,'') FROM tax_flags WHERE tx_id=?`, txID).
+		Scan(&f.TaxCategory, &gst, &f.Receipt, &f.Note)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	f.GSTCents, f.HasGST = gst.Int64, gst.Valid
+	return &f, nil
+}
+
+// Item is one tax-relevant transaction.
+type Item struct {
+	TxID        int64
+	Date        time.Time
+	AmountCents int64 // signed: rental income is positive
+	Merchant    string
+	Details     string
+	Category    string
+	TaxCategory string
+	Flagged     bool // by its own flag rather than its category
+	GSTCents    int64
+	HasGST      bool
+	Receipt     string
+	Note        string // the flag's note, else the transaction's notes
+	Attachments int    // files attached to the transaction
+}
+
+// Total is one tax category over the year.
+type Total struct {
+	Category
+	Count       int
+	AmountCents int64 // signed; deductions are negative
+	GSTCents    int64
+	Unattached  int // items without an attached receipt
+}
+
+// Report is a financial year's tax-relevant transactions.
+type Report struct {
+	Year         Year
+	Totals       []Total // in Categories order, only those with items
+	Items        []Item  // by tax category, then date
+	IncomeCents  int64   // every transaction in the year
+	ExpenseCents int64
+}
+
+// categorySQL is t's category as the spend metrics see it.
+var categorySQL = db.CategorySQL("t")
+
+// RelevantSQL is true for the transaction aliased t when it would be in a
+// report: the same rule as Build, for filtering elsewhere.
+var RelevantSQL = `COALESCE((SELECT tf.tax_category FROM tax_flags tf WHERE tf.tx_id = t.id),
+	(SELECT tm.tax_category FROM tax_category_map tm WHERE tm.category_norm = ` + categorySQL + `), '` + NotDeductible + `') != '` + NotDeductible + `'`
+
+// Build reports on y. A transaction counts if it is flagged into a tax
+// category, or its spending category is mapped to one and it isn't flagged
+// out.
+func Build(ctx context.Context, q db.Querier, y Year) (*Report, error) {
+	r := &Report{Year: y}
+	from, to := y.Start().Format("2006-01-02"), y.End().Format("2006-01-02")
+	if err := q.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(CASE WHEN amount_cents > 0 THEN amount_cents ELSE 0 END),0),
+		       COALESCE(SUM(CASE WHEN amount_cents < 0 THEN -amount_cents ELSE 0 END),0)
+		FROM transactions WHERE txn_date >= ? AND txn_date < ?`, from, to).Scan(&r.IncomeCents, &r.ExpenseCents); err != nil {
+		return nil, err
+	}
+
+	rows, err := q.QueryContext(ctx, `
+		SELECT t.id, t.txn_date, t.amount_cents,
+		       COALESCE(NULLIF(t.merchant_norm,''), COALESCE(t.merchant_raw,'')), COALESCE(t.details,''), `+categorySQL+`,
+		       COALESCE(f.tax_category, m.tax_category), f.tx_id IS NOT NULL, f.gst_cents, COALESCE(f.receipt,''),
+		       COALESCE(NULLIF(f.note,''), COALESCE(t.notes,'')),
+		       (SELECT COUNT(*) FROM attachments a WHERE a.tx_id = t.id)
+		FROM transactions t
+		LEFT JOIN tax_flags f ON f.tx_id = t.id
+		LEFT JOIN tax_category_map m ON m.category_norm = `+categorySQL+`
+		WHERE t.txn_date >= ? AND t.txn_date < ?
+		  AND COALESCE(f.tax_category, m.tax_category, ?) != ?
+		ORDER BY t.txn_date, t.id`, from, to, NotDeductible, NotDeductible)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	byCat := map[string][]Item{}
+	for rows.Next() {
+		var it Item
+		var d string
+		var gst sql.NullInt64
+		if err := rows.Scan(&it.TxID, &d, &it.AmountCents, &it.Merchant, &it.Details, &it.Category,
+			&it.TaxCategory, &it.Flagged, &gst, &it.Receipt, &it.Note, &it.Attachments); err != nil {
+			return nil, err
+		}
+		it.Date, _ = time.Parse("2006-01-02", d)
+		it.GSTCents, it.HasGST = gst.Int64, gst.Valid
+		byCat[it.TaxCategory] = append(byCat[it.TaxCategory], it)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for _, c := range Categories {
+		items := byCat[c.Key]
+		if len(items) == 0 {
+			continue
+		}
+		t := Total{Category: c, Count: len(items)}
+		for _, it := range items {
+			t.AmountCents += it.AmountCents
+			t.GSTCents += it.GSTCents
+			if it.Attachments == 0 {
+				t.Unattached++
+			}
+		}
+		r.Totals = append(r.Totals, t)
+		r.Items = append(r.Items, items...)
+	}
+	return r, nil
+}
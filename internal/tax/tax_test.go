@@ -0,0 +1,66 @@
+package tax
+
+import (
+	"testing"
+	"time"
+)
+
+func TestYearOf(t *testing.T) {
+	tests := []struct {
+		date string
+		want Year
+	}{
+		{"2025-07-01", 2026},
+		{"2026-01-15", 2026},
+		{"2026-06-30", 2026},
+		{"2026-07-01", 2027},
+		{"2026-12-31", 2027},
+	}
+	for _, tt := range tests {
+		d, _ := time.Parse("2006-01-02", tt.date)
+		if got := YearOf(d); got != tt.want {
+			t.Errorf("YearOf(%s) = %d, want %d", tt.date, got, tt.want)
+		}
+	}
+}
+
+func TestParseYear(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Year
+		wantErr bool
+	}{
+		{in: "2025-26", want: 2026},
+		{in: " 2025-26 ", want: 2026},
+		{in: "2026", want: 2026},
+		{in: "1999-00", want: 2000},
+		{in: "2025-27", wantErr: true},
+		{in: "2025-2026", wantErr: true},
+		{in: "2025-6", wantErr: true},
+		{in: "FY26", wantErr: true},
+		{in: "26", wantErr: true},
+		{in: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseYear(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseYear(%q) = %d, %v; want %d, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestYearRange(t *testing.T) {
+	y := Year(2026)
+	if s := y.String(); s != "2025-26" {
+		t.Errorf("String() = %q", s)
+	}
+	if s := y.Start().Format("2006-01-02"); s != "2025-07-01" {
+		t.Errorf("Start() = %s", s)
+	}
+	if s := y.End().Format("2006-01-02"); s != "2026-07-01" {
+		t.Errorf("End() = %s", s)
+	}
+	if p, err := ParseYear(y.String()); err != nil || p != y {
+		t.Errorf("ParseYear(String()) = %d, %v", p, err)
+	}
+}
@@ -2,26 +2,70 @@ package web
 
 import (
 	"embed"
+	"fmt"
 	"html/template"
+	"io/fs"
 	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/anthurium-ai/personal-finance/internal/search"
 )
 
 //go:embed templates/*.html
 var templatesFS embed.FS
 
+// Templates holds one template set per page. Every page redefines the
+// layout's "title" and "content" blocks, so pages can't share a set.
 type Templates struct {
-	base *template.Template
+	pages map[string]*template.Template
 }
 
 func LoadTemplates() (*Templates, error) {
-	t, err := template.ParseFS(templatesFS, "templates/*.html")
+	layout, err := template.New("").Funcs(funcs).ParseFS(templatesFS, "templates/layout.html")
+	if err != nil {
+		return nil, err
+	}
+	files, err := fs.Glob(templatesFS, "templates/*.html")
 	if err != nil {
 		return nil, err
 	}
-	return &Templates{base: t}, nil
+	t := &Templates{pages: map[string]*template.Template{}}
+	for _, f := range files {
+		name := strings.TrimSuffix(path.Base(f), ".html")
+		if name == "layout" {
+			continue
+		}
+		base, err := layout.Clone()
+		if err != nil {
+			return nil, err
+		}
+		page, err := base.ParseFS(templatesFS, f)
+		if err != nil {
+			return nil, err
+		}
+		t.pages[name] = page
+	}
+	return t, nil
 }
 
 func (t *Templates) Render(w http.ResponseWriter, name string, data any) {
+	page, ok := t.pages[name]
+	if !ok {
+		http.Error(w, fmt.Sprintf("unknown template %q", name), 500)
+		return
+	}
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	_ = t.base.ExecuteTemplate(w, name, data)
+	_ = page.ExecuteTemplate(w, name, data)
+}
+
+var funcs = template.FuncMap{
+	// pathEscape makes a value safe as a single path segment, e.g. /merchants/{{pathEscape .Name}}
+	"pathEscape": url.PathEscape,
+	// mark shows search hits as <mark>, e.g. {{mark .Details}}
+	"mark": func(s string) template.HTML {
+		s = template.HTMLEscapeString(s)
+		return template.HTML(strings.NewReplacer(search.HitStart, "<mark>", search.HitEnd, "</mark>").Replace(s))
+	},
 }